@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+
+	"github.com/jetstack/paranoia/cmd/options"
+	"github.com/jetstack/paranoia/internal/baseline"
+	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/output"
+)
+
+func newBaseline(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "baseline subcommand",
+		Short: "Compare the trust store of an image against its distribution's default",
+		Long: `
+Compares the certificate authorities trusted by the operating system in a container image against a baseline, to find those which were added or removed.
+The baseline is usually the stock certificate bundle of the image's distribution, either built from a reference image such as the distribution's base image, or loaded from a file written by "paranoia baseline create".
+
+The distribution of each image is detected from its os-release file, and the package which installed its trusted certificates, such as ca-certificates, from the package database.
+A warning is printed if the baseline is for a different distribution or version than the image.
+`,
+	}
+
+	cmd.AddCommand(newBaselineCreate(ctx))
+	cmd.AddCommand(newBaselineCompare(ctx))
+
+	return cmd
+}
+
+func newBaselineCreate(ctx context.Context) *cobra.Command {
+	var imgOpts *options.Image
+
+	cmd := &cobra.Command{
+		Use:   "create [flags] image",
+		Short: "Write a baseline of the certificate authorities trusted by an image",
+		Long: `
+Writes a baseline of the certificate authorities trusted by the operating system in the given image to STDOUT, in YAML.
+The baseline records the image's distribution and the package which installed its trusted certificates, along with the fingerprint and subject of each certificate.
+`,
+		Example: `
+Create a baseline from the Debian 12 base image:
+
+	$ paranoia baseline create debian:12 > debian-12.yaml
+`,
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			return options.MustSingleImageArgs(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			imageName := args[0]
+
+			iOpts, err := imgOpts.Options()
+			if err != nil {
+				return errors.Wrap(err, "constructing image options")
+			}
+
+			parsedCertificates, err := image.FindImageCertificates(ctx, imageName, iOpts...)
+			if err != nil {
+				return err
+			}
+
+			return baseline.New(parsedCertificates, imageName).Write(os.Stdout)
+		},
+	}
+
+	imgOpts = options.RegisterImage(cmd)
+	cmd.Args = cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)
+
+	return cmd
+}
+
+func newBaselineCompare(ctx context.Context) *cobra.Command {
+	var (
+		imgOpts      *options.Image
+		outOpts      *options.Output
+		baselineOpts *options.Baseline
+	)
+
+	cmd := &cobra.Command{
+		Use:   "compare [flags] image",
+		Short: "Report the certificate authorities added or removed relative to a baseline",
+		Long: `
+Compares the certificate authorities trusted by the operating system in the given image against a baseline.
+Certificates trusted by the image but not in the baseline are reported as added, and certificates in the baseline not trusted by the image as removed.
+`,
+		Example: `
+Compare an image against the base image of its distribution:
+
+	$ paranoia baseline compare --baseline-image debian:12 my-app:latest
+
+Compare an image against a baseline file:
+
+	$ paranoia baseline compare --baseline debian-12.yaml my-app:latest
+`,
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			if err := options.MustSingleImageArgs(args); err != nil {
+				return err
+			}
+			if err := baselineOpts.Validate(); err != nil {
+				return err
+			}
+			return outOpts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			imageName := args[0]
+
+			iOpts, err := imgOpts.Options()
+			if err != nil {
+				return errors.Wrap(err, "constructing image options")
+			}
+
+			var base *baseline.Baseline
+			if baselineOpts.File != "" {
+				base, err = baseline.Load(baselineOpts.File)
+				if err != nil {
+					return errors.Wrap(err, "failed to load baseline")
+				}
+			} else {
+				reference, err := image.FindImageCertificates(ctx, baselineOpts.Image, iOpts...)
+				if err != nil {
+					return errors.Wrap(err, "failed to scan baseline image")
+				}
+				base = baseline.New(reference, baselineOpts.Image)
+			}
+
+			parsedCertificates, err := image.FindImageCertificates(ctx, imageName, iOpts...)
+			if err != nil {
+				return err
+			}
+
+			comparison := baseline.Compare(base, parsedCertificates)
+			if !comparison.SameOperatingSystem(base) {
+				fmt.Fprintf(os.Stderr, "Warning: the baseline is for %s, but the image is %s\n",
+					describeSystem(base.OperatingSystem, nil), describeSystem(comparison.OperatingSystem, nil))
+			}
+
+			if outOpts.Mode == options.OutputModeJSON {
+				out := output.JSONBaselineComparison{
+					SchemaVersion:           output.JSONSchemaVersion,
+					OperatingSystem:         output.NewJSONOperatingSystem(parsedCertificates.OperatingSystem),
+					Package:                 baselinePackage(comparison.Package),
+					BaselineSource:          base.Source,
+					BaselineOperatingSystem: baselineOperatingSystem(base.OperatingSystem),
+					BaselinePackage:         baselinePackage(base.Package),
+					Added:                   []output.JSONCertificate{},
+					Removed:                 []output.JSONBaselineCertificate{},
+				}
+				for _, found := range comparison.Added {
+					out.Added = append(out.Added, output.NewJSONCertificate(found, false))
+				}
+				for _, cert := range comparison.Removed {
+					out.Removed = append(out.Removed, output.JSONBaselineCertificate{
+						Subject:           cert.Subject,
+						FingerprintSHA256: cert.FingerprintSHA256,
+					})
+				}
+
+				m, err := json.Marshal(out)
+				if err != nil {
+					return errors.Wrap(err, "failed to marshall output JSON")
+				}
+				fmt.Println(string(m))
+				return nil
+			}
+
+			fmt.Printf("Image:    %s\n", describeSystem(comparison.OperatingSystem, comparison.Package))
+			source := ""
+			if base.Source != "" {
+				source = " from " + base.Source
+			}
+			fmt.Printf("Baseline: %s%s\n", describeSystem(base.OperatingSystem, base.Package), source)
+
+			if comparison.IsEmpty() {
+				fmt.Println("The image trusts exactly the certificates in the baseline")
+				return nil
+			}
+
+			headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+			columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+			tbl := table.New("Change", "File Location", "Subject", "SHA-256")
+			tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+			for _, found := range comparison.Added {
+				tbl.AddRow("added", found.Location, found.Certificate.Subject, hex.EncodeToString(found.FingerprintSha256[:]))
+			}
+			for _, cert := range comparison.Removed {
+				tbl.AddRow("removed", "", cert.Subject, cert.FingerprintSHA256)
+			}
+			tbl.Print()
+			fmt.Printf("Found %d certificates added and %d removed relative to the baseline\n", len(comparison.Added), len(comparison.Removed))
+
+			return nil
+		},
+	}
+
+	imgOpts = options.RegisterImage(cmd)
+	outOpts = options.RegisterComparisonOutputs(cmd)
+	baselineOpts = options.RegisterBaseline(cmd)
+	cmd.Args = cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)
+
+	return cmd
+}
+
+// describeSystem describes a distribution and the package which installed its
+// trusted certificates, such as "Debian GNU/Linux 12 (bookworm), ca-certificates
+// 20230311".
+func describeSystem(system *baseline.OperatingSystem, pkg *baseline.Package) string {
+	s := "an unknown distribution"
+	if system != nil {
+		s = system.PrettyName
+		if s == "" {
+			s = system.ID + " " + system.VersionID
+		}
+	}
+	if pkg != nil {
+		s += ", " + pkg.Name + " " + pkg.Version
+	}
+	return s
+}
+
+func baselineOperatingSystem(system *baseline.OperatingSystem) *output.JSONOperatingSystem {
+	if system == nil {
+		return nil
+	}
+	return &output.JSONOperatingSystem{
+		ID:         system.ID,
+		VersionID:  system.VersionID,
+		PrettyName: system.PrettyName,
+	}
+}
+
+func baselinePackage(pkg *baseline.Package) *output.JSONPackage {
+	if pkg == nil {
+		return nil
+	}
+	return &output.JSONPackage{
+		Manager: pkg.Manager,
+		Name:    pkg.Name,
+		Version: pkg.Version,
+	}
+}
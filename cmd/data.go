@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/jetstack/paranoia/cmd/options"
+	"github.com/jetstack/paranoia/internal/analyse"
+)
+
+func newData(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "data subcommand",
+		Short: "Manage the data used when analysing certificates",
+		Long: `
+Paranoia uses published data, such as Mozilla's list of removed certificate authorities, when analysing certificates.
+This data is downloaded when needed and cached in the user cache directory, falling back to a snapshot built into Paranoia when it cannot be downloaded.
+`,
+	}
+
+	cmd.AddCommand(newDataUpdate(ctx))
+
+	return cmd
+}
+
+func newDataUpdate(ctx context.Context) *cobra.Command {
+	var analyseOpts *options.Analyse
+
+	cmd := &cobra.Command{
+		Use:   "update [flags]",
+		Short: "Download the latest data into the cache",
+		Long: `
+Downloads the latest copy of all data used when analysing certificates, and stores it in the cache.
+This can be used to pre-populate the cache before running Paranoia on a runner without network access.
+`,
+		Example: `
+Populate a cache directory, and use it later without network access:
+
+	$ paranoia data update --data-cache-dir ./paranoia-cache
+	$ paranoia inspect --offline --data-cache-dir ./paranoia-cache alpine:latest
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			paths, err := analyse.UpdateData(ctx, analyseOpts)
+			if err != nil {
+				return errors.Wrap(err, "failed to update data")
+			}
+
+			var names []string
+			for name := range paths {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Printf("Updated %s in %s\n", name, paths[name])
+			}
+
+			return nil
+		},
+	}
+
+	analyseOpts = options.RegisterAnalyse(cmd)
+
+	return cmd
+}
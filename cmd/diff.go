@@ -0,0 +1,212 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+	"github.com/rodaine/table"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/jetstack/paranoia/cmd/options"
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/diff"
+	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/output"
+)
+
+func newDiff(ctx context.Context) *cobra.Command {
+	var (
+		imgOpts  *options.Image
+		outOpts  *options.Output
+		diffOpts *options.Diff
+	)
+
+	cmd := &cobra.Command{
+		Use:   "diff [flags] imageA imageB",
+		Short: "Show the certificate changes between two container images",
+		Long: `
+Scans both container images and reports the certificates which were added, removed, moved between files, or changed between them.
+Certificates are identified by their SHA-256 fingerprint.
+A certificate is changed if one with the same subject, but a different fingerprint, replaces it, such as a renewed certificate authority.
+
+With --fail-on-added-trust-anchor, the command exits with a nonzero code if the second image adds a trust anchor.
+A trust anchor is a self-signed certificate authority, whether added or changed.
+`,
+		Example: `
+Show the certificate authority changes a base image update brings:
+
+	$ paranoia diff debian:11 debian:12
+
+Comment on a pull request, and fail if a trust anchor was added:
+
+	$ paranoia diff --output markdown --fail-on-added-trust-anchor my-app:main my-app:pr-123
+`,
+		Args: cobra.ExactArgs(2),
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			return outOpts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			beforeName, afterName := args[0], args[1]
+
+			iOpts, err := imgOpts.Options()
+			if err != nil {
+				return errors.Wrap(err, "constructing image options")
+			}
+
+			var before, after *certificate.ParsedCertificates
+			g, gctx := errgroup.WithContext(ctx)
+			g.Go(func() error {
+				var err error
+				before, err = image.FindImageCertificates(gctx, beforeName, iOpts...)
+				return errors.Wrapf(err, "failed to scan %s", beforeName)
+			})
+			g.Go(func() error {
+				var err error
+				after, err = image.FindImageCertificates(gctx, afterName, iOpts...)
+				return errors.Wrapf(err, "failed to scan %s", afterName)
+			})
+			if err := g.Wait(); err != nil {
+				return err
+			}
+
+			changes := diff.Diff(before, after)
+
+			switch outOpts.Mode {
+			case options.OutputModeJSON:
+				out := output.JSONDiff{
+					SchemaVersion: output.JSONSchemaVersion,
+					Before:        beforeName,
+					After:         afterName,
+					Changes:       []output.JSONDiffChange{},
+				}
+				for _, c := range changes {
+					out.Changes = append(out.Changes, output.JSONDiffChange{
+						Kind:            string(c.Kind),
+						Subject:         c.Subject(),
+						AddsTrustAnchor: c.AddsTrustAnchor(),
+						Before:          jsonDiffSide(c.Before),
+						After:           jsonDiffSide(c.After),
+					})
+				}
+
+				m, err := json.Marshal(out)
+				if err != nil {
+					return errors.Wrap(err, "failed to marshall output JSON")
+				}
+				fmt.Println(string(m))
+			case options.OutputModeMarkdown:
+				fmt.Print(diffMarkdown(beforeName, afterName, changes))
+			default:
+				if len(changes) == 0 {
+					fmt.Printf("No certificate changes between %s and %s\n", beforeName, afterName)
+					break
+				}
+
+				headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+				columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+				tbl := table.New("Change", "Subject", "Before", "After", "SHA-256")
+				tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+				for _, c := range changes {
+					tbl.AddRow(c.Kind, c.Subject(), diffLocations(c.Before), diffLocations(c.After), diffFingerprint(c))
+				}
+				tbl.Print()
+				fmt.Println(diffSummary(changes))
+			}
+
+			if diffOpts.FailOnAddedTrustAnchor && diff.AddsTrustAnchor(changes) {
+				fmt.Fprintf(os.Stderr, "Trust anchors were added in %s\n", afterName)
+				os.Exit(1)
+			}
+
+			return nil
+		},
+	}
+
+	imgOpts = options.RegisterImage(cmd)
+	outOpts = options.RegisterDiffOutputs(cmd)
+	diffOpts = options.RegisterDiff(cmd)
+
+	return cmd
+}
+
+func jsonDiffSide(s *diff.Side) *output.JSONDiffSide {
+	if s == nil {
+		return nil
+	}
+	return &output.JSONDiffSide{
+		Locations:   s.Locations,
+		Certificate: output.NewJSONCertificate(s.Found, false),
+	}
+}
+
+func diffLocations(s *diff.Side) string {
+	if s == nil {
+		return ""
+	}
+	return strings.Join(s.Locations, ", ")
+}
+
+// diffFingerprint is the fingerprint of the certificate in the second image,
+// or in the first image if it was removed.
+func diffFingerprint(c diff.Change) string {
+	s := c.After
+	if s == nil {
+		s = c.Before
+	}
+	return hex.EncodeToString(s.Found.FingerprintSha256[:])
+}
+
+// diffSummary counts the changes of each kind, such as "Found 3 changes: 1
+// added, 2 removed, 0 moved, 0 changed".
+func diffSummary(changes []diff.Change) string {
+	counts := make(map[diff.Kind]int)
+	for _, c := range changes {
+		counts[c.Kind]++
+	}
+	return fmt.Sprintf("Found %d changes: %d added, %d removed, %d moved, %d changed",
+		len(changes), counts[diff.KindAdded], counts[diff.KindRemoved], counts[diff.KindMoved], counts[diff.KindChanged])
+}
+
+func diffMarkdown(beforeName, afterName string, changes []diff.Change) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "### Certificate changes from `%s` to `%s`\n\n", beforeName, afterName)
+	if len(changes) == 0 {
+		b.WriteString("No certificate changes.\n")
+		return b.String()
+	}
+
+	b.WriteString("| Change | Subject | Before | After | SHA-256 |\n")
+	b.WriteString("| --- | --- | --- | --- | --- |\n")
+	for _, c := range changes {
+		kind := string(c.Kind)
+		if c.AddsTrustAnchor() {
+			kind = "**" + kind + " trust anchor**"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | `%s` |\n",
+			kind, markdownEscape(c.Subject()), markdownCode(diffLocations(c.Before)), markdownCode(diffLocations(c.After)), diffFingerprint(c))
+	}
+	fmt.Fprintf(&b, "\n%s\n", diffSummary(changes))
+	return b.String()
+}
+
+// markdownEscape escapes the characters which would break a Markdown table.
+func markdownEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+func markdownCode(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "`" + markdownEscape(s) + "`"
+}
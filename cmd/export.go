@@ -5,10 +5,10 @@ package cmd
 import (
 	"context"
 	"encoding/hex"
-	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/fatih/color"
@@ -17,24 +17,37 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/jetstack/paranoia/cmd/options"
-	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/analyse"
+	"github.com/jetstack/paranoia/internal/certificate"
 	"github.com/jetstack/paranoia/internal/output"
 )
 
 func newExport(ctx context.Context) *cobra.Command {
 	var (
-		imgOpts *options.Image
-		outOpts *options.Output
+		imgOpts    *options.Image
+		imagesOpts *options.Images
+		outOpts    *options.Output
+		rootOpts   *options.Analyse
 	)
 
 	cmd := &cobra.Command{
-		Use:   "export [flags] image",
+		Use:   "export [flags] image...",
 		Short: "Export all certificate authorities in the given container image",
 		Long: `
 Exports all certificates found in the container image.
 The detail available depends on the output mode used
 
-In most output modes, partial certificates are also included after the main output.
+Certificates are found in PEM blocks labelled "CERTIFICATE", "X509 CERTIFICATE", and OpenSSL's "TRUSTED CERTIFICATE".
+In most output modes, partial certificates, private keys, certificate revocation lists (CRLs), and certificate signing requests (CSRs) are also included after the main output.
+
+In wide and JSON output, certificates in files installed by an operating system package (dpkg, apk or rpm) show the package name and version.
+Files whose contents no longer match the checksum recorded by the package manager are marked as modified.
+
+Many images can be exported in one invocation, given as arguments or listed in a file with *--images-from*.
+They are scanned *--parallelism* at a time, and layers shared between images are only downloaded once.
+The output for each image is introduced by its name and digest.
+In JSON output, the top level "images" key is an array with an object for each image, with keys for "image", "digest", and "error" if it couldn't be scanned, alongside the usual keys.
+In PEM output, the certificates of each image are preceded by a comment line with its name and digest.
 `,
 		Example: `
 Export certificates for an image:
@@ -44,114 +57,274 @@ Export certificates for an image:
 Pipe certificate information into jq:
 
 	$ paranoia export --output json alpine:latest | jq '.certificates[].fingerprintSHA256'
+
+Show which certificates are included in the Chrome Root Store:
+
+	$ paranoia export --output wide --root-programme chrome=./chrome-root-store.csv alpine:latest
+
+Export the certificates of every image listed in a file:
+
+	$ paranoia export --output json --images-from images.txt
 `,
 		PreRunE: func(_ *cobra.Command, args []string) error {
-			if err := options.MustSingleImageArgs(args); err != nil {
+			if err := imagesOpts.Validate(); err != nil {
 				return err
 			}
 			return outOpts.Validate()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			imageName := args[0]
+			names, err := imagesOpts.Names(args)
+			if err != nil {
+				return err
+			}
 
 			iOpts, err := imgOpts.Options()
 			if err != nil {
 				return errors.Wrap(err, "constructing image options")
 			}
 
-			parsedCertificates, err := image.FindImageCertificates(ctx, imageName, iOpts...)
+			results, err := scanImages(ctx, names, imagesOpts, iOpts)
 			if err != nil {
 				return err
 			}
 
-			if outOpts.Mode == options.OutputModePretty || outOpts.Mode == options.OutputModeWide {
-				wide := outOpts.Mode == options.OutputModeWide
-				headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
-				columnFmt := color.New(color.FgYellow).SprintfFunc()
+			programmes, warnings, err := analyse.LoadRootProgrammes(ctx, rootOpts)
+			printWarnings(warnings)
+			if err != nil {
+				return errors.Wrap(err, "failed to load root programmes")
+			}
+
+			if !imagesOpts.Aggregate(names) {
+				parsedCertificates := results[0].Parsed
+				warnMissingTrustVariables(parsedCertificates)
 
-				var tbl table.Table
-				if wide {
-					tbl = table.New("File Location", "Parser", "Subject", "Not Before", "Not After", "SHA-256")
-				} else {
-					tbl = table.New("File Location", "Subject")
+				switch outOpts.Mode {
+				case options.OutputModePretty, options.OutputModeWide:
+					printExport(parsedCertificates, outOpts.Mode == options.OutputModeWide, programmes)
+				case options.OutputModeJSON:
+					return printJSON(output.NewJSONOutput(parsedCertificates, programmes, outOpts.IncludePEM))
+				case options.OutputModePEM:
+					printPEM(parsedCertificates)
+				}
+				return nil
+			}
+
+			switch outOpts.Mode {
+			case options.OutputModePretty, options.OutputModeWide:
+				for _, res := range results {
+					fmt.Println(imageHeading(res, imagesOpts.Containers(res.Name)))
+					if res.Err != nil {
+						fmt.Fprintf(os.Stderr, "error: %v\n", res.Err)
+						continue
+					}
+					warnMissingTrustVariables(res.Parsed)
+					printExport(res.Parsed, outOpts.Mode == options.OutputModeWide, programmes)
 				}
-				tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
-
-				for _, cert := range parsedCertificates.Found {
-					if wide {
-						tbl.AddRow(cert.Location, cert.Parser, cert.Certificate.Subject,
-							cert.Certificate.NotBefore.Format(time.RFC3339),
-							cert.Certificate.NotAfter.Format(time.RFC3339),
-							hex.EncodeToString(cert.FingerprintSha256[:]))
+			case options.OutputModeJSON:
+				out := output.JSONImagesOutput{SchemaVersion: output.JSONSchemaVersion}
+				for _, res := range results {
+					img := output.JSONImageOutput{Image: res.Name, Digest: res.Digest, Workloads: output.NewJSONWorkloads(imagesOpts.Containers(res.Name))}
+					if res.Err != nil {
+						img.Error = res.Err.Error()
 					} else {
-						tbl.AddRow(cert.Location, cert.Certificate.Subject)
+						report := output.NewJSONOutput(res.Parsed, programmes, outOpts.IncludePEM)
+						report.SchemaVersion = ""
+						img.JSONOutput = &report
 					}
+					out.Images = append(out.Images, img)
 				}
+				if err := printJSON(out); err != nil {
+					return err
+				}
+			case options.OutputModePEM:
+				for _, res := range results {
+					if res.Err != nil {
+						fmt.Fprintf(os.Stderr, "error: %s: %v\n", res.Name, res.Err)
+						continue
+					}
+					fmt.Printf("# %s@%s\n", res.Name, res.Digest)
+					printPEM(res.Parsed)
+				}
+			}
 
-				tbl.Print()
-				fmt.Printf("Found %d certificates\n", len(parsedCertificates.Found))
+			return failedImages(results)
+		},
+	}
 
-				if len(parsedCertificates.Partials) > 0 {
-					headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
-					columnFmt := color.New(color.FgYellow).SprintfFunc()
+	imgOpts = options.RegisterImage(cmd)
+	imagesOpts = options.RegisterImages(cmd)
+	outOpts = options.RegisterOutputs(cmd)
+	rootOpts = options.RegisterRootProgrammes(cmd)
 
-					tbl := table.New("File Location", "Parser", "Reason")
-					tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+	return cmd
+}
 
-					for _, p := range parsedCertificates.Partials {
-						tbl.AddRow(p.Location, p.Parser, p.Reason)
-					}
+// printExport prints tables of the certificates, and any partial certificates,
+// private keys, CRLs and certificate requests found in an image.
+func printExport(parsedCertificates *certificate.ParsedCertificates, wide bool, programmes []analyse.RootProgramme) {
+	headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+	columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-					tbl.Print()
-					fmt.Printf("Found %d partial certificates\n", len(parsedCertificates.Partials))
-				}
+	var tbl table.Table
+	if wide && len(programmes) > 0 {
+		tbl = table.New("File Location", "Parser", "Subject", "Not Before", "Not After", "SHA-256", "Trust", "Package", "Root Programmes")
+	} else if wide {
+		tbl = table.New("File Location", "Parser", "Subject", "Not Before", "Not After", "SHA-256", "Trust", "Package")
+	} else {
+		tbl = table.New("File Location", "Subject")
+	}
+	tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
 
-			} else if outOpts.Mode == options.OutputModeJSON {
-				var out output.JSONOutput
-
-				for _, cert := range parsedCertificates.Found {
-					out.Certificates = append(out.Certificates, output.JSONCertificate{
-						FileLocation:      cert.Location,
-						Owner:             cert.Certificate.Subject.String(),
-						Parser:            cert.Parser,
-						Signature:         fmt.Sprintf("%X", cert.Certificate.Signature),
-						NotBefore:         cert.Certificate.NotBefore.Format(time.RFC3339),
-						NotAfter:          cert.Certificate.NotAfter.Format(time.RFC3339),
-						FingerprintSHA1:   hex.EncodeToString(cert.FingerprintSha1[:]),
-						FingerprintSHA256: hex.EncodeToString(cert.FingerprintSha256[:]),
-					})
+	for _, cert := range parsedCertificates.Found {
+		if wide {
+			row := []interface{}{cert.Location, cert.Parser, cert.Certificate.Subject,
+				cert.Certificate.NotBefore.Format(time.RFC3339),
+				cert.Certificate.NotAfter.Format(time.RFC3339),
+				hex.EncodeToString(cert.FingerprintSha256[:]),
+				trustDescription(cert), packageDescription(cert)}
+			if len(programmes) > 0 {
+				var memberships []string
+				for _, m := range analyse.Memberships(programmes, cert.Certificate) {
+					memberships = append(memberships, m.Programme+":"+string(m.Status))
 				}
+				row = append(row, strings.Join(memberships, " "))
+			}
+			tbl.AddRow(row...)
+		} else {
+			tbl.AddRow(cert.Location, cert.Certificate.Subject)
+		}
+	}
 
-				for _, p := range parsedCertificates.Partials {
-					out.PartialCertificates = append(out.PartialCertificates, output.JSONPartialCertificate{
-						FileLocation: p.Location,
-						Parser:       p.Parser,
-						Reason:       p.Reason,
-					})
-				}
+	tbl.Print()
+	fmt.Printf("Found %d certificates\n", len(parsedCertificates.Found))
 
-				m, err := json.Marshal(out)
-				if err != nil {
-					return errors.Wrap(err, "failed to marshall output JSON")
-				}
+	if len(parsedCertificates.Partials) > 0 {
+		headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+		columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+		tbl := table.New("File Location", "Parser", "Reason")
+		tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+		for _, p := range parsedCertificates.Partials {
+			tbl.AddRow(p.Location, p.Parser, p.Reason)
+		}
+
+		tbl.Print()
+		fmt.Printf("Found %d partial certificates\n", len(parsedCertificates.Partials))
+	}
+
+	if len(parsedCertificates.PrivateKeys) > 0 {
+		headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+		columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-				fmt.Println(string(m))
-			} else if outOpts.Mode == options.OutputModePEM {
-				for _, cert := range parsedCertificates.Found {
-					pem.Encode(os.Stdout, &pem.Block{
-						Type:  "CERTIFICATE",
-						Bytes: cert.Certificate.Raw,
-					})
+		var tbl table.Table
+		if wide {
+			tbl = table.New("File Location", "Parser", "Format", "Type", "Size", "Encrypted", "Matching Certificates")
+		} else {
+			tbl = table.New("File Location", "Type", "Encrypted")
+		}
+		tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+		for _, key := range parsedCertificates.PrivateKeys {
+			if wide {
+				var matching []string
+				for _, fingerprint := range key.MatchingCertificates {
+					matching = append(matching, hex.EncodeToString(fingerprint[:]))
 				}
+				tbl.AddRow(key.Location, key.Parser, key.Format, key.Type, key.Size, key.Encrypted, strings.Join(matching, " "))
+			} else {
+				tbl.AddRow(key.Location, key.Type, key.Encrypted)
 			}
+		}
 
-			return nil
-		},
+		tbl.Print()
+		fmt.Printf("Found %d private keys\n", len(parsedCertificates.PrivateKeys))
 	}
 
-	imgOpts = options.RegisterImage(cmd)
-	outOpts = options.RegisterOutputs(cmd)
-	cmd.Args = cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)
+	if len(parsedCertificates.CRLs) > 0 {
+		headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+		columnFmt := color.New(color.FgYellow).SprintfFunc()
 
-	return cmd
+		var tbl table.Table
+		if wide {
+			tbl = table.New("File Location", "Parser", "Issuer", "This Update", "Next Update", "Revoked", "SHA-256")
+		} else {
+			tbl = table.New("File Location", "Issuer")
+		}
+		tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+		for _, crl := range parsedCertificates.CRLs {
+			rl := crl.RevocationList
+			if wide {
+				tbl.AddRow(crl.Location, crl.Parser, rl.Issuer,
+					rl.ThisUpdate.Format(time.RFC3339),
+					rl.NextUpdate.Format(time.RFC3339),
+					len(rl.RevokedCertificateEntries),
+					hex.EncodeToString(crl.FingerprintSha256[:]))
+			} else {
+				tbl.AddRow(crl.Location, rl.Issuer)
+			}
+		}
+
+		tbl.Print()
+		fmt.Printf("Found %d CRLs\n", len(parsedCertificates.CRLs))
+	}
+
+	if len(parsedCertificates.CertificateRequests) > 0 {
+		headerFmt := color.New(color.FgGreen, color.Underline).SprintfFunc()
+		columnFmt := color.New(color.FgYellow).SprintfFunc()
+
+		var tbl table.Table
+		if wide {
+			tbl = table.New("File Location", "Parser", "Subject", "SHA-256")
+		} else {
+			tbl = table.New("File Location", "Subject")
+		}
+		tbl.WithHeaderFormatter(headerFmt).WithFirstColumnFormatter(columnFmt)
+
+		for _, req := range parsedCertificates.CertificateRequests {
+			if wide {
+				tbl.AddRow(req.Location, req.Parser, req.Request.Subject, hex.EncodeToString(req.FingerprintSha256[:]))
+			} else {
+				tbl.AddRow(req.Location, req.Request.Subject)
+			}
+		}
+
+		tbl.Print()
+		fmt.Printf("Found %d certificate requests\n", len(parsedCertificates.CertificateRequests))
+	}
+
+}
+
+// printPEM prints every certificate found in an image in PEM format.
+func printPEM(parsedCertificates *certificate.ParsedCertificates) {
+	for _, cert := range parsedCertificates.Found {
+		pem.Encode(os.Stdout, &pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: cert.Certificate.Raw,
+		})
+	}
+}
+
+// trustDescription describes the effective trust of the certificate, along with
+// any environment variables through which it is trusted.
+func trustDescription(found certificate.Found) string {
+	s := string(found.Trust)
+	if len(found.TrustVariables) > 0 {
+		s += " (" + strings.Join(found.TrustVariables, ", ") + ")"
+	}
+	return s
+}
+
+// packageDescription describes the operating system package which installed
+// the certificate's file, and whether the file has since been modified.
+func packageDescription(found certificate.Found) string {
+	if found.Package == nil {
+		return ""
+	}
+	s := found.Package.Name + " " + found.Package.Version
+	if found.Package.Modified {
+		s += " (modified)"
+	}
+	return s
 }
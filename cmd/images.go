@@ -0,0 +1,90 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/pkg/errors"
+
+	"github.com/jetstack/paranoia/cmd/options"
+	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/manifests"
+	"github.com/jetstack/paranoia/internal/output"
+)
+
+// scanImages scans the named images, with the parallelism given by the images
+// options, and prints the warnings from scanning them. A single image is
+// scanned on its own, so that its error is returned directly; otherwise errors
+// are recorded in each image's result.
+func scanImages(ctx context.Context, names []string, imagesOpts *options.Images, iOpts []image.Option) ([]image.Result, error) {
+	if len(names) == 1 {
+		res, err := image.ScanImage(ctx, names[0], iOpts...)
+		if err != nil {
+			return nil, err
+		}
+		printWarnings(res.Warnings)
+		return []image.Result{*res}, nil
+	}
+	results, err := image.ScanImages(ctx, names, imagesOpts.Parallelism, iOpts...)
+	for _, res := range results {
+		for _, w := range res.Warnings {
+			fmt.Fprintf(os.Stderr, "Warning: %s: %s\n", res.Name, w)
+		}
+	}
+	return results, err
+}
+
+// imageHeading introduces the report for one of many images, with its name and
+// digest, and the containers in manifests which run it.
+func imageHeading(res image.Result, containers []manifests.Container) string {
+	heading := color.New(color.Bold).Sprintf("Image %s", res.Name)
+	if res.Digest != "" {
+		heading = color.New(color.Bold).Sprintf("Image %s (%s)", res.Name, res.Digest)
+	}
+	for _, c := range containers {
+		heading += fmt.Sprintf("\n  used by %s (%s)", c, c.Source)
+	}
+	return heading
+}
+
+// failedImages returns an error if any of the images couldn't be scanned.
+func failedImages(results []image.Result) error {
+	failed := 0
+	for _, res := range results {
+		if res.Err != nil {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("failed to scan %d of %d images", failed, len(results))
+	}
+	return nil
+}
+
+// scanFailureSuite is a JUnit suite for an image which couldn't be scanned,
+// with a single failing test case.
+func scanFailureSuite(res image.Result) output.JUnitTestSuite {
+	msg := res.Err.Error()
+	return output.JUnitTestSuite{
+		Name: res.Name,
+		TestCases: []output.JUnitTestCase{{
+			Name:     "image can be scanned",
+			Failures: []output.JUnitResult{{Message: msg, Type: "error", Text: msg}},
+		}},
+	}
+}
+
+// printJSON prints the output as JSON to STDOUT.
+func printJSON(out any) error {
+	m, err := json.Marshal(out)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshall output JSON")
+	}
+	fmt.Println(string(m))
+	return nil
+}
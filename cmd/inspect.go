@@ -6,6 +6,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"os"
 
 	"github.com/fatih/color"
 	"github.com/pkg/errors"
@@ -13,15 +14,18 @@ import (
 
 	"github.com/jetstack/paranoia/cmd/options"
 	"github.com/jetstack/paranoia/internal/analyse"
-	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/output"
 )
 
 func newInspect(ctx context.Context) *cobra.Command {
 	var imgOpts *options.Image
+	var imagesOpts *options.Images
 	var analyseOpts *options.Analyse
+	var outOpts *options.Output
 
 	cmd := &cobra.Command{
-		Use:   "inspect [flags] image",
+		Use:   "inspect [flags] image...",
 		Short: "Summarise potential issues with certificates",
 		Long: `
 Inspect prints out certificates that have one or more of the following faults:
@@ -29,21 +33,44 @@ Inspect prints out certificates that have one or more of the following faults:
 - Expired (based on current system time).
 - Close to expiry (based on current system time).
 - Removed by Mozilla from their certificate authority bundle.
+- Root certificates unknown to Mozilla's certificate authority bundle, such as private or test certificate authorities.
+  The level of this note is set with *--unknown-ca-note-level*.
+- Distrusted by any additional root programme given with *--root-programme*, such as the Chrome Root Store.
+- Weak cryptography, such as RSA keys under 2048 bits, DSA keys, weak elliptic curves, or MD5 and SHA-1 signatures on certificates which are not self-signed.
+- Leaf or intermediate certificates in well-known trust store locations, such as /etc/ssl/certs/.
+- Private keys, which should never be shipped in an image.
+- Trust related environment variables in the image config, such as SSL_CERT_FILE, which point to paths that don't exist.
+- Malformed or unusual certificates, such as X.509 version 1 certificates, negative or oversized serial numbers, or excessively long validity periods.
+
+Each issue is tagged with the ID of the rule which raised it.
+Rules can be suppressed individually with *--suppress-rule*.
 
 Partial certificates are also all printed for further inspection.
+
+Mozilla's lists of included and removed certificate authorities are downloaded and cached in the user cache directory.
+Use *--offline* to run without network access, using the cache (see "paranoia data update") or the snapshot built into Paranoia.
+
+Many images can be inspected in one invocation, given as arguments or listed in a file with *--images-from*.
+The issues for each image are introduced by its name and digest, and in JUnit output each suite name is prefixed with them.
 `,
 		PreRunE: func(_ *cobra.Command, args []string) error {
-			return options.MustSingleImageArgs(args)
+			if err := imagesOpts.Validate(); err != nil {
+				return err
+			}
+			return outOpts.Validate()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			imageName := args[0]
+			names, err := imagesOpts.Names(args)
+			if err != nil {
+				return err
+			}
 
 			iOpts, err := imgOpts.Options()
 			if err != nil {
 				return errors.Wrap(err, "constructing image options")
 			}
 
-			parsedCertificates, err := image.FindImageCertificates(ctx, imageName, iOpts...)
+			results, err := scanImages(ctx, names, imagesOpts, iOpts)
 			if err != nil {
 				return err
 			}
@@ -52,54 +79,208 @@ Partial certificates are also all printed for further inspection.
 			if err != nil {
 				return errors.Wrap(err, "failed to initialise analyser")
 			}
+			printWarnings(analyser.Warnings)
 
-			numIssues := 0
-			for _, cert := range parsedCertificates.Found {
-				if cert.Certificate == nil {
-					numIssues++
-					continue
+			if !imagesOpts.Aggregate(names) {
+				parsedCertificates := results[0].Parsed
+				if outOpts.Mode == options.OutputModeJUnit {
+					report := inspectJUnit(names[0], analyser, parsedCertificates)
+					if err := report.Write(os.Stdout); err != nil {
+						return errors.Wrap(err, "failed to write JUnit report")
+					}
+					return nil
 				}
-				notes := analyser.AnalyseCertificate(cert.Certificate)
-				if len(notes) > 0 {
-					numIssues++
-					fingerprint := hex.EncodeToString(cert.FingerprintSha256[:])
-					fmt.Printf("Certificate %s, Fingerprint: %s\n", cert.Certificate.Subject, fingerprint)
-					for i, n := range notes {
-						var lead string
-						if i == len(notes)-1 {
-							lead = "┗"
-						} else {
-							lead = "┣"
-						}
-						var fmtFn func(format string, a ...interface{}) string
-						var emoji string
-						if n.Level == analyse.NoteLevelError {
-							fmtFn = color.New(color.FgRed).SprintfFunc()
-							emoji = "🚨"
-						} else if n.Level == analyse.NoteLevelWarn {
-							fmtFn = color.New(color.FgYellow).SprintfFunc()
-							emoji = "⚠️"
-						}
-						fmt.Printf(lead + " " + fmtFn("%s %s\n", emoji, n.Reason))
+				printInspect(analyser, parsedCertificates)
+				return nil
+			}
+
+			if outOpts.Mode == options.OutputModeJUnit {
+				report := &output.JUnitTestSuites{Name: "paranoia inspect"}
+				for _, res := range results {
+					if res.Err != nil {
+						report.AddSuite(scanFailureSuite(res))
+						continue
 					}
+					report.AddReport(res.Name+"@"+res.Digest, inspectJUnit(res.Name, analyser, res.Parsed))
+				}
+				if err := report.Write(os.Stdout); err != nil {
+					return errors.Wrap(err, "failed to write JUnit report")
 				}
+				return failedImages(results)
 			}
-			fmt.Printf("Found %d certificates total, of which %d had issues\n", len(parsedCertificates.Found), numIssues)
-			if len(parsedCertificates.Partials) > 0 {
-				for _, p := range parsedCertificates.Partials {
-					fmtFn := color.New(color.FgYellow).SprintfFunc()
-					fmt.Print(fmtFn("⚠️ Partial certificate found in file %s: %s\n", p.Location, p.Reason))
+
+			for _, res := range results {
+				fmt.Println(imageHeading(res, imagesOpts.Containers(res.Name)))
+				if res.Err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", res.Err)
+					continue
 				}
-				fmt.Printf("Found %d partial certificates\n", len(parsedCertificates.Partials))
+				printInspect(analyser, res.Parsed)
 			}
 
-			return nil
+			return failedImages(results)
 		},
 	}
 
 	imgOpts = options.RegisterImage(cmd)
+	imagesOpts = options.RegisterImages(cmd)
 	analyseOpts = options.RegisterAnalyse(cmd)
-	cmd.Args = cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)
+	outOpts = options.RegisterReportOutputs(cmd)
 
 	return cmd
 }
+
+// printInspect prints the certificates found in an image which have issues,
+// followed by a summary of the trust in all the certificates.
+func printInspect(analyser *analyse.Analyser, parsedCertificates *certificate.ParsedCertificates) {
+	numIssues := 0
+	publicTrust := make(map[analyse.PublicTrust]int)
+	programmeStatus := make(map[analyse.ProgrammeMembership]int)
+	trust := make(map[certificate.Trust]int)
+	for _, cert := range parsedCertificates.Found {
+		if cert.Certificate == nil {
+			numIssues++
+			continue
+		}
+		publicTrust[analyser.PublicTrust(cert.Certificate)]++
+		trust[cert.Trust]++
+		for _, m := range analyse.Memberships(analyser.RootProgrammes, cert.Certificate) {
+			programmeStatus[m]++
+		}
+		notes := analyser.AnalyseFound(cert)
+		if len(notes) > 0 {
+			numIssues++
+			fingerprint := hex.EncodeToString(cert.FingerprintSha256[:])
+			fmt.Printf("Certificate %s, Fingerprint: %s\n", cert.Certificate.Subject, fingerprint)
+			printNotes(notes)
+		}
+	}
+	fmt.Printf("Found %d certificates total, of which %d had issues\n", len(parsedCertificates.Found), numIssues)
+	fmt.Printf("Of these, %d are trusted by the operating system, %d are distrusted, and %d are in files outside the trust store\n",
+		trust[certificate.TrustTrusted], trust[certificate.TrustDistrusted], trust[certificate.TrustUntrustedFile])
+	if len(analyser.IncludedCertificates) > 0 {
+		fmt.Printf("Of these, %d are publicly trusted, %d were removed, and %d are unknown to Mozilla's root store\n",
+			publicTrust[analyse.PublicTrustIncluded], publicTrust[analyse.PublicTrustRemoved], publicTrust[analyse.PublicTrustUnknown])
+	}
+	for _, p := range analyser.RootProgrammes {
+		fmt.Printf("In the %s root programme, %d are included, %d are distrusted, and %d are not included\n", p.Name,
+			programmeStatus[analyse.ProgrammeMembership{Programme: p.Name, Status: analyse.ProgrammeStatusIncluded}],
+			programmeStatus[analyse.ProgrammeMembership{Programme: p.Name, Status: analyse.ProgrammeStatusDistrusted}],
+			programmeStatus[analyse.ProgrammeMembership{Programme: p.Name, Status: analyse.ProgrammeStatusNotIncluded}])
+	}
+	for _, msg := range missingTrustVariableMessages(parsedCertificates) {
+		fmt.Print(color.New(color.FgYellow).Sprintf("⚠️ %s\n", msg))
+	}
+	if len(parsedCertificates.Partials) > 0 {
+		for _, p := range parsedCertificates.Partials {
+			fmtFn := color.New(color.FgYellow).SprintfFunc()
+			fmt.Print(fmtFn("⚠️ %s\n", partialMessage(p)))
+		}
+		fmt.Printf("Found %d partial certificates\n", len(parsedCertificates.Partials))
+	}
+	for _, key := range parsedCertificates.PrivateKeys {
+		if notes := analyser.AnalysePrivateKey(key); len(notes) > 0 {
+			fmt.Printf("Private key in file %s\n", key.Location)
+			printNotes(notes)
+		}
+	}
+	if len(parsedCertificates.PrivateKeys) > 0 {
+		fmt.Printf("Found %d private keys\n", len(parsedCertificates.PrivateKeys))
+	}
+}
+
+// printNotes prints each note in a tree, coloured by level.
+func printNotes(notes []analyse.Note) {
+	for i, n := range notes {
+		var lead string
+		if i == len(notes)-1 {
+			lead = "┗"
+		} else {
+			lead = "┣"
+		}
+		var fmtFn func(format string, a ...interface{}) string
+		var emoji string
+		if n.Level == analyse.NoteLevelError {
+			fmtFn = color.New(color.FgRed).SprintfFunc()
+			emoji = "🚨"
+		} else if n.Level == analyse.NoteLevelWarn {
+			fmtFn = color.New(color.FgYellow).SprintfFunc()
+			emoji = "⚠️"
+		}
+		fmt.Printf(lead + " " + fmtFn("%s %s [%s]\n", emoji, n.Reason, n.Rule))
+	}
+}
+
+func partialMessage(p certificate.Partial) string {
+	return fmt.Sprintf("Partial certificate found in file %s: %s", p.Location, p.Reason)
+}
+
+// inspectJUnit builds a JUnit report from the analysis of each certificate.
+// Every certificate becomes a test case, failing with the notes raised by the
+// analyser. Partial certificates and private keys are reported in their own
+// suites.
+func inspectJUnit(imageName string, analyser *analyse.Analyser, parsed *certificate.ParsedCertificates) *output.JUnitTestSuites {
+	report := &output.JUnitTestSuites{Name: "paranoia inspect " + imageName}
+
+	certificates := output.JUnitTestSuite{Name: "certificates"}
+	for _, cert := range parsed.Found {
+		fingerprint := hex.EncodeToString(cert.FingerprintSha256[:])
+		if cert.Certificate == nil {
+			certificates.TestCases = append(certificates.TestCases, output.JUnitTestCase{
+				Name:      fingerprint,
+				ClassName: cert.Location,
+				Failures:  []output.JUnitResult{{Message: "certificate could not be decoded", Type: string(analyse.NoteLevelError)}},
+			})
+			continue
+		}
+		tc := output.JUnitTestCase{
+			Name:      fmt.Sprintf("%s (%s)", cert.Certificate.Subject, fingerprint),
+			ClassName: cert.Location,
+		}
+		for _, n := range analyser.AnalyseFound(cert) {
+			tc.Failures = append(tc.Failures, output.JUnitResult{Message: n.Reason, Type: string(n.Level), Text: n.Reason})
+		}
+		certificates.TestCases = append(certificates.TestCases, tc)
+	}
+	report.AddSuite(certificates)
+
+	if len(parsed.Partials) > 0 {
+		partials := output.JUnitTestSuite{Name: "partials"}
+		for _, p := range parsed.Partials {
+			msg := partialMessage(p)
+			partials.TestCases = append(partials.TestCases, output.JUnitTestCase{
+				Name:      p.Location,
+				ClassName: p.Parser,
+				Failures:  []output.JUnitResult{{Message: msg, Type: string(analyse.NoteLevelWarn), Text: msg}},
+			})
+		}
+		report.AddSuite(partials)
+	}
+
+	if len(parsed.TrustVariables) > 0 {
+		variables := output.JUnitTestSuite{Name: "trust variables"}
+		for _, v := range parsed.TrustVariables {
+			tc := output.JUnitTestCase{Name: v.Name + "=" + v.Path, ClassName: v.Name}
+			if !v.Exists {
+				msg := missingTrustVariableMessage(v)
+				tc.Failures = append(tc.Failures, output.JUnitResult{Message: msg, Type: string(analyse.NoteLevelWarn), Text: msg})
+			}
+			variables.TestCases = append(variables.TestCases, tc)
+		}
+		report.AddSuite(variables)
+	}
+
+	if len(parsed.PrivateKeys) > 0 {
+		keys := output.JUnitTestSuite{Name: "private keys"}
+		for _, key := range parsed.PrivateKeys {
+			tc := output.JUnitTestCase{Name: key.Location, ClassName: key.Parser}
+			for _, n := range analyser.AnalysePrivateKey(key) {
+				tc.Failures = append(tc.Failures, output.JUnitResult{Message: n.Reason, Type: string(n.Level), Text: n.Reason})
+			}
+			keys.TestCases = append(keys.TestCases, tc)
+		}
+		report.AddSuite(keys)
+	}
+
+	return report
+}
@@ -1,15 +1,83 @@
 package options
 
-import "github.com/spf13/cobra"
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
 
 // Analyse are options for configuring certificate analysis.
 type Analyse struct {
 	// MozillaRemovedCertsURL is the URL to fetch the Mozilla removed CA certificates list from.
 	MozillaRemovedCertsURL string `json:"mozilla_removed_certs_url"`
+
+	// MozillaRemovedCertsFile is a local file to read the Mozilla removed CA
+	// certificates list from. If set, the list is never downloaded.
+	MozillaRemovedCertsFile string `json:"mozilla_removed_certs_file"`
+
+	// MozillaIncludedCertsURL is the URL to fetch the Mozilla included CA
+	// certificates list from.
+	MozillaIncludedCertsURL string `json:"mozilla_included_certs_url"`
+
+	// MozillaIncludedCertsFile is a local file to read the Mozilla included CA
+	// certificates list from. If set, the list is never downloaded.
+	MozillaIncludedCertsFile string `json:"mozilla_included_certs_file"`
+
+	// UnknownCANoteLevel is the level of note raised for certificates unknown
+	// to Mozilla's root store. One of "none", "warn" or "error".
+	UnknownCANoteLevel string `json:"unknown_ca_note_level"`
+
+	// SuppressRules are the IDs of analysis rules which should not raise notes.
+	SuppressRules []string `json:"suppress_rules"`
+
+	// RootProgrammes are additional root programmes to compare certificates
+	// against, each in the form name=url or name=file.
+	RootProgrammes []string `json:"root_programmes"`
+
+	// DataCacheDir is the directory downloaded data files are cached in.
+	// Defaults to a directory under the user's cache directory.
+	DataCacheDir string `json:"data_cache_dir"`
+
+	// DataCacheTTL is how long a cached data file is used before it is
+	// downloaded again.
+	DataCacheTTL time.Duration `json:"data_cache_ttl"`
+
+	// Offline disables all network access. Cached data files are used
+	// regardless of their age, falling back to the snapshot built into
+	// Paranoia.
+	Offline bool `json:"offline"`
 }
 
 func RegisterAnalyse(cmd *cobra.Command) *Analyse {
 	var opts Analyse
+	registerData(cmd, &opts)
 	cmd.PersistentFlags().StringVar(&opts.MozillaRemovedCertsURL, "mozilla-removed-certs-url", "https://ccadb.my.salesforce-sites.com/mozilla/RemovedCACertificateReportCSVFormat", "URL to fetch Mozilla's removed CA certificate list from.")
+	cmd.PersistentFlags().StringVar(&opts.MozillaRemovedCertsFile, "mozilla-removed-certs-file", "", "Path to a local copy of Mozilla's removed CA certificate list, in CSV format. Overrides the URL.")
+	cmd.PersistentFlags().StringVar(&opts.MozillaIncludedCertsURL, "mozilla-included-certs-url", "https://ccadb.my.salesforce-sites.com/mozilla/IncludedCACertificateReportCSVFormat", "URL to fetch Mozilla's included CA certificate list from.")
+	cmd.PersistentFlags().StringVar(&opts.MozillaIncludedCertsFile, "mozilla-included-certs-file", "", "Path to a local copy of Mozilla's included CA certificate list, in CSV format. Overrides the URL.")
+	cmd.PersistentFlags().StringSliceVar(&opts.SuppressRules, "suppress-rule", nil, "IDs of analysis rules to suppress, such as long-validity. May be given multiple times, or as a comma separated list.")
+	cmd.PersistentFlags().StringVar(&opts.UnknownCANoteLevel, "unknown-ca-note-level", "warn", "Level of the note raised for root certificates unknown to Mozilla's root store, such as private certificate authorities. One of none, warn, or error.")
+	return &opts
+}
+
+// RegisterRootProgrammes registers only the options for comparing
+// certificates against root programmes, for commands which don't otherwise
+// analyse certificates.
+func RegisterRootProgrammes(cmd *cobra.Command) *Analyse {
+	var opts Analyse
+	registerData(cmd, &opts)
 	return &opts
 }
+
+func registerData(cmd *cobra.Command, opts *Analyse) {
+	cmd.PersistentFlags().StringArrayVar(&opts.RootProgrammes, "root-programme", nil, `
+A root programme to compare certificates against, in the form name=url or name=file, for example chrome=./chrome-root-store.csv.
+May be given multiple times.
+The file must be in CSV format, with a "SHA-256 Fingerprint" column.
+If it has a "Status" or "<name> Status" column, as in CCADB's all included roots report, certificates with a status containing "distrust", "removed", "revoked", "disabled" or "block" are distrusted.
+Otherwise, all listed certificates are included in the programme.
+`)
+	cmd.PersistentFlags().StringVar(&opts.DataCacheDir, "data-cache-dir", "", "Directory to cache downloaded data in. Defaults to a paranoia directory in the user cache directory.")
+	cmd.PersistentFlags().DurationVar(&opts.DataCacheTTL, "data-cache-ttl", 24*time.Hour, "How long cached data is used before it is downloaded again.")
+	cmd.PersistentFlags().BoolVar(&opts.Offline, "offline", false, "Never download data. Cached data is used regardless of age, falling back to the snapshot built into Paranoia.")
+}
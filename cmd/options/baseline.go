@@ -0,0 +1,33 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package options
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+// Baseline are options for choosing the baseline an image's trust store is
+// compared against.
+type Baseline struct {
+	// File is a baseline file, as written by "paranoia baseline create".
+	File string `json:"file"`
+
+	// Image is a reference image, whose trust store is used as the baseline.
+	Image string `json:"image"`
+}
+
+func RegisterBaseline(cmd *cobra.Command) *Baseline {
+	var opts Baseline
+	cmd.Flags().StringVar(&opts.File, "baseline", "", "Baseline file to compare against, as written by \"paranoia baseline create\".")
+	cmd.Flags().StringVar(&opts.Image, "baseline-image", "", "Reference image to compare against, such as the distribution's base image.")
+	return &opts
+}
+
+func (b *Baseline) Validate() error {
+	if (b.File == "") == (b.Image == "") {
+		return errors.New("exactly one of --baseline or --baseline-image is required")
+	}
+	return nil
+}
@@ -0,0 +1,18 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package options
+
+import "github.com/spf13/cobra"
+
+// Diff are options for configuring the diff command.
+type Diff struct {
+	// FailOnAddedTrustAnchor exits with a non-zero code if the second image
+	// adds any trust anchor.
+	FailOnAddedTrustAnchor bool `json:"failOnAddedTrustAnchor"`
+}
+
+func RegisterDiff(cmd *cobra.Command) *Diff {
+	var opts Diff
+	cmd.Flags().BoolVar(&opts.FailOnAddedTrustAnchor, "fail-on-added-trust-anchor", false, "Exit with a nonzero code if the second image adds a trust anchor, either a new root certificate or a changed one.")
+	return &opts
+}
@@ -3,6 +3,9 @@
 package options
 
 import (
+	"os"
+	"path/filepath"
+
 	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
@@ -15,6 +18,13 @@ type Image struct {
 	// Platform specifies the platform in the form
 	// os/arch[/variant][:osversion] (e.g. linux/amd64)
 	Platform string `json:"platform"`
+
+	// CacheDir is the directory the result of scanning each layer is cached
+	// in. Defaults to a paranoia directory in the user cache directory.
+	CacheDir string `json:"cacheDir"`
+
+	// NoCache scans every layer, without reading or writing cached results.
+	NoCache bool `json:"noCache"`
 }
 
 // Options converts the options to a slice of image.Options
@@ -29,12 +39,25 @@ func (i *Image) Options() ([]image.Option, error) {
 		opts = append(opts, image.WithPlatform(platform))
 	}
 
+	if !i.NoCache {
+		dir := i.CacheDir
+		if dir == "" {
+			// Without a user cache directory, layers are scanned every time.
+			if userCache, err := os.UserCacheDir(); err == nil {
+				dir = filepath.Join(userCache, "paranoia", "layers")
+			}
+		}
+		opts = append(opts, image.WithCacheDir(dir))
+	}
+
 	return opts, nil
 }
 
 // RegistryImage registers image options with cobra
 func RegisterImage(cmd *cobra.Command) *Image {
 	var opts Image
+	cmd.Flags().StringVar(&opts.CacheDir, "cache-dir", "", "Directory to cache the certificates found in each image layer in, so later scans only read new layers. Defaults to a paranoia directory in the user cache directory.")
+	cmd.Flags().BoolVar(&opts.NoCache, "no-cache", false, "Scan every image layer, without reading or writing cached results.")
 	cmd.Flags().StringVar(&opts.Platform, "platform", "", "Specifies the platform in the form os/arch[/variant][:osversion] (e.g. linux/amd64)")
 	return &opts
 }
@@ -0,0 +1,134 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package options
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/jetstack/paranoia/internal/manifests"
+)
+
+// Images are options for commands which scan many images in one invocation.
+type Images struct {
+	// ImagesFrom is a file of image references to scan, one per line, in
+	// addition to those given as arguments.
+	ImagesFrom string `json:"imagesFrom"`
+
+	// FromManifests is a directory or file of Kubernetes manifests, rendered
+	// Helm charts, or Docker Compose files, whose images are scanned in
+	// addition to those given as arguments.
+	FromManifests string `json:"fromManifests"`
+
+	// Parallelism is the number of images scanned at once.
+	Parallelism int `json:"parallelism"`
+
+	// containers are those found in the manifests, once Names has been called.
+	containers []manifests.Container
+}
+
+func RegisterImages(cmd *cobra.Command) *Images {
+	var opts Images
+	cmd.Flags().StringVar(&opts.ImagesFrom, "images-from", "", "Read image names to scan from a file, one per line. Blank lines and lines starting with # are ignored.")
+	cmd.Flags().StringVar(&opts.FromManifests, "from-manifests", "", "Scan the images used by the Kubernetes manifests, rendered Helm charts, or Docker Compose files in a directory.")
+	cmd.Flags().IntVar(&opts.Parallelism, "parallelism", 4, "Number of images to scan at once, when scanning many images.")
+	return &opts
+}
+
+// Names returns the images to scan, from the command arguments, the images
+// file, and the manifests, in that order. Each image is returned once, where
+// it was first given.
+func (i *Images) Names(args []string) ([]string, error) {
+	var names []string
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, arg := range args {
+		add(arg)
+	}
+
+	if i.ImagesFrom != "" {
+		f, err := os.Open(i.ImagesFrom)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			add(line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	if i.FromManifests != "" {
+		containers, warnings, err := manifests.Load(i.FromManifests)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load manifests")
+		}
+		for _, w := range warnings {
+			fmt.Fprintln(os.Stderr, "Warning: "+w)
+		}
+		i.containers = containers
+		for _, image := range manifests.Images(containers) {
+			add(image)
+		}
+	}
+
+	if len(names) == 0 {
+		if i.FromManifests != "" {
+			return nil, errors.Errorf("no images found in manifests in %s", i.FromManifests)
+		}
+		return nil, errors.New("expected at least one image name argument, --images-from, or --from-manifests")
+	}
+	stdin := 0
+	for _, name := range names {
+		if name == "-" {
+			stdin++
+		}
+	}
+	if stdin > 0 && len(names) > 1 {
+		return nil, errors.New("an image read from STDIN must be the only image scanned")
+	}
+
+	return names, nil
+}
+
+// Aggregate returns true if the report should cover many images, keyed by
+// image name and digest, rather than describing a single image.
+func (i *Images) Aggregate(names []string) bool {
+	return len(names) > 1 || i.ImagesFrom != "" || i.FromManifests != ""
+}
+
+// Containers returns the containers in the manifests which run the image.
+func (i *Images) Containers(name string) []manifests.Container {
+	var containers []manifests.Container
+	for _, c := range i.containers {
+		if c.Image == name {
+			containers = append(containers, c)
+		}
+	}
+	return containers
+}
+
+func (i *Images) Validate() error {
+	if i.Parallelism < 1 {
+		return errors.New("--parallelism must be at least 1")
+	}
+	return nil
+}
@@ -14,6 +14,10 @@ const (
 	OutputModeJSON   = "json"
 	OutputModeWide   = "wide"
 	OutputModePEM    = "pem"
+	OutputModeJUnit  = "junit"
+	// OutputModeMarkdown is a Markdown report, suitable for a pull request
+	// comment.
+	OutputModeMarkdown = "markdown"
 )
 
 var outputModes = []string{
@@ -23,19 +27,41 @@ var outputModes = []string{
 	OutputModePEM,
 }
 
+var reportOutputModes = []string{
+	OutputModePretty,
+	OutputModeJUnit,
+}
+
+var comparisonOutputModes = []string{
+	OutputModePretty,
+	OutputModeJSON,
+}
+
+var diffOutputModes = []string{
+	OutputModePretty,
+	OutputModeJSON,
+	OutputModeMarkdown,
+}
+
 // Output are options for configuring command outputs.
 type Output struct {
 	// Mode is the output format of the command. Defaults to "pretty".
 	Mode string `json:"format"`
+
+	// IncludePEM embeds the PEM encoding of each certificate in JSON output.
+	IncludePEM bool `json:"includePEM"`
+
+	// modes is the set of output modes supported by the command.
+	modes []string
 }
 
 func RegisterOutputs(cmd *cobra.Command) *Output {
-	var opts Output
+	opts := Output{modes: outputModes}
 	cmd.Flags().StringVarP(&opts.Mode, "output", "o", "pretty", `
 The output mode controls how Paranoia displays the data, and what data is shown.
 Supported modes are *pretty*, *wide*, *json*, and *pem*.
 
-*pretty*: Both certificates and partial certificates are output using a table to the terminal.
+*pretty*: Certificates, partial certificates, private keys, CRLs, and certificate requests are output using a table to the terminal.
 This includes the file location (in the container) and the subject line of the certificate.
 
 *wide*: Like pretty mode, this uses a table to format data.
@@ -43,22 +69,89 @@ Wide includes additional columns including the SHA256 and other information.
 
 *json*: The JSON output mode emits only JSON to STDOUT.
 Therefore, it is suitable for piping either to file or into programs that consume JSON text.
-The output format will include a "certificates" key containing an array of certificate objects.
-Each certificate object will have keys for "fileLocation", "owner", "parser", "signature", "notBefore", "notAfter", "fingerprintSHA1", and "fingerprintSHA256".
+The output format will include a "schemaVersion" key, and a "certificates" key containing an array of certificate objects.
+Each certificate object will have keys for "fileLocation", "owner", "issuer", "serialNumber", "parser", "classification", "signature", "signatureAlgorithm", "keyAlgorithm", "keySize", "notBefore", "notAfter", "spkiSHA256", "fingerprintSHA1", and "fingerprintSHA256".
+Where it could be determined, the "trust" key is one of "trusted", "distrusted", or "untrusted-file", describing whether the operating system's trust store includes the certificate.
+Certificates reachable through trust related environment variables in the image config, such as SSL_CERT_FILE, have a "trustVariables" key listing the variables.
+Where present in the certificate, the keys "subjectAltNames", "basicConstraints", "keyUsages", "extKeyUsages", "subjectKeyID", and "authorityKeyID" are also included.
+Certificates found in OpenSSL "TRUSTED CERTIFICATE" blocks also have a "trustSettings" key, with the extended key usages the certificate is "trusted" or "rejected" for, and its "alias".
+With *--json-include-pem*, each certificate object will also have a "pem" key.
 Optionally, the output will include a "partials" key containing an array of partial certificate objects.
 Partial certificate objects will have keys for "fileLocation", "reason", and "parser".
+Optionally, the output will include a "privateKeys" key containing an array of private key objects.
+Private key objects will have keys for "fileLocation", "parser", "format", and "encrypted".
+Where known, the keys "keyAlgorithm", "keySize", "spkiSHA256", and "matchingCertificates" are also included.
+Optionally, the output will include a "trustVariables" key containing an array of the paths given by trust related environment variables, with keys for "name", "path", "resolved", and "exists".
+Where the distribution of the image could be detected from its os-release file, the output will include an "operatingSystem" key with keys for "id", "versionID", and "prettyName".
+Certificates in files installed by an operating system package have a "package" key, with keys for "manager", "name", "version", and "modified".
+Optionally, the output will include "crls" and "certificateRequests" keys containing arrays of certificate revocation list and certificate signing request objects.
+CRL objects will have keys for "fileLocation", "parser", "issuer", "number", "signatureAlgorithm", "thisUpdate", "nextUpdate", "revokedCertificates", "authorityKeyID", and "fingerprintSHA256".
+Certificate request objects will have keys for "fileLocation", "parser", "subject", "signatureAlgorithm", "keyAlgorithm", "keySize", "subjectAltNames", "spkiSHA256", and "fingerprintSHA256".
 
 *pem*: Emits every certificate found in PEM format.
 In this output mode, partial certificates are omitted.
+`)
+	cmd.Flags().BoolVar(&opts.IncludePEM, "json-include-pem", false, "Embed the PEM encoding of each certificate in JSON output.")
+	return &opts
+}
+
+// RegisterReportOutputs registers the output options for commands which report
+// on issues found in an image, such as inspect and validate.
+func RegisterReportOutputs(cmd *cobra.Command) *Output {
+	opts := Output{modes: reportOutputModes}
+	cmd.Flags().StringVarP(&opts.Mode, "output", "o", "pretty", `
+The output mode controls how Paranoia reports any issues found.
+Supported modes are *pretty* and *junit*.
+
+*pretty*: Issues are printed to the terminal in a human-readable form.
+
+*junit*: Emits only JUnit XML to STDOUT, suitable for CI systems that display test reports.
+Each checked item becomes a test case, and each issue found becomes a test case failure.
+`)
+	return &opts
+}
+
+// RegisterComparisonOutputs registers the output options for commands which
+// compare the certificates in an image against another set of certificates.
+func RegisterComparisonOutputs(cmd *cobra.Command) *Output {
+	opts := Output{modes: comparisonOutputModes}
+	cmd.Flags().StringVarP(&opts.Mode, "output", "o", "pretty", `
+The output mode controls how Paranoia displays the differences found.
+Supported modes are *pretty* and *json*.
+
+*pretty*: Differences are output using a table to the terminal.
+
+*json*: The JSON output mode emits only JSON to STDOUT.
+The output format will include a "schemaVersion" key, and "added" and "removed" keys containing arrays of certificate objects.
+`)
+	return &opts
+}
+
+// RegisterDiffOutputs registers the output options for the diff command.
+func RegisterDiffOutputs(cmd *cobra.Command) *Output {
+	opts := Output{modes: diffOutputModes}
+	cmd.Flags().StringVarP(&opts.Mode, "output", "o", "pretty", `
+The output mode controls how Paranoia displays the changes between the images.
+Supported modes are *pretty*, *json*, and *markdown*.
+
+*pretty*: Changes are output using a table to the terminal.
+
+*json*: The JSON output mode emits only JSON to STDOUT.
+The output format will include a "schemaVersion" key, "before" and "after" keys naming the images, and a "changes" key containing an array of change objects.
+Each change object will have keys for "kind", one of "added", "removed", "moved", or "changed", and "subject".
+Changes have "before" and "after" keys, where applicable, with the "locations" of the certificate in that image and the "certificate" object itself.
+Changes which add a trust anchor also have an "addsTrustAnchor" key.
+
+*markdown*: Emits a Markdown report, suitable for a pull request comment.
 `)
 	return &opts
 }
 
 func (o *Output) Validate() error {
-	for _, m := range outputModes {
+	for _, m := range o.modes {
 		if o.Mode == m {
 			return nil
 		}
 	}
-	return fmt.Errorf("invalid output mode %q, must be one of %s", o.Mode, strings.Join(outputModes, ", "))
+	return fmt.Errorf("invalid output mode %q, must be one of %s", o.Mode, strings.Join(o.modes, ", "))
 }
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package options
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+// Policy are options for the policy commands.
+type Policy struct {
+	// Config is the filepath location of the validation configuration to
+	// write.
+	Config string `json:"config"`
+}
+
+func RegisterPolicy(cmd *cobra.Command) *Policy {
+	var opts Policy
+	cmd.Flags().StringVarP(&opts.Config, "config", "c", ".paranoia.yaml", "Path to the configuration file for Paranoia's validate mode.")
+	return &opts
+}
+
+// PolicyInit are options for generating a new config.
+type PolicyInit struct {
+	// Require are the subjects of the certificates to require, rather than
+	// allow.
+	Require []string `json:"require"`
+
+	// Force overwrites an existing config.
+	Force bool `json:"force"`
+}
+
+func RegisterPolicyInit(cmd *cobra.Command) *PolicyInit {
+	var opts PolicyInit
+	cmd.Flags().StringArrayVar(&opts.Require, "require", nil, "Subject of a certificate to require rather than allow, either the full distinguished name or the common name. May be given more than once.")
+	cmd.Flags().BoolVar(&opts.Force, "force", false, "Overwrite the configuration file if it already exists.")
+	return &opts
+}
+
+// PolicyUpdate are options for updating an existing config.
+type PolicyUpdate struct {
+	// PolicyCacheDir is the directory configs included from URLs and OCI
+	// artifacts are cached in.
+	PolicyCacheDir string `json:"policyCacheDir"`
+}
+
+// LoadOptions converts the options to a slice of validate.LoadOptions.
+func (u *PolicyUpdate) LoadOptions() []validate.LoadOption {
+	return loadOptions(u.PolicyCacheDir)
+}
+
+func RegisterPolicyUpdate(cmd *cobra.Command) *PolicyUpdate {
+	var opts PolicyUpdate
+	cmd.Flags().StringVar(&opts.PolicyCacheDir, "policy-cache-dir", "", "Directory to cache configs included from URLs and OCI artifacts in. Defaults to a paranoia directory in the user cache directory.")
+	return &opts
+}
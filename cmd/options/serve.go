@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package options
+
+import (
+	"errors"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Serve are options for configuring the scanning service.
+type Serve struct {
+	// Address is the address the server listens on.
+	Address string `json:"address"`
+
+	// Workers is the number of images scanned at once.
+	Workers int `json:"workers"`
+
+	// QueueSize is the number of scans which may wait for a worker.
+	QueueSize int `json:"queueSize"`
+
+	// CacheSize is the number of image digests whose scans are cached.
+	CacheSize int `json:"cacheSize"`
+
+	// JobRetention is how long finished scans can be polled for.
+	JobRetention time.Duration `json:"jobRetention"`
+
+	// MaxUploadSize is the largest image tarball accepted, in bytes.
+	MaxUploadSize int64 `json:"maxUploadSize"`
+}
+
+func RegisterServe(cmd *cobra.Command) *Serve {
+	var opts Serve
+	cmd.Flags().StringVar(&opts.Address, "address", ":8080", "Address the server listens on.")
+	cmd.Flags().IntVar(&opts.Workers, "workers", 2, "Number of images scanned at once.")
+	cmd.Flags().IntVar(&opts.QueueSize, "queue-size", 100, "Number of scans which may wait for a worker, beyond which new scans are rejected.")
+	cmd.Flags().IntVar(&opts.CacheSize, "cache-size", 1000, "Number of image digests whose scans are cached. Cached scans are validated again on each request.")
+	cmd.Flags().DurationVar(&opts.JobRetention, "job-retention", time.Hour, "How long the results of finished scans can be polled for.")
+	cmd.Flags().Int64Var(&opts.MaxUploadSize, "max-upload-size", 2<<30, "Largest image tarball accepted, in bytes.")
+	return &opts
+}
+
+func (s *Serve) Validate() error {
+	if s.Workers < 1 {
+		return errors.New("--workers must be at least 1")
+	}
+	if s.QueueSize < 1 {
+		return errors.New("--queue-size must be at least 1")
+	}
+	return nil
+}
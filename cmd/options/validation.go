@@ -1,6 +1,15 @@
+// SPDX-License-Identifier: Apache-2.0
+
 package options
 
-import "github.com/spf13/cobra"
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/jetstack/paranoia/internal/validate"
+)
 
 // Validation are options for configuring validation command.
 type Validation struct {
@@ -13,6 +22,37 @@ type Validation struct {
 	// Permissive allows any certificate that is not otherwise forbidden. This
 	// overrides the config's allow list.
 	Permissive bool `json:"permissive"`
+
+	// TrustedOnly applies the policy only to certificates trusted by the
+	// operating system's trust store.
+	TrustedOnly bool `json:"trustedOnly"`
+
+	// ExceptionWarningDays is how many days before an exception in the config
+	// expires to start warning about it.
+	ExceptionWarningDays int `json:"exceptionWarningDays"`
+
+	// PolicyCacheDir is the directory configs included from URLs and OCI
+	// artifacts are cached in. Defaults to a paranoia directory in the user
+	// cache directory.
+	PolicyCacheDir string `json:"policyCacheDir"`
+}
+
+// LoadOptions converts the options to a slice of validate.LoadOptions.
+func (v *Validation) LoadOptions() []validate.LoadOption {
+	return loadOptions(v.PolicyCacheDir)
+}
+
+// loadOptions returns the options for loading configs, caching included
+// configs in the given directory or the default.
+func loadOptions(dir string) []validate.LoadOption {
+	if dir == "" {
+		// Without a user cache directory, remote configs are fetched every
+		// time.
+		if userCache, err := os.UserCacheDir(); err == nil {
+			dir = filepath.Join(userCache, "paranoia", "policies")
+		}
+	}
+	return []validate.LoadOption{validate.WithPolicyCacheDir(dir)}
 }
 
 func RegisterValidation(cmd *cobra.Command) *Validation {
@@ -20,5 +60,8 @@ func RegisterValidation(cmd *cobra.Command) *Validation {
 	cmd.PersistentFlags().StringVarP(&opts.Config, "config", "c", ".paranoia.yaml", "Path to configuration file for Paranoia's validate mode.")
 	cmd.PersistentFlags().BoolVar(&opts.Quiet, "quiet", false, "Suppress nonzero exit code on validation failures.")
 	cmd.PersistentFlags().BoolVar(&opts.Permissive, "permissive", false, "Allow any certificate that is not otherwise forbidden. This overrides the config's allow list.")
+	cmd.PersistentFlags().BoolVar(&opts.TrustedOnly, "trusted-only", false, "Apply the policy only to certificates trusted by the operating system's trust store, ignoring stray certificate files and distrusted certificates.")
+	cmd.PersistentFlags().StringVar(&opts.PolicyCacheDir, "policy-cache-dir", "", "Directory to cache configs included from URLs and OCI artifacts in. Defaults to a paranoia directory in the user cache directory.")
+	cmd.PersistentFlags().IntVar(&opts.ExceptionWarningDays, "exception-warning-days", 14, "Warn about exceptions in the config which expire within this many days.")
 	return &opts
 }
@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package options
+
+import "github.com/spf13/cobra"
+
+// Webhook are options for configuring the admission webhook server.
+type Webhook struct {
+	// Port is the port the webhook server listens on.
+	Port int `json:"port"`
+
+	// CertDir is the directory containing the server's TLS certificate and
+	// key, named tls.crt and tls.key.
+	CertDir string `json:"certDir"`
+
+	// Path is the URL path the webhook is served on.
+	Path string `json:"path"`
+
+	// CacheSize is the number of image digests whose scans are cached.
+	CacheSize int `json:"cacheSize"`
+}
+
+func RegisterWebhook(cmd *cobra.Command) *Webhook {
+	var opts Webhook
+	cmd.Flags().IntVar(&opts.Port, "port", 9443, "Port the webhook server listens on.")
+	cmd.Flags().StringVar(&opts.CertDir, "cert-dir", "/tmp/k8s-webhook-server/serving-certs", "Directory containing the server's TLS certificate and key, named tls.crt and tls.key.")
+	cmd.Flags().StringVar(&opts.Path, "path", "/validate", "URL path the webhook is served on.")
+	cmd.Flags().IntVar(&opts.CacheSize, "cache-size", 1000, "Number of image digests whose scans are cached. Cached scans are validated again on each admission.")
+	return &opts
+}
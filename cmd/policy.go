@@ -0,0 +1,178 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+
+	"github.com/jetstack/paranoia/cmd/options"
+	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/policy"
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+func newPolicy(ctx context.Context) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "policy subcommand",
+		Short: "Write and maintain a validate configuration file from an image",
+		Long: `
+Writes the configuration file for "paranoia validate" from the certificates found in a container image, and keeps it up to date as the image changes.
+
+Certificates are identified by their SHA256 fingerprints, with their subjects as comments.
+The configuration file is edited in place, so comments and the order of entries are preserved.
+`,
+	}
+
+	cmd.AddCommand(newPolicyInit(ctx))
+	cmd.AddCommand(newPolicyUpdate(ctx))
+
+	return cmd
+}
+
+func newPolicyInit(ctx context.Context) *cobra.Command {
+	var (
+		imgOpts    *options.Image
+		policyOpts *options.Policy
+		initOpts   *options.PolicyInit
+	)
+
+	cmd := &cobra.Command{
+		Use:   "init [flags] image",
+		Short: "Write a configuration file allowing every certificate in an image",
+		Long: `
+Writes a version 2 configuration file with every certificate found in the given image in the allow list, for validating the image in strict mode.
+Certificates whose subjects are given with *--require* are added to the require list instead.
+An existing configuration file is only overwritten with *--force*.
+`,
+		Example: `
+Write a .paranoia.yaml allowing the certificates in an image, and requiring the ISRG Root X1 certificate:
+
+	$ paranoia policy init --require "ISRG Root X1" my-app:latest
+`,
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			return options.MustSingleImageArgs(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			imageName := args[0]
+
+			if _, err := os.Stat(policyOpts.Config); err == nil && !initOpts.Force {
+				return errors.Errorf("%s already exists, use --force to overwrite it or \"paranoia policy update\" to update it", policyOpts.Config)
+			}
+
+			iOpts, err := imgOpts.Options()
+			if err != nil {
+				return errors.Wrap(err, "constructing image options")
+			}
+
+			parsedCertificates, err := image.FindImageCertificates(ctx, imageName, iOpts...)
+			if err != nil {
+				return err
+			}
+
+			p, changes, err := policy.New(parsedCertificates.Found, initOpts.Require,
+				fmt.Sprintf("Certificates found in %s, written by \"paranoia policy init\".", imageName))
+			if err != nil {
+				return err
+			}
+			if err := p.WriteFile(policyOpts.Config); err != nil {
+				return errors.Wrap(err, "failed to write config")
+			}
+
+			fmt.Printf("Wrote %s, allowing %d and requiring %d certificates\n", policyOpts.Config, len(changes.Allowed), len(changes.Required))
+			return nil
+		},
+	}
+
+	imgOpts = options.RegisterImage(cmd)
+	policyOpts = options.RegisterPolicy(cmd)
+	initOpts = options.RegisterPolicyInit(cmd)
+	cmd.Args = cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)
+
+	return cmd
+}
+
+func newPolicyUpdate(ctx context.Context) *cobra.Command {
+	var (
+		imgOpts    *options.Image
+		policyOpts *options.Policy
+		updateOpts *options.PolicyUpdate
+	)
+
+	cmd := &cobra.Command{
+		Use:   "update [flags] image",
+		Short: "Update a configuration file with the certificates in an image",
+		Long: `
+Updates a configuration file with the certificates found in the given image.
+Certificates which the configuration doesn't allow are added to the allow list, unless they are forbidden, misplaced, or waived by an exception.
+Allow list entries which identify a certificate only by its fingerprint are pruned if the certificate is no longer found.
+Other entries, the require and forbid lists, and comments are left as they are.
+`,
+		Example: `
+Update .paranoia.yaml after rebuilding an image:
+
+	$ paranoia policy update my-app:latest
+`,
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			return options.MustSingleImageArgs(args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			imageName := args[0]
+
+			p, err := policy.Load(policyOpts.Config)
+			if err != nil {
+				return errors.Wrap(err, "failed to load config")
+			}
+			config, err := validate.LoadConfig(ctx, policyOpts.Config, updateOpts.LoadOptions()...)
+			if err != nil {
+				return errors.Wrap(err, "failed to load validator config")
+			}
+			validator, err := validate.NewValidator(*config, false)
+			if err != nil {
+				return errors.Wrap(err, "failed to initialise validator")
+			}
+
+			iOpts, err := imgOpts.Options()
+			if err != nil {
+				return errors.Wrap(err, "constructing image options")
+			}
+
+			parsedCertificates, err := image.FindImageCertificates(ctx, imageName, iOpts...)
+			if err != nil {
+				return err
+			}
+
+			changes, err := p.Update(validator, parsedCertificates.Found)
+			if err != nil {
+				return err
+			}
+			if changes.IsEmpty() {
+				fmt.Printf("%s is up to date\n", policyOpts.Config)
+				return nil
+			}
+			for _, entry := range changes.Allowed {
+				fmt.Printf("Allowed %s\n", entryName(entry))
+			}
+			for _, entry := range changes.Pruned {
+				fmt.Printf("Pruned %s, which is no longer found\n", entryName(entry))
+			}
+			if err := p.WriteFile(policyOpts.Config); err != nil {
+				return errors.Wrap(err, "failed to write config")
+			}
+
+			fmt.Printf("Updated %s, adding %d and pruning %d entries\n", policyOpts.Config, len(changes.Allowed), len(changes.Pruned))
+			return nil
+		},
+	}
+
+	imgOpts = options.RegisterImage(cmd)
+	policyOpts = options.RegisterPolicy(cmd)
+	updateOpts = options.RegisterPolicyUpdate(cmd)
+	cmd.Args = cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)
+
+	return cmd
+}
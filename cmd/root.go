@@ -28,6 +28,8 @@ Paranoia will detect certificate authorities in most cases, and is especially us
   Anything added into the container at runtime is not seen.
 - If a certificate is found, that doesn’t guarantee that the container will trust it as a certificate authority.
   It could, for example, be an unused leftover file.
+  Paranoia marks each certificate as trusted, distrusted, or in an untrusted file based on the operating system's trust store configuration,
+  but applications can be configured to use other certificate files.
 - It’s possible for an attacker to ‘hide’ a certificate authority from Paranoia (e.g., by encoding it in a format Paranoia doesn’t understand).
   In general Paranoia isn’t designed to defend against an adversary with supply chain write access intentionally sneaking obfuscated certificate authorities into container images.
 
@@ -62,10 +64,23 @@ To enable this behaviour, use "-" as the image name.
 	root.AddCommand(newExport(ctx))
 	root.AddCommand(newInspect(ctx))
 	root.AddCommand(newValidation(ctx))
+	root.AddCommand(newData(ctx))
+	root.AddCommand(newBaseline(ctx))
+	root.AddCommand(newDiff(ctx))
+	root.AddCommand(newWebhook(ctx))
+	root.AddCommand(newServe(ctx))
+	root.AddCommand(newPolicy(ctx))
 
 	return root
 }
 
+// printWarnings prints each warning to STDERR.
+func printWarnings(warnings []string) {
+	for _, w := range warnings {
+		fmt.Fprintln(os.Stderr, "Warning: "+w)
+	}
+}
+
 func Execute() {
 	ctx := signals.SetupSignalHandler()
 	if err := NewRoot(ctx).Execute(); err != nil {
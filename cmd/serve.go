@@ -0,0 +1,145 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"golang.org/x/sync/errgroup"
+
+	"github.com/jetstack/paranoia/cmd/options"
+	"github.com/jetstack/paranoia/internal/analyse"
+	"github.com/jetstack/paranoia/internal/server"
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+func newServe(ctx context.Context) *cobra.Command {
+	var (
+		imgOpts     *options.Image
+		analyseOpts *options.Analyse
+		valOpts     *options.Validation
+		serveOpts   *options.Serve
+	)
+
+	cmd := &cobra.Command{
+		Use:   "serve [flags]",
+		Short: "Serve an HTTP API for scanning container images",
+		Long: `
+Serves an HTTP API for scanning container images, so they can be scanned without installing Paranoia.
+
+Each scan is queued as a job, and scanned by one of a fixed number of workers.
+When the queue is full, new scans are rejected with 503 Service Unavailable until a worker is free.
+Scans are cached by image digest, so scanning an image again, even by another tag, only resolves its digest.
+Cached scans are validated again, so that expired exceptions and expiring certificates are reported.
+
+The API is:
+
+	POST /v1/scans                   Submit a scan, returning 202 Accepted and the job, with its URL in the Location header.
+	                                 The body is either {"image": "name"} as application/json, or an image tarball, as saved by docker save, as application/x-tar.
+	GET  /v1/scans/{id}              Poll a job. Its "status" is one of "queued", "running", "succeeded", or "failed".
+	                                 Once succeeded, its "result" has "export", "inspect", and "validate" keys.
+	GET  /v1/scans/{id}/export       The certificates found, as by export --output json.
+	GET  /v1/scans/{id}/inspect      The certificates and private keys with issues, each with its notes.
+	GET  /v1/scans/{id}/validate     The result of validating the certificates against the configuration file.
+	GET  /metrics                    Prometheus metrics, including scan durations and the number of certificates found.
+	GET  /healthz                    Health check.
+
+Validation results are only available if the configuration file exists, or --config is given.
+`,
+		Example: `
+Serve the API, and scan an image:
+
+	$ paranoia serve --address :8080 &
+	$ curl -s -X POST -H 'Content-Type: application/json' -d '{"image": "alpine:latest"}' localhost:8080/v1/scans
+	{"id":"3f1c...","status":"queued","image":"alpine:latest","created":"..."}
+	$ curl -s localhost:8080/v1/scans/3f1c.../validate
+
+Upload a locally built image:
+
+	$ docker save example.com/image:v0.1.0 | curl -s -X POST -H 'Content-Type: application/x-tar' --data-binary @- localhost:8080/v1/scans
+`,
+		Args: cobra.NoArgs,
+		PreRunE: func(_ *cobra.Command, _ []string) error {
+			return serveOpts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			iOpts, err := imgOpts.Options()
+			if err != nil {
+				return errors.Wrap(err, "constructing image options")
+			}
+
+			analyser, err := analyse.NewAnalyser(analyseOpts)
+			if err != nil {
+				return errors.Wrap(err, "failed to initialise analyser")
+			}
+			printWarnings(analyser.Warnings)
+
+			config := server.Config{
+				Analyser:      analyser,
+				ImageOptions:  iOpts,
+				Workers:       serveOpts.Workers,
+				QueueSize:     serveOpts.QueueSize,
+				CacheSize:     serveOpts.CacheSize,
+				JobRetention:  serveOpts.JobRetention,
+				MaxUploadSize: serveOpts.MaxUploadSize,
+			}
+
+			validateConfig, err := validate.LoadConfig(ctx, valOpts.Config, valOpts.LoadOptions()...)
+			switch {
+			case os.IsNotExist(err) && !cmd.Flags().Changed("config"):
+				fmt.Fprintf(os.Stderr, "No configuration file at %s, results won't include validation\n", valOpts.Config)
+			case err != nil:
+				return errors.Wrap(err, "failed to load validator config")
+			default:
+				config.Validator, err = validate.NewValidator(*validateConfig, valOpts.Permissive)
+				if err != nil {
+					return errors.Wrap(err, "failed to initialise validator")
+				}
+				if valOpts.TrustedOnly {
+					config.Filter = trustedOnly
+				}
+				fmt.Fprintf(os.Stderr, "Validating certificates with %s\n", config.Validator.DescribeConfig())
+			}
+
+			srv := server.New(config)
+			httpServer := &http.Server{
+				Addr:              serveOpts.Address,
+				Handler:           srv.Handler(),
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+
+			g, gctx := errgroup.WithContext(ctx)
+			g.Go(func() error {
+				srv.Run(gctx)
+				return nil
+			})
+			g.Go(func() error {
+				fmt.Fprintf(os.Stderr, "Serving on %s\n", serveOpts.Address)
+				if err := httpServer.ListenAndServe(); err != http.ErrServerClosed {
+					return err
+				}
+				return nil
+			})
+			g.Go(func() error {
+				<-gctx.Done()
+				shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+				defer cancel()
+				return httpServer.Shutdown(shutdownCtx)
+			})
+			return g.Wait()
+		},
+	}
+
+	imgOpts = options.RegisterImage(cmd)
+	analyseOpts = options.RegisterAnalyse(cmd)
+	valOpts = options.RegisterValidation(cmd)
+	serveOpts = options.RegisterServe(cmd)
+
+	return cmd
+}
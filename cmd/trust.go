@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// warnMissingTrustVariables prints a warning for each trust related
+// environment variable which points to a path that doesn't exist in the image.
+func warnMissingTrustVariables(parsed *certificate.ParsedCertificates) {
+	printWarnings(missingTrustVariableMessages(parsed))
+}
+
+func missingTrustVariableMessages(parsed *certificate.ParsedCertificates) []string {
+	var msgs []string
+	for _, v := range parsed.TrustVariables {
+		if !v.Exists {
+			msgs = append(msgs, missingTrustVariableMessage(v))
+		}
+	}
+	return msgs
+}
+
+func missingTrustVariableMessage(v certificate.TrustVariable) string {
+	return fmt.Sprintf("environment variable %s points to %s, which does not exist in the image", v.Name, v.Path)
+}
@@ -6,24 +6,29 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"reflect"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/spf13/cobra"
 
 	"github.com/jetstack/paranoia/cmd/options"
-	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/output"
 	"github.com/jetstack/paranoia/internal/validate"
 )
 
 func newValidation(ctx context.Context) *cobra.Command {
 	var (
-		imgOpts *options.Image
-		valOpts *options.Validation
+		imgOpts    *options.Image
+		imagesOpts *options.Images
+		valOpts    *options.Validation
+		outOpts    *options.Output
 	)
 
 	cmd := &cobra.Command{
-		Use:   "validate [flags] image",
+		Use:   "validate [flags] image...",
 		Short: "Validate that the certificates in a container image conform to a provided config",
 		Long: `
 Check certificates found in a given container image against policy in a configuration file.
@@ -53,26 +58,116 @@ The *--permissive* flag will disable this behaviour, and allow any certificate n
 Forbid a certificate.
 Paranoia will always error if it finds a forbidden certificate in a container image.
 
+### Effective trust
+
+Finding a certificate in a container image doesn't mean the operating system trusts it.
+Paranoia models the trust store of Debian, Ubuntu, and Alpine (update-ca-certificates and /etc/ca-certificates.conf),
+and of Red Hat derived and SUSE distributions (p11-kit anchors and blocklists).
+With *--trusted-only*, the policy applies only to certificates the trust store trusts,
+or which applications are configured to trust through environment variables in the image config such as SSL_CERT_FILE,
+ignoring stray certificate files and certificates which are disabled or blocklisted.
+
+### Forbid private keys
+
+If "forbidPrivateKeys" is set to true, Paranoia will error if it finds any PEM encoded private key in the container image.
+
 ## CONFIGURATION FILE
 
 The configuration file is a YAML formatted text file.
 By default Paranoia uses a file named .paranoia.yaml in the working directory, but the *--config* flag can be used to override this.
 
-This file should contain a "version" key at the root level.
-Presently this should be set to the string "1".
+This file should contain a "version" key at the root level, set to the string "1" or "2".
+Version 1 files identify certificates only by their fingerprints, and remain supported.
 Future versions of Paranoia may use different values for this key.
 
 Next it may contain the "require", "allow", and "forbid" keys.
 The behaviour of these keys is described above.
 Each of these keys is a list of certificate entries.
+It may also contain the boolean "forbidPrivateKeys" key.
 
 Each certificate entry may contain the key "comment" with any commentary about the certificate.
 It must contain a "fingerprints" key, with one of "sha1" or "sha256" containing the SHA1 or SHA256 fingerprint of the certificate respectively.
-If both SHA1 and SHA256 fingerprints are given, the SHA1 is ignored.`,
+If both SHA1 and SHA256 fingerprints are given, the SHA1 is ignored.
+
+### Version 2 matchers
+
+In version 2 files, a certificate entry may instead, or as well, identify certificates with these keys:
+
+- "spkiSHA256": the SHA256 hash of the certificate's public key, which is kept when a certificate authority re-issues its certificate.
+- "subject" and "issuer": a distinguished name, such as "CN=ISRG Root X1,O=Internet Security Research Group,C=US", matched exactly.
+- "subjectRegex" and "issuerRegex": a regular expression matched against the distinguished name.
+- "serial": the serial number in hex, optionally separated by colons.
+- "keyID": the subject key identifier in hex, optionally separated by colons.
+
+A certificate matches an entry only if it matches every key the entry sets.
+Issues list the matchers which identified the certificate.
+
+### Locations
+
+In version 2 files, a certificate entry may contain a "paths" key, with a list of absolute globs where "**" matches any number of directories.
+An allow or require entry with paths only allows (or is only satisfied by) certificates found in those locations,
+and a certificate which matches the entry elsewhere is reported as misplaced.
+A forbid entry with paths only forbids certificates found in those locations.
+
+The "pathRules" key is a list of rules, each with a "path" glob, an optional "comment", and an "allow" list of certificate entries.
+Any certificate found in a location matching the path is reported as misplaced unless it matches an entry in the rule's allow list.
+Entry paths and path rules apply in permissive mode too; an empty allow list permits no certificates under the path.
+
+### Rules
+
+In version 2 files, the "rules" key checks the properties of every certificate found, alongside the lists above.
+Each issue names the rule which was violated.
+
+- "expiresWithinDays": fail for certificates which have expired, or expire within this many days.
+- "minRSAKeySize": fail for RSA keys smaller than this many bits, such as 2048.
+- "forbidSHA1Signatures": fail for certificates signed with SHA-1.
+- "maxValidityYears": fail for certificates valid for longer than this many years, such as 25.
+- "forbidPartials": fail if any partial certificate, which looks like a certificate but couldn't be parsed, is found.
+
+### Exceptions
+
+In version 2 files, the "exceptions" key is a list of time-boxed waivers, such as for a vendor's CA while they fix their image.
+Each exception identifies certificates as a certificate entry does, with fingerprints, other matchers, and "paths".
+An exception with only "paths" waives everything found in those locations, including private keys and partial certificates.
+It must have an "owner" and an "expires" date, and may have a "ticket" reference and a "comment".
+
+Until it expires, issues with the certificates an exception matches don't fail validation, and are listed as waived instead.
+Once it expires, the issues fail validation again.
+Paranoia warns about exceptions which have expired, or expire within *--exception-warning-days* days.
+Exceptions cannot waive missing required certificates.
+
+### Includes
+
+In version 2 files, the "include" key is a list of other configs to build on, such as an organisation-wide baseline.
+Each include sets exactly one of "path", a file relative to the including config; "url", an HTTPS URL; or "oci", an OCI artifact reference.
+OCI artifacts hold the config in a layer of media type application/vnd.paranoia.policy.v1+yaml.
+An include may set "digest" to a sha256 digest, and fails if the config doesn't match it.
+For OCI artifacts the digest is of the artifact's manifest, and may instead be given in the reference.
+
+Included configs are merged in order, followed by the including config, with later configs taking precedence.
+Certificate entries, path rules, and exceptions are combined, checks enabled by any config stay enabled,
+and numeric rules are taken from the config with the highest precedence which sets them.
+Included configs may themselves include others, but remote configs cannot include local files,
+and every include of a pinned config must be pinned too, so that its contents can't change.
+
+Configs fetched from URLs and OCI artifacts are cached in *--policy-cache-dir*.
+Pinned configs are read from the cache once fetched, and unpinned configs fall back to the cache, with a warning, if they can't be fetched.
+Fetches time out after 30 seconds.
+Validation prints the resolved sources, in order of increasing precedence.
+
+## MANY IMAGES
+
+Many images can be validated against the same policy in one invocation, given as arguments or listed in a file with *--images-from*.
+The result for each image is introduced by its name and digest, and the exit code is non-zero if any image fails validation or couldn't be scanned.
+
+With *--from-manifests*, the images used by the Kubernetes manifests, rendered Helm charts and Docker Compose files in a directory are validated.
+Files which can't be parsed, such as Helm values files containing templates, are skipped with a warning.
+Each image is scanned once, and its result lists every workload and container which runs it.`,
 		Example: `
 An example configuration file: 
 
 	version: "1"
+	forbidPrivateKeys: true
 	require:
 	  - comment: "DigitCert Global Root"
 	    fingerprints:
@@ -86,19 +181,49 @@ An example configuration file:
 	    fingerprints:
 	      sha256: bd40be0eccfce513ab318882f03962e4e2ec3799b51392e82805d9249e426d28
 
+A version 2 configuration file, forbidding every certificate issued by an internal CA,
+allowing the internal CA only in one location, permitting no certificates under /app,
+checking the expiry, key size, signature algorithm, and validity period of every certificate,
+and waiving a vendor's CA until the end of January 2025:
+
+	version: "2"
+	forbid:
+	  - comment: "Issued by the internal CA"
+	    issuerRegex: "^CN=Internal .*,O=Example"
+	allow:
+	  - comment: "The internal CA, only in the system trust store"
+	    subject: "CN=Internal Root CA,O=Example"
+	    paths: ["/etc/ssl/certs/internal-ca.pem"]
+	pathRules:
+	  - comment: "No certificates may be bundled with the app"
+	    path: "/app/**"
+	rules:
+	  expiresWithinDays: 30
+	  minRSAKeySize: 2048
+	  forbidSHA1Signatures: true
+	  maxValidityYears: 25
+	  forbidPartials: true
+	exceptions:
+	  - comment: "Vendor CA, until they fix their image"
+	    owner: "payments-team"
+	    ticket: "SEC-123"
+	    expires: 2030-01-31
+	    fingerprints:
+	      sha256: bd40be0eccfce513ab318882f03962e4e2ec3799b51392e82805d9249e426d28
+
 Validating a locally built image, using the implicit .paranoia.yaml configuration file:
 
 	$ docker build . -t example.com/image:v0.1.0
 	$ docker save example.com/image:v0.1.0 | paranoia validate -
 `,
 		PreRunE: func(_ *cobra.Command, args []string) error {
-			if err := options.MustSingleImageArgs(args); err != nil {
+			if err := imagesOpts.Validate(); err != nil {
 				return err
 			}
-			return nil
+			return outOpts.Validate()
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			validateConfig, err := validate.LoadConfig(valOpts.Config)
+			validateConfig, err := validate.LoadConfig(ctx, valOpts.Config, valOpts.LoadOptions()...)
 			if err != nil {
 				return errors.Wrap(err, "failed to load validator config")
 			}
@@ -107,79 +232,409 @@ Validating a locally built image, using the implicit .paranoia.yaml configuratio
 			if err != nil {
 				return errors.Wrap(err, "failed to initialise validator")
 			}
-			fmt.Println("Validating certificates with " + validator.DescribeConfig())
+			if outOpts.Mode != options.OutputModeJUnit {
+				if len(validateConfig.Sources) > 1 {
+					fmt.Println("Resolved policy sources, in order of increasing precedence:")
+					for _, source := range validateConfig.Sources {
+						fmt.Println("  " + source.String())
+					}
+				}
+				fmt.Println("Validating certificates with " + validator.DescribeConfig())
+			}
+			warnExpiringExceptions(validator, valOpts.ExceptionWarningDays)
 
-			imageName := args[0]
+			names, err := imagesOpts.Names(args)
+			if err != nil {
+				return err
+			}
 
 			iOpts, err := imgOpts.Options()
 			if err != nil {
 				return errors.Wrap(err, "constructing image options")
 			}
 
-			// Validate operates only on full certificates, and ignores partials.
-			parsedCertificates, err := image.FindImageCertificates(context.TODO(), imageName, iOpts...)
+			// Partial certificates are only checked by the forbidPartials rule.
+			results, err := scanImages(ctx, names, imagesOpts, iOpts)
 			if err != nil {
 				return err
 			}
 
-			validateRes, err := validator.Validate(parsedCertificates.Found)
-			if err != nil {
-				return err
-			}
+			aggregate := imagesOpts.Aggregate(names)
+			report := &output.JUnitTestSuites{Name: "paranoia validate"}
+			passed := true
+			for _, res := range results {
+				if aggregate && outOpts.Mode != options.OutputModeJUnit {
+					fmt.Println(imageHeading(res, imagesOpts.Containers(res.Name)))
+				}
+				if res.Err != nil {
+					fmt.Fprintf(os.Stderr, "error: %v\n", res.Err)
+					report.AddSuite(scanFailureSuite(res))
+					passed = false
+					continue
+				}
+
+				parsedCertificates := res.Parsed
+				warnMissingTrustVariables(parsedCertificates)
 
-			if validateRes.IsPass() {
-				fmt.Printf("Scanned %d certificates in image %s, no issues found.\n", len(parsedCertificates.Found), imageName)
-			} else {
-				fmt.Printf("Scanned %d certificates in image %s, found issues.\n", len(parsedCertificates.Found), imageName)
-				for _, na := range validateRes.NotAllowedCertificates {
-					fmt.Printf("Certificate with SHA256 fingerprint %X in location %s was not allowed\n", na.FingerprintSha256, na.Location)
+				if valOpts.TrustedOnly {
+					parsedCertificates = trustedOnly(parsedCertificates)
 				}
-				for _, f := range validateRes.ForbiddenCertificates {
-					sb := strings.Builder{}
-					sb.WriteString("Certificate with ")
-					if f.Entry.Fingerprints.Sha1 != "" {
-						sb.WriteString(fmt.Sprintf("SHA1 %X", f.Certificate.FingerprintSha1))
-					} else if f.Entry.Fingerprints.Sha256 != "" {
-						sb.WriteString(fmt.Sprintf("SHA256 %X", f.Certificate.FingerprintSha256))
-					}
-					sb.WriteString(fmt.Sprintf(" in location %s was forbidden!", f.Certificate.Location))
-					if f.Entry.Comment != "" {
-						sb.WriteString(" Comment: ")
-						sb.WriteString(f.Entry.Comment)
-					} else {
-						sb.WriteString(" No comment was provided.")
-					}
-					fmt.Println(sb.String())
+
+				validateRes, err := validator.ValidateParsed(parsedCertificates)
+				if err != nil {
+					return err
 				}
-				for _, req := range validateRes.RequiredButAbsent {
-					sb := strings.Builder{}
-					sb.WriteString("Certificate with ")
-					if req.Fingerprints.Sha1 != "" {
-						sb.WriteString(fmt.Sprintf("SHA1 %s", req.Fingerprints.Sha1))
-					} else if req.Fingerprints.Sha256 != "" {
-						sb.WriteString(fmt.Sprintf("SHA256 %s", req.Fingerprints.Sha256))
-					}
-					sb.WriteString(" was required, but was not found")
-					if req.Comment != "" {
-						sb.WriteString(" Comment: ")
-						sb.WriteString(req.Comment)
-					} else {
-						sb.WriteString(" No comment was provided.")
-					}
-					fmt.Println(sb.String())
+				passed = passed && validateRes.IsPass()
+
+				if outOpts.Mode != options.OutputModeJUnit {
+					printValidation(res.Name, parsedCertificates, validateRes)
+				} else if aggregate {
+					report.AddReport(res.Name+"@"+res.Digest, validateJUnit(res.Name, validator, *validateConfig, validateRes))
+				} else {
+					report = validateJUnit(res.Name, validator, *validateConfig, validateRes)
 				}
-				if !valOpts.Quiet {
-					os.Exit(1)
+			}
+
+			if outOpts.Mode == options.OutputModeJUnit {
+				if err := report.Write(os.Stdout); err != nil {
+					return errors.Wrap(err, "failed to write JUnit report")
 				}
 			}
 
+			if !passed && !valOpts.Quiet {
+				os.Exit(1)
+			}
+
 			return nil
 		},
 	}
 
 	imgOpts = options.RegisterImage(cmd)
+	imagesOpts = options.RegisterImages(cmd)
 	valOpts = options.RegisterValidation(cmd)
-	cmd.Args = cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs)
+	outOpts = options.RegisterReportOutputs(cmd)
 
 	return cmd
 }
+
+// printValidation prints the result of validating the certificates in an
+// image, with a message for each issue found.
+func printValidation(imageName string, parsedCertificates *certificate.ParsedCertificates, validateRes validate.Result) {
+	if validateRes.IsPass() {
+		fmt.Printf("Scanned %d certificates in image %s, no issues found.\n", len(parsedCertificates.Found), imageName)
+		printWaivers(validateRes.Waived)
+		return
+	}
+
+	fmt.Printf("Scanned %d certificates in image %s, found issues.\n", len(parsedCertificates.Found), imageName)
+	for _, na := range validateRes.NotAllowedCertificates {
+		fmt.Println(notAllowedMessage(na))
+	}
+	for _, f := range validateRes.ForbiddenCertificates {
+		fmt.Println(forbiddenMessage(f))
+	}
+	for _, m := range validateRes.MisplacedCertificates {
+		fmt.Println(misplacedMessage(m))
+	}
+	for _, req := range validateRes.RequiredButAbsent {
+		fmt.Println(requiredButAbsentMessage(req))
+	}
+	for _, key := range validateRes.ForbiddenPrivateKeys {
+		fmt.Println(forbiddenPrivateKeyMessage(key))
+	}
+	for _, rv := range validateRes.RuleViolations {
+		fmt.Println(ruleViolationMessage(rv))
+	}
+	printWaivers(validateRes.Waived)
+}
+
+// printWaivers lists the issues waived by exceptions, so that they aren't
+// forgotten about.
+func printWaivers(waived []validate.WaivedIssue) {
+	if len(waived) == 0 {
+		return
+	}
+	fmt.Printf("Waived %d issues with exceptions:\n", len(waived))
+	for _, w := range waived {
+		fmt.Println(waivedMessage(w))
+	}
+}
+
+// warnExpiringExceptions warns about exceptions which have expired, and so
+// are no longer honoured, or will expire within the given number of days.
+func warnExpiringExceptions(validator *validate.Validator, days int) {
+	expiring, expired := validator.ExpiringExceptions(time.Duration(days) * 24 * time.Hour)
+	for _, e := range expired {
+		fmt.Fprintf(os.Stderr, "Warning: %s has expired and is no longer honoured\n", e)
+	}
+	for _, e := range expiring {
+		fmt.Fprintf(os.Stderr, "Warning: %s expires soon, after which it will no longer be honoured\n", e)
+	}
+}
+
+// trustedOnly returns a copy of the parsed certificates with only the
+// certificates trusted by the operating system, or through environment
+// variables in the image config.
+func trustedOnly(parsed *certificate.ParsedCertificates) *certificate.ParsedCertificates {
+	filtered := *parsed
+	filtered.Found = nil
+	for _, found := range parsed.Found {
+		if found.Trust == certificate.TrustTrusted || len(found.TrustVariables) > 0 {
+			filtered.Found = append(filtered.Found, found)
+		}
+	}
+	return &filtered
+}
+
+// notAllowedName names the test case of a certificate which isn't allowed, by
+// its subject, or its fingerprint if it has none, and its location.
+func notAllowedName(na certificate.Found) string {
+	name := fmt.Sprintf("SHA256 %X", na.FingerprintSha256)
+	if na.Certificate != nil && na.Certificate.Subject.String() != "" {
+		name = na.Certificate.Subject.String()
+	}
+	return fmt.Sprintf("%s in %s is allowed", name, na.Location)
+}
+
+func notAllowedMessage(na certificate.Found) string {
+	return fmt.Sprintf("Certificate with SHA256 fingerprint %X in location %s was not allowed", na.FingerprintSha256, na.Location)
+}
+
+func forbiddenMessage(f validate.ForbiddenCert) string {
+	sb := strings.Builder{}
+	sb.WriteString("Certificate with ")
+	if f.Entry.Fingerprints.Sha1 != "" {
+		sb.WriteString(fmt.Sprintf("SHA1 %X", f.Certificate.FingerprintSha1))
+	} else {
+		sb.WriteString(fmt.Sprintf("SHA256 %X", f.Certificate.FingerprintSha256))
+	}
+	sb.WriteString(fmt.Sprintf(" in location %s was forbidden!", f.Certificate.Location))
+	if !f.Entry.IsFingerprintOnly() {
+		sb.WriteString(" Matched by ")
+		sb.WriteString(strings.Join(f.Matchers(), ", "))
+		sb.WriteString(".")
+	}
+	if f.Entry.Comment != "" {
+		sb.WriteString(" Comment: ")
+		sb.WriteString(f.Entry.Comment)
+	} else {
+		sb.WriteString(" No comment was provided.")
+	}
+	return sb.String()
+}
+
+func misplacedMessage(m validate.MisplacedCert) string {
+	sb := strings.Builder{}
+	sb.WriteString(fmt.Sprintf("Certificate with SHA256 %X in location %s was misplaced!", m.Certificate.FingerprintSha256, m.Certificate.Location))
+	var comment string
+	if m.Entry != nil {
+		sb.WriteString(fmt.Sprintf(" Certificates with %s are only allowed in %s.", strings.Join(m.Entry.Matchers(), ", "), strings.Join(m.Entry.Paths, ", ")))
+		comment = m.Entry.Comment
+	} else {
+		sb.WriteString(fmt.Sprintf(" Only allowed certificates may be found in %s.", m.Rule.Path))
+		comment = m.Rule.Comment
+	}
+	if comment != "" {
+		sb.WriteString(" Comment: ")
+		sb.WriteString(comment)
+	} else {
+		sb.WriteString(" No comment was provided.")
+	}
+	return sb.String()
+}
+
+func requiredButAbsentMessage(req validate.CertificateEntry) string {
+	sb := strings.Builder{}
+	sb.WriteString("Certificate with ")
+	sb.WriteString(strings.Join(req.Matchers(), ", "))
+	sb.WriteString(" was required, but was not found")
+	if req.Comment != "" {
+		sb.WriteString(" Comment: ")
+		sb.WriteString(req.Comment)
+	} else {
+		sb.WriteString(" No comment was provided.")
+	}
+	return sb.String()
+}
+
+func forbiddenPrivateKeyMessage(key certificate.PrivateKey) string {
+	return fmt.Sprintf("Private key in location %s was forbidden!", key.Location)
+}
+
+func ruleViolationMessage(rv validate.RuleViolation) string {
+	if rv.Certificate == nil {
+		return fmt.Sprintf("Partial certificate in location %s violated rule %s: %s", rv.Location, rv.Rule, rv.Reason)
+	}
+	return fmt.Sprintf("Certificate with SHA256 %X in location %s violated rule %s: %s", rv.Certificate.FingerprintSha256, rv.Location, rv.Rule, rv.Reason)
+}
+
+func waivedMessage(w validate.WaivedIssue) string {
+	subject := "Partial certificate"
+	if w.Issue == validate.IssuePrivateKey {
+		subject = "Private key"
+	} else if w.Certificate != nil {
+		subject = fmt.Sprintf("Certificate with SHA256 %X", w.Certificate.FingerprintSha256)
+	}
+	return fmt.Sprintf("%s in location %s %s, but this was waived by %s", subject, w.Location, issueVerb(w.Issue), w.Exception)
+}
+
+// issueVerb returns the issue as it reads after the subject of a message, as
+// in "... was forbidden" or "... violated rule minRSAKeySize".
+func issueVerb(issue string) string {
+	switch {
+	case strings.HasPrefix(issue, validate.IssueRule):
+		return issue
+	case issue == validate.IssuePrivateKey:
+		return "was forbidden"
+	}
+	return "was " + issue
+}
+
+// entryName returns a short name for a config entry, suitable for naming a
+// JUnit test case.
+func entryName(entry validate.CertificateEntry) string {
+	name := strings.Join(entry.Matchers(), ", ")
+	if entry.Comment != "" {
+		name = entry.Comment + " (" + name + ")"
+	}
+	return name
+}
+
+// misplacedFailures returns a failure for each certificate matching the allow
+// or require entry which was found outside of the entry's paths.
+func misplacedFailures(res validate.Result, entry validate.CertificateEntry) []output.JUnitResult {
+	var failures []output.JUnitResult
+	for _, m := range res.MisplacedCertificates {
+		if m.Entry != nil && reflect.DeepEqual(*m.Entry, entry) {
+			msg := misplacedMessage(m)
+			failures = append(failures, output.JUnitResult{Message: msg, Type: "misplaced", Text: msg})
+		}
+	}
+	return failures
+}
+
+// validateJUnit builds a JUnit report from a validation result. Each policy
+// entry becomes a test case, failing with the same messages as are printed in
+// pretty mode.
+func validateJUnit(imageName string, validator *validate.Validator, config validate.Config, res validate.Result) *output.JUnitTestSuites {
+	report := &output.JUnitTestSuites{Name: "paranoia validate " + imageName}
+
+	require := output.JUnitTestSuite{Name: "require"}
+	for _, entry := range config.Require {
+		tc := output.JUnitTestCase{Name: entryName(entry), ClassName: "require"}
+		for _, req := range res.RequiredButAbsent {
+			if reflect.DeepEqual(req, entry) {
+				msg := requiredButAbsentMessage(req)
+				tc.Failures = append(tc.Failures, output.JUnitResult{Message: msg, Type: "required", Text: msg})
+			}
+		}
+		tc.Failures = append(tc.Failures, misplacedFailures(res, entry)...)
+		require.TestCases = append(require.TestCases, tc)
+	}
+	report.AddSuite(require)
+
+	allow := output.JUnitTestSuite{Name: "allow"}
+	for _, entry := range config.Allow {
+		allow.TestCases = append(allow.TestCases, output.JUnitTestCase{Name: entryName(entry), ClassName: "allow", Failures: misplacedFailures(res, entry)})
+	}
+	// Each certificate which isn't allowed is its own failing test case, so
+	// that they can be tracked separately. Otherwise a single test case
+	// records that only allowed certificates are present.
+	switch {
+	case validator.IsPermissive():
+		allow.TestCases = append(allow.TestCases, output.JUnitTestCase{
+			Name:      "only allowed certificates are present",
+			ClassName: "allow",
+			Skipped:   &output.JUnitResult{Message: "permissive mode"},
+		})
+	case len(res.NotAllowedCertificates) == 0:
+		allow.TestCases = append(allow.TestCases, output.JUnitTestCase{Name: "only allowed certificates are present", ClassName: "allow"})
+	}
+	for _, na := range res.NotAllowedCertificates {
+		msg := notAllowedMessage(na)
+		allow.TestCases = append(allow.TestCases, output.JUnitTestCase{
+			Name:      notAllowedName(na),
+			ClassName: "allow",
+			Failures:  []output.JUnitResult{{Message: msg, Type: "not-allowed", Text: msg}},
+		})
+	}
+	report.AddSuite(allow)
+
+	forbid := output.JUnitTestSuite{Name: "forbid"}
+	for _, entry := range config.Forbid {
+		tc := output.JUnitTestCase{Name: entryName(entry), ClassName: "forbid"}
+		for _, f := range res.ForbiddenCertificates {
+			if reflect.DeepEqual(f.Entry, entry) {
+				msg := forbiddenMessage(f)
+				tc.Failures = append(tc.Failures, output.JUnitResult{Message: msg, Type: "forbidden", Text: msg})
+			}
+		}
+		forbid.TestCases = append(forbid.TestCases, tc)
+	}
+	report.AddSuite(forbid)
+
+	if len(config.PathRules) > 0 {
+		paths := output.JUnitTestSuite{Name: "paths"}
+		for _, rule := range config.PathRules {
+			name := "only allowed certificates are in " + rule.Path
+			if rule.Comment != "" {
+				name = rule.Comment + " (" + name + ")"
+			}
+			tc := output.JUnitTestCase{Name: name, ClassName: "pathRules"}
+			for _, m := range res.MisplacedCertificates {
+				if m.Rule != nil && reflect.DeepEqual(*m.Rule, rule) {
+					msg := misplacedMessage(m)
+					tc.Failures = append(tc.Failures, output.JUnitResult{Message: msg, Type: "misplaced", Text: msg})
+				}
+			}
+			paths.TestCases = append(paths.TestCases, tc)
+		}
+		report.AddSuite(paths)
+	}
+
+	if enabled := config.Rules.Enabled(); len(enabled) > 0 {
+		rules := output.JUnitTestSuite{Name: "rules"}
+		for _, rule := range enabled {
+			tc := output.JUnitTestCase{Name: rule, ClassName: "rules"}
+			for _, rv := range res.RuleViolations {
+				if rv.Rule == rule {
+					msg := ruleViolationMessage(rv)
+					tc.Failures = append(tc.Failures, output.JUnitResult{Message: msg, Type: "rule", Text: msg})
+				}
+			}
+			rules.TestCases = append(rules.TestCases, tc)
+		}
+		report.AddSuite(rules)
+	}
+
+	if len(config.Exceptions) > 0 {
+		exceptions := output.JUnitTestSuite{Name: "exceptions"}
+		for _, e := range config.Exceptions {
+			tc := output.JUnitTestCase{Name: e.String(), ClassName: "exceptions"}
+			var waived []string
+			for _, w := range res.Waived {
+				if reflect.DeepEqual(w.Exception, e) {
+					waived = append(waived, waivedMessage(w))
+				}
+			}
+			if len(waived) > 0 {
+				tc.Skipped = &output.JUnitResult{Message: fmt.Sprintf("waived %d issues", len(waived)), Text: strings.Join(waived, "\n")}
+			}
+			exceptions.TestCases = append(exceptions.TestCases, tc)
+		}
+		report.AddSuite(exceptions)
+	}
+
+	if config.ForbidPrivateKeys {
+		keys := output.JUnitTestSuite{Name: "private keys"}
+		tc := output.JUnitTestCase{Name: "no private keys are present", ClassName: "forbidPrivateKeys"}
+		for _, key := range res.ForbiddenPrivateKeys {
+			msg := forbiddenPrivateKeyMessage(key)
+			tc.Failures = append(tc.Failures, output.JUnitResult{Message: msg, Type: "forbidden", Text: msg})
+		}
+		keys.TestCases = append(keys.TestCases, tc)
+		report.AddSuite(keys)
+	}
+
+	return report
+}
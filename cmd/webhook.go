@@ -0,0 +1,117 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/jetstack/paranoia/cmd/options"
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/validate"
+	paranoiawebhook "github.com/jetstack/paranoia/internal/webhook"
+)
+
+func newWebhook(ctx context.Context) *cobra.Command {
+	var (
+		imgOpts     *options.Image
+		valOpts     *options.Validation
+		webhookOpts *options.Webhook
+	)
+
+	cmd := &cobra.Command{
+		Use:   "webhook [flags]",
+		Short: "Serve a Kubernetes validating admission webhook",
+		Long: `
+Serves a Kubernetes validating admission webhook, which denies Pods and workloads running images that fail validation.
+
+The images of every container, init container, and ephemeral container are validated against the configuration file, as by the validate command.
+The configuration file is usually mounted into the webhook's Pod from a ConfigMap.
+Each image is scanned once, and the certificates found cached by its digest, so admitting further Pods which run the same image only resolves its digest.
+The *--cache-size* most recently used digests are cached, and concurrent admissions of the same digest share one scan.
+The cached certificates are validated again for each admission, so that expired exceptions and expiring certificates are denied.
+A denied request lists the fingerprints of the certificates which were forbidden, not allowed, or required but absent.
+Images which aren't registry references, such as file:// tarballs, are denied without being read.
+If an image can't be resolved or scanned, the request fails, and the webhook configuration's failure policy applies.
+
+The server is served over TLS, using the tls.crt and tls.key files in the certificate directory, and reloads them when they change.
+`,
+		Example: `
+Serve the webhook, with the configuration and certificates mounted into the Pod:
+
+	$ paranoia webhook --config /etc/paranoia/.paranoia.yaml --cert-dir /etc/paranoia/tls
+
+Register it for Pods with a ValidatingWebhookConfiguration:
+
+	webhooks:
+	  - name: paranoia.jetstack.io
+	    rules:
+	      - apiGroups: [""]
+	        apiVersions: ["v1"]
+	        operations: ["CREATE", "UPDATE"]
+	        resources: ["pods"]
+	    clientConfig:
+	      service:
+	        name: paranoia
+	        namespace: paranoia
+	        path: /validate
+	    admissionReviewVersions: ["v1"]
+	    sideEffects: None
+	    timeoutSeconds: 30
+	    failurePolicy: Fail
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			log.SetLogger(logr.FromSlogHandler(slog.NewTextHandler(os.Stderr, nil)))
+
+			validateConfig, err := validate.LoadConfig(ctx, valOpts.Config, valOpts.LoadOptions()...)
+			if err != nil {
+				return errors.Wrap(err, "failed to load validator config")
+			}
+
+			validator, err := validate.NewValidator(*validateConfig, valOpts.Permissive)
+			if err != nil {
+				return errors.Wrap(err, "failed to initialise validator")
+			}
+
+			iOpts, err := imgOpts.Options()
+			if err != nil {
+				return errors.Wrap(err, "constructing image options")
+			}
+
+			var filter func(*certificate.ParsedCertificates) *certificate.ParsedCertificates
+			if valOpts.TrustedOnly {
+				filter = trustedOnly
+			}
+
+			server := webhook.NewServer(webhook.Options{
+				Port:    webhookOpts.Port,
+				CertDir: webhookOpts.CertDir,
+			})
+			server.Register(webhookOpts.Path, &webhook.Admission{
+				Handler: paranoiawebhook.NewHandler(validator, filter, webhookOpts.CacheSize, iOpts...),
+			})
+			server.Register("/healthz", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			fmt.Fprintf(os.Stderr, "Validating images with %s\n", validator.DescribeConfig())
+			return server.Start(ctx)
+		},
+	}
+
+	imgOpts = options.RegisterImage(cmd)
+	valOpts = options.RegisterValidation(cmd)
+	webhookOpts = options.RegisterWebhook(cmd)
+
+	return cmd
+}
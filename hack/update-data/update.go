@@ -0,0 +1,25 @@
+// SPDX-License-Identifier: Apache-2.0
+package main
+
+import (
+	"context"
+	"log"
+
+	"github.com/jetstack/paranoia/cmd/options"
+	"github.com/jetstack/paranoia/internal/analyse"
+)
+
+// Refreshes the data snapshots built into Paranoia, which are used when data
+// cannot be downloaded at runtime.
+func main() {
+	opts := &options.Analyse{
+		DataCacheDir: "internal/analyse/data",
+	}
+	paths, err := analyse.UpdateData(context.Background(), opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for name, path := range paths {
+		log.Printf("updated %s in %s", name, path)
+	}
+}
@@ -3,15 +3,22 @@
 package analyse
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"crypto/x509"
 	"encoding/csv"
 	"fmt"
-	"net/http"
+	"io"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/hako/durafmt"
+	"github.com/pkg/errors"
+
 	"github.com/jetstack/paranoia/cmd/options"
+	"github.com/jetstack/paranoia/internal/certificate"
 )
 
 type NoteLevel string
@@ -24,10 +31,69 @@ const (
 )
 
 type Note struct {
-	Level  NoteLevel
+	Level NoteLevel
+	// Rule is the stable identifier of the check which raised the note, which
+	// can be used to suppress it.
+	Rule   string
 	Reason string
 }
 
+const (
+	RuleNotYetValid              = "not-yet-valid"
+	RuleExpired                  = "expired"
+	RuleExpiresSoon              = "expires-soon"
+	RuleMozillaRemoved           = "mozilla-removed"
+	RuleMozillaUnknown           = "mozilla-unknown"
+	RuleRootProgrammeDistrusted  = "root-programme-distrusted"
+	RuleWeakRSAKey               = "weak-rsa-key"
+	RuleDSAKey                   = "dsa-key"
+	RuleWeakECCurve              = "weak-ec-curve"
+	RuleWeakSignature            = "weak-signature-algorithm"
+	RuleX509V1                   = "x509-v1"
+	RuleNegativeSerial           = "negative-serial"
+	RuleOversizedSerial          = "oversized-serial"
+	RuleLongValidity             = "long-validity"
+	RuleLeafInTrustStore         = "leaf-in-trust-store"
+	RuleIntermediateInTrustStore = "intermediate-in-trust-store"
+	RulePrivateKey               = "private-key"
+)
+
+// Rules is every rule the analyser checks.
+var Rules = []string{
+	RuleNotYetValid,
+	RuleExpired,
+	RuleExpiresSoon,
+	RuleMozillaRemoved,
+	RuleMozillaUnknown,
+	RuleRootProgrammeDistrusted,
+	RuleWeakRSAKey,
+	RuleDSAKey,
+	RuleWeakECCurve,
+	RuleWeakSignature,
+	RuleX509V1,
+	RuleNegativeSerial,
+	RuleOversizedSerial,
+	RuleLongValidity,
+	RuleLeafInTrustStore,
+	RuleIntermediateInTrustStore,
+	RulePrivateKey,
+}
+
+// PublicTrust describes whether a certificate is known to a public root
+// programme.
+type PublicTrust string
+
+const (
+	// PublicTrustIncluded is a certificate included in Mozilla's root store.
+	PublicTrustIncluded PublicTrust = "publicly-trusted"
+	// PublicTrustRemoved is a certificate that Mozilla has removed from its
+	// root store.
+	PublicTrustRemoved PublicTrust = "removed"
+	// PublicTrustUnknown is a certificate unknown to Mozilla's root store, such
+	// as a private or test certificate authority.
+	PublicTrustUnknown PublicTrust = "unknown"
+)
+
 type removedCertificate struct {
 	Fingerprint string
 	Comments    string
@@ -35,34 +101,144 @@ type removedCertificate struct {
 
 type Analyser struct {
 	RemovedCertificates []removedCertificate
+
+	// IncludedCertificates is the set of SHA-256 fingerprints, formatted as
+	// upper case hex, of the certificates included in Mozilla's root store.
+	IncludedCertificates map[string]bool
+
+	// UnknownCANoteLevel is the level of the note raised for certificates
+	// unknown to Mozilla's root store. If empty, no note is raised.
+	UnknownCANoteLevel NoteLevel
+
+	// RootProgrammes are additional root programmes to check certificates
+	// against.
+	RootProgrammes []RootProgramme
+
+	// SuppressedRules is the set of rules for which no notes are raised.
+	SuppressedRules map[string]bool
+
+	// Warnings are problems loading the datasets the analyser uses, such as
+	// falling back to the built-in snapshot when a download fails.
+	Warnings []string
 }
 
-const defaultMozillaRemovedCACertificateReportURL = "https://ccadb.my.salesforce-sites.com/mozilla/RemovedCACertificateReportCSVFormat"
+const (
+	defaultMozillaRemovedCACertificateReportURL  = "https://ccadb.my.salesforce-sites.com/mozilla/RemovedCACertificateReportCSVFormat"
+	defaultMozillaIncludedCACertificateReportURL = "https://ccadb.my.salesforce-sites.com/mozilla/IncludedCACertificateReportCSVFormat"
+	mozillaRemovedDatasetName                    = "mozilla-removed-ca-certificates.csv"
+	mozillaIncludedDatasetName                   = "mozilla-included-ca-certificates.csv"
+)
 
-// NewAnalyser creates a new Analyzer using the public Mozilla CA removed certificate list as part of
-// its checks. This method performs HTTP requests to retrieve that list. The request will be made with the given
-// context. The options struct configures various aspects of the analysis.
+// NewAnalyser creates a new Analyzer using the public Mozilla CA included and removed certificate lists as part of
+// its checks. Each list is read from a local file if one is configured, otherwise from the cache or by downloading it,
+// falling back to a snapshot built into Paranoia. The options struct configures various aspects of the analysis.
 func NewAnalyser(opts *options.Analyse) (*Analyser, error) {
-	rc, err := downloadMozillaRemovedCACertsList(opts)
+	var an Analyser
+
+	switch NoteLevel(opts.UnknownCANoteLevel) {
+	case NoteLevelWarn, NoteLevelError:
+		an.UnknownCANoteLevel = NoteLevel(opts.UnknownCANoteLevel)
+	case "", "none":
+	default:
+		return nil, fmt.Errorf("invalid note level %q for unknown certificate authorities, must be one of none, warn, error", opts.UnknownCANoteLevel)
+	}
+
+	an.SuppressedRules = make(map[string]bool)
+	for _, rule := range opts.SuppressRules {
+		if !slices.Contains(Rules, rule) {
+			return nil, fmt.Errorf("unknown rule %q, must be one of %s", rule, strings.Join(Rules, ", "))
+		}
+		an.SuppressedRules[rule] = true
+	}
+
+	b, source, warnings, err := mozillaRemovedDataset(opts).load(context.Background(), opts)
+	an.Warnings = append(an.Warnings, warnings...)
 	if err != nil {
 		return nil, err
 	}
-	return &Analyser{RemovedCertificates: rc}, nil
-}
+	an.RemovedCertificates, err = parseMozillaRemovedCACertsList(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Mozilla removed CA certificate list")
+	}
+	if len(an.RemovedCertificates) == 0 {
+		return nil, emptyDatasetError(mozillaRemovedDataset(opts), source, "mozilla-removed-certs-file")
+	}
 
-func downloadMozillaRemovedCACertsList(opts *options.Analyse) ([]removedCertificate, error) {
+	b, source, warnings, err = mozillaIncludedDataset(opts).load(context.Background(), opts)
+	an.Warnings = append(an.Warnings, warnings...)
+	if err != nil {
+		return nil, err
+	}
+	an.IncludedCertificates, err = parseMozillaIncludedCACertsList(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse Mozilla included CA certificate list")
+	}
+	if len(an.IncludedCertificates) == 0 && an.UnknownCANoteLevel != "" {
+		return nil, emptyDatasetError(mozillaIncludedDataset(opts), source, "mozilla-included-certs-file")
+	}
+
+	an.RootProgrammes, warnings, err = LoadRootProgrammes(context.Background(), opts)
+	an.Warnings = append(an.Warnings, warnings...)
+	if err != nil {
+		return nil, err
+	}
 
+	return &an, nil
+}
+
+func mozillaRemovedDataset(opts *options.Analyse) dataset {
 	// Use default URL if none provided
 	url := opts.MozillaRemovedCertsURL
 	if url == "" {
 		url = defaultMozillaRemovedCACertificateReportURL
 	}
+	return dataset{name: mozillaRemovedDatasetName, url: url, file: opts.MozillaRemovedCertsFile}
+}
 
-	resp, err := http.Get(url)
+func mozillaIncludedDataset(opts *options.Analyse) dataset {
+	url := opts.MozillaIncludedCertsURL
+	if url == "" {
+		url = defaultMozillaIncludedCACertificateReportURL
+	}
+	return dataset{name: mozillaIncludedDatasetName, url: url, file: opts.MozillaIncludedCertsFile}
+}
+
+func parseMozillaRemovedCACertsList(r io.Reader) ([]removedCertificate, error) {
+	records, err := readCSVColumns(r, fingerprintHeader, commentsHeader)
 	if err != nil {
 		return nil, err
 	}
-	csvReader := csv.NewReader(resp.Body)
+
+	var removedCerts []removedCertificate
+	for _, record := range records {
+		removedCerts = append(removedCerts, removedCertificate{
+			Fingerprint: normaliseFingerprint(record[0]),
+			Comments:    record[1],
+		})
+	}
+
+	return removedCerts, nil
+}
+
+func parseMozillaIncludedCACertsList(r io.Reader) (map[string]bool, error) {
+	records, err := readCSVColumns(r, fingerprintHeader)
+	if err != nil {
+		return nil, err
+	}
+
+	included := make(map[string]bool, len(records))
+	for _, record := range records {
+		included[normaliseFingerprint(record[0])] = true
+	}
+
+	return included, nil
+}
+
+// readCSVColumns reads a CSV file with a header row, returning the values of
+// the given columns from each record, in the order the columns were given.
+func readCSVColumns(r io.Reader, columns ...string) ([][]string, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
 	// Read the header first
 	headers, err := csvReader.Read()
 	if err != nil {
@@ -70,40 +246,60 @@ func downloadMozillaRemovedCACertsList(opts *options.Analyse) ([]removedCertific
 	}
 
 	// Find column indices by their names from the header
-	fingerprint := -1
-	comments := -1
-	for idx, header := range headers {
-		switch header {
-		case fingerprintHeader:
-			fingerprint = idx
-		case commentsHeader:
-			comments = idx
+	indices := make([]int, len(columns))
+	for i, column := range columns {
+		indices[i] = -1
+		for idx, header := range headers {
+			if header == column {
+				indices[i] = idx
+			}
+		}
+		if indices[i] == -1 {
+			return nil, fmt.Errorf("required column '%s' not found in CSV header", column)
 		}
 	}
 
-	if fingerprint == -1 {
-		return nil, fmt.Errorf("required column 'SHA-256 Fingerprint' not found in CSV header")
-	}
-	if comments == -1 {
-		return nil, fmt.Errorf("required column 'Comments' not found in CSV header")
-	}
-
-	// Now process each line individually instead of loading everything into memory
-	var removedCerts []removedCertificate
+	var records [][]string
 	for {
 		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
 		if err != nil {
-			break // End of file or other error
+			return nil, err
 		}
 
-		removedCerts = append(removedCerts, removedCertificate{
-			Fingerprint: record[fingerprint],
-			Comments:    record[comments],
-		})
+		values := make([]string, len(indices))
+		for i, idx := range indices {
+			if idx < len(record) {
+				values[i] = record[idx]
+			}
+		}
+		records = append(records, values)
 	}
 
-	// All CSV processing is done in the loop above, we already have the removedCerts slice
-	return removedCerts, nil
+	return records, nil
+}
+
+// normaliseFingerprint formats a hex fingerprint as upper case, without any
+// separators.
+func normaliseFingerprint(fingerprint string) string {
+	return strings.ToUpper(strings.NewReplacer(":", "", " ", "").Replace(fingerprint))
+}
+
+// PublicTrust returns whether the certificate is included in, removed from, or
+// unknown to Mozilla's root store.
+func (an *Analyser) PublicTrust(cert *x509.Certificate) PublicTrust {
+	fingerprint := fmt.Sprintf("%X", sha256.Sum256(cert.Raw))
+	for _, rc := range an.RemovedCertificates {
+		if fingerprint == rc.Fingerprint {
+			return PublicTrustRemoved
+		}
+	}
+	if an.IncludedCertificates[fingerprint] {
+		return PublicTrustIncluded
+	}
+	return PublicTrustUnknown
 }
 
 // AnalyseCertificate takes an X.509 certificate and performs basic analysis. This is intended to highlight any concerns
@@ -115,17 +311,20 @@ func (an *Analyser) AnalyseCertificate(cert *x509.Certificate) []Note {
 	if now.Before(cert.NotBefore) {
 		notes = append(notes, Note{
 			Level:  NoteLevelError,
+			Rule:   RuleNotYetValid,
 			Reason: "not yet valid ( becomes valid on " + cert.NotBefore.Format(time.RFC3339) + " in " + fmtDuration(cert.NotBefore.Sub(now)) + ")",
 		})
 	}
 	if now.After(cert.NotAfter) {
 		notes = append(notes, Note{
 			Level:  NoteLevelError,
+			Rule:   RuleExpired,
 			Reason: "expired ( expired on " + cert.NotAfter.Format(time.RFC3339) + ", " + fmtDuration(now.Sub(cert.NotAfter)) + " since expiry)",
 		})
 	} else if sixIshMonthsFromNow.After(cert.NotAfter) {
 		notes = append(notes, Note{
 			Level:  NoteLevelWarn,
+			Rule:   RuleExpiresSoon,
 			Reason: "expires soon ( expires on " + cert.NotAfter.Format(time.RFC3339) + ", " + fmtDuration(cert.NotAfter.Sub(now)) + " until expiry)",
 		})
 	}
@@ -140,11 +339,124 @@ func (an *Analyser) AnalyseCertificate(cert *x509.Certificate) []Note {
 			}
 			notes = append(notes, Note{
 				Level:  NoteLevelError,
+				Rule:   RuleMozillaRemoved,
 				Reason: reason,
 			})
 		}
 	}
-	return notes
+	for _, m := range Memberships(an.RootProgrammes, cert) {
+		if m.Status == ProgrammeStatusDistrusted {
+			notes = append(notes, Note{
+				Level:  NoteLevelError,
+				Rule:   RuleRootProgrammeDistrusted,
+				Reason: "distrusted by the " + m.Programme + " root programme",
+			})
+		}
+	}
+	// Mozilla's included list only has roots, so intermediates and leaves
+	// issued by them are always unknown to it.
+	if an.UnknownCANoteLevel != "" && certificate.Classify(cert) == certificate.ClassificationRoot &&
+		an.PublicTrust(cert) == PublicTrustUnknown {
+		notes = append(notes, Note{
+			Level:  an.UnknownCANoteLevel,
+			Rule:   RuleMozillaUnknown,
+			Reason: "unknown to Mozilla's root store, this may be a private or test certificate authority",
+		})
+	}
+	notes = append(notes, analyseCrypto(cert)...)
+
+	return an.unsuppressed(notes)
+}
+
+// AnalyseFound performs the same analysis as AnalyseCertificate, along with
+// checks that depend on where the certificate was found.
+func (an *Analyser) AnalyseFound(found certificate.Found) []Note {
+	notes := an.AnalyseCertificate(found.Certificate)
+	if isTrustStorePath(found.Location) {
+		switch certificate.Classify(found.Certificate) {
+		case certificate.ClassificationLeaf:
+			notes = append(notes, Note{
+				Level:  NoteLevelWarn,
+				Rule:   RuleLeafInTrustStore,
+				Reason: "leaf certificate in trust store, which usually means a server certificate has been pinned as a certificate authority",
+			})
+		case certificate.ClassificationIntermediate:
+			notes = append(notes, Note{
+				Level:  NoteLevelWarn,
+				Rule:   RuleIntermediateInTrustStore,
+				Reason: "intermediate certificate in trust store, only root certificates are expected",
+			})
+		}
+	}
+	return an.unsuppressed(notes)
+}
+
+// AnalysePrivateKey raises an error for a private key found in the image,
+// describing the key and any certificates it belongs to.
+func (an *Analyser) AnalysePrivateKey(key certificate.PrivateKey) []Note {
+	var details []string
+	if key.Type != "" {
+		details = append(details, fmt.Sprintf("%s %d bits", key.Type, key.Size))
+	}
+	if key.Format != "" {
+		details = append(details, key.Format)
+	}
+	if key.Encrypted {
+		details = append(details, "encrypted")
+	} else {
+		details = append(details, "unencrypted")
+	}
+	if n := len(key.MatchingCertificates); n == 1 {
+		details = append(details, "matches 1 certificate")
+	} else if n > 1 {
+		details = append(details, fmt.Sprintf("matches %d certificates", n))
+	}
+
+	return an.unsuppressed([]Note{{
+		Level:  NoteLevelError,
+		Rule:   RulePrivateKey,
+		Reason: "private key found ( " + strings.Join(details, ", ") + ")",
+	}})
+}
+
+func (an *Analyser) unsuppressed(notes []Note) []Note {
+	if len(an.SuppressedRules) == 0 {
+		return notes
+	}
+	var unsuppressed []Note
+	for _, n := range notes {
+		if !an.SuppressedRules[n.Rule] {
+			unsuppressed = append(unsuppressed, n)
+		}
+	}
+	return unsuppressed
+}
+
+// trustStorePaths are the well-known files and directories, across common
+// distributions, which contain the certificate authorities trusted by the
+// operating system. Directories end with a slash.
+var trustStorePaths = []string{
+	"/etc/ssl/certs/",
+	"/etc/ssl/cert.pem",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/ca-certificates/",
+	"/etc/pki/ca-trust/",
+	"/etc/pki/tls/certs/",
+	"/etc/pki/tls/cert.pem",
+	"/usr/share/ca-certificates/",
+	"/usr/local/share/ca-certificates/",
+	"/usr/share/pki/ca-trust-source/",
+	"/usr/share/pki/trust/",
+	"/var/lib/ca-certificates/",
+}
+
+func isTrustStorePath(location string) bool {
+	for _, p := range trustStorePaths {
+		if location == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(location, p)) {
+			return true
+		}
+	}
+	return false
 }
 
 func fmtDuration(duration time.Duration) string {
@@ -14,6 +14,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/certificate"
 )
 
 func TestAnalyser_AnalyseCertificate(t *testing.T) {
@@ -59,6 +61,38 @@ func TestAnalyser_AnalyseCertificate(t *testing.T) {
 		assert.Contains(t, notes[0].Reason, "not yet valid")
 	})
 
+	t.Run("public trust", func(t *testing.T) {
+		includedCert, includedFingerprint, err := generateTestCertificate(oneHourAgo, inOneYear)
+		require.NoError(t, err)
+		unknownCert, _, err := generateTestCertificate(oneHourAgo, inOneYear)
+		require.NoError(t, err)
+
+		analyser := Analyser{
+			RemovedCertificates:  analyser.RemovedCertificates,
+			IncludedCertificates: map[string]bool{includedFingerprint: true},
+			UnknownCANoteLevel:   NoteLevelWarn,
+		}
+
+		assert.Equal(t, PublicTrustIncluded, analyser.PublicTrust(includedCert))
+		assert.Equal(t, PublicTrustRemoved, analyser.PublicTrust(revokedCert))
+		assert.Equal(t, PublicTrustUnknown, analyser.PublicTrust(unknownCert))
+
+		assert.Empty(t, analyser.AnalyseCertificate(includedCert))
+		notes := analyser.AnalyseCertificate(unknownCert)
+		assert.Len(t, notes, 1)
+		assert.Equal(t, NoteLevelWarn, notes[0].Level)
+		assert.Contains(t, notes[0].Reason, "unknown to Mozilla's root store")
+
+		// The included list only has roots, so other certificates aren't
+		// unknown to it.
+		leaf := *unknownCert
+		leaf.BasicConstraintsValid, leaf.IsCA = true, false
+		assert.Empty(t, analyser.AnalyseCertificate(&leaf))
+
+		analyser.UnknownCANoteLevel = ""
+		assert.Empty(t, analyser.AnalyseCertificate(unknownCert))
+	})
+
 	t.Run("expiring soon", func(t *testing.T) {
 		expiredCert, _, err := generateTestCertificate(time.Now().Add(-time.Hour*2), time.Now().Add(time.Hour))
 		require.NoError(t, err)
@@ -69,6 +103,50 @@ func TestAnalyser_AnalyseCertificate(t *testing.T) {
 	})
 }
 
+func TestAnalyser_AnalyseFound(t *testing.T) {
+	root, _, err := generateTestCertificate(time.Now().Add(-time.Hour), time.Now().Add(time.Hour*24*365))
+	require.NoError(t, err)
+	leaf := *root
+	leaf.IsCA = false
+	leaf.KeyUsage = x509.KeyUsageDigitalSignature
+
+	analyser := Analyser{}
+
+	assert.Empty(t, analyser.AnalyseFound(certificate.Found{Location: "/etc/ssl/certs/ca-certificates.crt", Certificate: root}))
+	assert.Empty(t, analyser.AnalyseFound(certificate.Found{Location: "/app/server.crt", Certificate: &leaf}))
+
+	notes := analyser.AnalyseFound(certificate.Found{Location: "/etc/ssl/certs/ca-certificates.crt", Certificate: &leaf})
+	require.Len(t, notes, 1)
+	assert.Equal(t, RuleLeafInTrustStore, notes[0].Rule)
+	assert.Equal(t, NoteLevelWarn, notes[0].Level)
+
+	analyser.SuppressedRules = map[string]bool{RuleLeafInTrustStore: true}
+	assert.Empty(t, analyser.AnalyseFound(certificate.Found{Location: "/etc/ssl/certs/ca-certificates.crt", Certificate: &leaf}))
+}
+
+func TestAnalyser_AnalysePrivateKey(t *testing.T) {
+	analyser := Analyser{}
+
+	notes := analyser.AnalysePrivateKey(certificate.PrivateKey{
+		Location:             "/app/server.key",
+		Format:               "PKCS#1",
+		Type:                 "RSA",
+		Size:                 2048,
+		MatchingCertificates: [][32]byte{{1}},
+	})
+	require.Len(t, notes, 1)
+	assert.Equal(t, RulePrivateKey, notes[0].Rule)
+	assert.Equal(t, NoteLevelError, notes[0].Level)
+	assert.Equal(t, "private key found ( RSA 2048 bits, PKCS#1, unencrypted, matches 1 certificate)", notes[0].Reason)
+
+	notes = analyser.AnalysePrivateKey(certificate.PrivateKey{Format: "PKCS#8", Encrypted: true})
+	require.Len(t, notes, 1)
+	assert.Equal(t, "private key found ( PKCS#8, encrypted)", notes[0].Reason)
+
+	analyser.SuppressedRules = map[string]bool{RulePrivateKey: true}
+	assert.Empty(t, analyser.AnalysePrivateKey(certificate.PrivateKey{}))
+}
+
 // generateTestCertificate will generate a random test certificate.
 func generateTestCertificate(notBefore, notAfter time.Time) (*x509.Certificate, string, error) {
 	ca := &x509.Certificate{
@@ -0,0 +1,125 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package analyse
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+const (
+	// minRSAKeySize is the smallest RSA key size considered secure.
+	minRSAKeySize = 2048
+	// minECKeySize is the smallest elliptic curve size considered secure.
+	minECKeySize = 256
+	// maxSerialNumberLength is the maximum length in octets of a serial number
+	// allowed by RFC 5280.
+	maxSerialNumberLength = 20
+	// maxCAValidity is the longest validity period expected of a certificate
+	// authority.
+	maxCAValidity = 25 * 365 * 24 * time.Hour
+	// maxLeafValidity is the longest validity period allowed for a publicly
+	// trusted TLS server certificate by the CA/Browser Forum.
+	maxLeafValidity = 398 * 24 * time.Hour
+)
+
+// analyseCrypto checks the certificate for weak keys, weak signature
+// algorithms, and other properties that indicate a poorly or maliciously
+// constructed certificate.
+func analyseCrypto(cert *x509.Certificate) []Note {
+	var notes []Note
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		if size := pub.N.BitLen(); size < minRSAKeySize {
+			notes = append(notes, Note{
+				Level:  NoteLevelError,
+				Rule:   RuleWeakRSAKey,
+				Reason: fmt.Sprintf("weak RSA key ( %d bits, at least %d bits are required)", size, minRSAKeySize),
+			})
+		}
+	case *ecdsa.PublicKey:
+		if size := pub.Curve.Params().BitSize; size < minECKeySize {
+			notes = append(notes, Note{
+				Level:  NoteLevelError,
+				Rule:   RuleWeakECCurve,
+				Reason: fmt.Sprintf("weak elliptic curve ( %s, %d bits)", pub.Curve.Params().Name, size),
+			})
+		}
+	}
+	if cert.PublicKeyAlgorithm == x509.DSA {
+		notes = append(notes, Note{
+			Level:  NoteLevelError,
+			Rule:   RuleDSAKey,
+			Reason: "DSA key, which is deprecated and no longer trusted by browsers",
+		})
+	}
+
+	// Signatures on self-signed certificates aren't used when building a chain
+	// of trust, so a weak algorithm there does no harm.
+	if !certificate.IsSelfSigned(cert) {
+		switch cert.SignatureAlgorithm {
+		case x509.MD2WithRSA, x509.MD5WithRSA:
+			notes = append(notes, Note{
+				Level:  NoteLevelError,
+				Rule:   RuleWeakSignature,
+				Reason: "weak signature algorithm ( " + cert.SignatureAlgorithm.String() + ")",
+			})
+		case x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+			notes = append(notes, Note{
+				Level:  NoteLevelWarn,
+				Rule:   RuleWeakSignature,
+				Reason: "weak signature algorithm ( " + cert.SignatureAlgorithm.String() + ")",
+			})
+		}
+	}
+
+	if cert.Version == 1 {
+		notes = append(notes, Note{
+			Level:  NoteLevelWarn,
+			Rule:   RuleX509V1,
+			Reason: "X.509 version 1 certificate, which cannot carry basic constraints or other extensions",
+		})
+	}
+
+	if cert.SerialNumber != nil {
+		if cert.SerialNumber.Sign() < 0 {
+			notes = append(notes, Note{
+				Level:  NoteLevelWarn,
+				Rule:   RuleNegativeSerial,
+				Reason: "negative serial number",
+			})
+		}
+		// The serial number is encoded as a signed integer, so needs an extra
+		// octet when the most significant bit is set.
+		if length := cert.SerialNumber.BitLen()/8 + 1; length > maxSerialNumberLength {
+			notes = append(notes, Note{
+				Level:  NoteLevelWarn,
+				Rule:   RuleOversizedSerial,
+				Reason: fmt.Sprintf("oversized serial number ( %d octets, at most %d are allowed)", length, maxSerialNumberLength),
+			})
+		}
+	}
+
+	validity := cert.NotAfter.Sub(cert.NotBefore)
+	maxValidity := maxLeafValidity
+	// X.509 v1 roots, and roots without basic constraints, aren't marked as
+	// CAs, but are self-signed.
+	if cert.IsCA || certificate.IsSelfSigned(cert) {
+		maxValidity = maxCAValidity
+	}
+	if validity > maxValidity {
+		notes = append(notes, Note{
+			Level:  NoteLevelWarn,
+			Rule:   RuleLongValidity,
+			Reason: "excessively long validity period ( " + fmtDuration(validity) + ", expected at most " + fmtDuration(maxValidity) + ")",
+		})
+	}
+
+	return notes
+}
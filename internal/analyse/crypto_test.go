@@ -0,0 +1,157 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package analyse
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_analyseCrypto(t *testing.T) {
+	rsa1024, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	rsa2048, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p224, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	require.NoError(t, err)
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Now()
+	// good returns a certificate that passes every check, which each test case
+	// then modifies.
+	good := func() *x509.Certificate {
+		return &x509.Certificate{
+			Version:            3,
+			SerialNumber:       big.NewInt(1234),
+			RawSubject:         []byte("intermediate"),
+			RawIssuer:          []byte("root"),
+			PublicKey:          &rsa2048.PublicKey,
+			PublicKeyAlgorithm: x509.RSA,
+			SignatureAlgorithm: x509.SHA256WithRSA,
+			IsCA:               true,
+			NotBefore:          now.Add(-time.Hour),
+			NotAfter:           now.Add(10 * 365 * 24 * time.Hour),
+		}
+	}
+
+	tests := map[string]struct {
+		modify  func(*x509.Certificate)
+		expRule string
+		expLvl  NoteLevel
+	}{
+		"good certificate has no notes": {
+			modify: func(*x509.Certificate) {},
+		},
+		"RSA key under 2048 bits": {
+			modify:  func(c *x509.Certificate) { c.PublicKey = &rsa1024.PublicKey },
+			expRule: RuleWeakRSAKey,
+			expLvl:  NoteLevelError,
+		},
+		"P-256 key is fine": {
+			modify: func(c *x509.Certificate) {
+				c.PublicKey, c.PublicKeyAlgorithm, c.SignatureAlgorithm = &p256.PublicKey, x509.ECDSA, x509.ECDSAWithSHA256
+			},
+		},
+		"P-224 key": {
+			modify: func(c *x509.Certificate) {
+				c.PublicKey, c.PublicKeyAlgorithm, c.SignatureAlgorithm = &p224.PublicKey, x509.ECDSA, x509.ECDSAWithSHA256
+			},
+			expRule: RuleWeakECCurve,
+			expLvl:  NoteLevelError,
+		},
+		"DSA key": {
+			modify:  func(c *x509.Certificate) { c.PublicKey, c.PublicKeyAlgorithm = nil, x509.DSA },
+			expRule: RuleDSAKey,
+			expLvl:  NoteLevelError,
+		},
+		"MD5 signature": {
+			modify:  func(c *x509.Certificate) { c.SignatureAlgorithm = x509.MD5WithRSA },
+			expRule: RuleWeakSignature,
+			expLvl:  NoteLevelError,
+		},
+		"SHA-1 signature": {
+			modify:  func(c *x509.Certificate) { c.SignatureAlgorithm = x509.SHA1WithRSA },
+			expRule: RuleWeakSignature,
+			expLvl:  NoteLevelWarn,
+		},
+		"SHA-1 signature on a root is fine": {
+			modify: func(c *x509.Certificate) {
+				c.SignatureAlgorithm = x509.SHA1WithRSA
+				c.RawIssuer = c.RawSubject
+			},
+		},
+		"X.509 v1": {
+			modify:  func(c *x509.Certificate) { c.Version = 1 },
+			expRule: RuleX509V1,
+			expLvl:  NoteLevelWarn,
+		},
+		"negative serial": {
+			modify:  func(c *x509.Certificate) { c.SerialNumber = big.NewInt(-1234) },
+			expRule: RuleNegativeSerial,
+			expLvl:  NoteLevelWarn,
+		},
+		"20 octet serial is fine": {
+			modify: func(c *x509.Certificate) { c.SerialNumber = new(big.Int).Lsh(big.NewInt(1), 158) },
+		},
+		"oversized serial": {
+			modify:  func(c *x509.Certificate) { c.SerialNumber = new(big.Int).Lsh(big.NewInt(1), 159) },
+			expRule: RuleOversizedSerial,
+			expLvl:  NoteLevelWarn,
+		},
+		"CA valid for over 25 years": {
+			modify:  func(c *x509.Certificate) { c.NotAfter = now.Add(30 * 365 * 24 * time.Hour) },
+			expRule: RuleLongValidity,
+			expLvl:  NoteLevelWarn,
+		},
+		"X.509 v1 root has the CA validity limit": {
+			modify: func(c *x509.Certificate) {
+				c.Version, c.IsCA = 1, false
+				c.RawIssuer = c.RawSubject
+				c.NotAfter = now.Add(20 * 365 * 24 * time.Hour)
+			},
+			expRule: RuleX509V1,
+			expLvl:  NoteLevelWarn,
+		},
+		"leaf valid for over 398 days": {
+			modify:  func(c *x509.Certificate) { c.IsCA = false },
+			expRule: RuleLongValidity,
+			expLvl:  NoteLevelWarn,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			cert := good()
+			test.modify(cert)
+			notes := analyseCrypto(cert)
+			if test.expRule == "" {
+				assert.Empty(t, notes)
+				return
+			}
+			require.Len(t, notes, 1)
+			assert.Equal(t, test.expRule, notes[0].Rule)
+			assert.Equal(t, test.expLvl, notes[0].Level)
+		})
+	}
+}
+
+func TestAnalyser_SuppressedRules(t *testing.T) {
+	cert, _, err := generateTestCertificate(time.Now().Add(-time.Hour*2), time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+
+	analyser := Analyser{}
+	require.Len(t, analyser.AnalyseCertificate(cert), 1)
+
+	analyser.SuppressedRules = map[string]bool{RuleExpired: true}
+	assert.Empty(t, analyser.AnalyseCertificate(cert))
+}
@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package analyse
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/jetstack/paranoia/cmd/options"
+)
+
+// snapshots contains copies of each dataset taken when Paranoia was built.
+// They are used when a dataset cannot be read from a file, the cache, or
+// downloaded. Refresh them with `go run ./hack/update-data`.
+//
+//go:embed data
+var snapshots embed.FS
+
+// downloadTimeout is the maximum time allowed for downloading a dataset.
+const downloadTimeout = 30 * time.Second
+
+// dataSource describes where a dataset was loaded from.
+type dataSource string
+
+const (
+	dataSourceFile     dataSource = "file"
+	dataSourceCache    dataSource = "cache"
+	dataSourceDownload dataSource = "download"
+	dataSourceSnapshot dataSource = "snapshot"
+)
+
+// dataset is a remotely published data file used during analysis.
+type dataset struct {
+	// name identifies the dataset, and is used as the file name in both the
+	// cache and the embedded snapshots.
+	name string
+	// url is the location the dataset is downloaded from.
+	url string
+	// file is a local path to read the dataset from in place of downloading.
+	file string
+}
+
+// datasets returns every dataset used by the analyser, as configured by the
+// given options.
+func datasets(opts *options.Analyse) ([]dataset, error) {
+	ds := []dataset{
+		mozillaRemovedDataset(opts),
+		mozillaIncludedDataset(opts),
+	}
+	for _, spec := range opts.RootProgrammes {
+		_, d, err := rootProgrammeDataset(spec)
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, d)
+	}
+	return ds, nil
+}
+
+// load returns the contents of the dataset. It is read from the configured file
+// if one is given. Otherwise, the cache is used if it is fresh, and if not the
+// dataset is downloaded and the cache updated. If downloading fails, a stale
+// cache or else the embedded snapshot is used, and a warning returned.
+func (d dataset) load(ctx context.Context, opts *options.Analyse) ([]byte, dataSource, []string, error) {
+	if d.file != "" {
+		b, err := os.ReadFile(d.file)
+		if err != nil {
+			return nil, "", nil, errors.Wrapf(err, "failed to read %s file", d.name)
+		}
+		return b, dataSourceFile, nil, nil
+	}
+
+	cachePath, cacheErr := d.cachePath(opts)
+	var (
+		cached   []byte
+		cachedAt time.Time
+	)
+	if cacheErr == nil {
+		if info, err := os.Stat(cachePath); err == nil {
+			if b, err := os.ReadFile(cachePath); err == nil {
+				cached, cachedAt = b, info.ModTime()
+			}
+		}
+	}
+
+	if cached != nil && (opts.Offline || time.Since(cachedAt) < opts.DataCacheTTL) {
+		return cached, dataSourceCache, nil, nil
+	}
+
+	var warnings []string
+	if !opts.Offline {
+		b, err := d.download(ctx)
+		if err == nil {
+			if cacheErr == nil {
+				if err := writeCache(cachePath, b); err != nil {
+					warnings = append(warnings, fmt.Sprintf("failed to cache %s: %s", d.name, err))
+				}
+			}
+			return b, dataSourceDownload, warnings, nil
+		}
+		if cached != nil {
+			warnings = append(warnings, fmt.Sprintf("failed to download %s, using cached copy from %s: %s", d.name, cachedAt.Format(time.RFC3339), err))
+			return cached, dataSourceCache, warnings, nil
+		}
+		warnings = append(warnings, fmt.Sprintf("failed to download %s, using built-in snapshot: %s", d.name, err))
+	} else if cached != nil {
+		return cached, dataSourceCache, nil, nil
+	}
+
+	b, err := snapshots.ReadFile("data/" + d.name)
+	if err != nil {
+		return nil, "", warnings, errors.Wrapf(err, "no snapshot for %s", d.name)
+	}
+	return b, dataSourceSnapshot, warnings, nil
+}
+
+// emptyDatasetError is the error for a dataset without any entries, which
+// would otherwise silently disable the checks which use it.
+func emptyDatasetError(d dataset, source dataSource, fileFlag string) error {
+	return errors.Errorf("%s from the %s has no entries, so certificates can't be checked against it; "+
+		"download it with \"paranoia data update\", or give a local copy with --%s", d.name, source, fileFlag)
+}
+
+// update downloads the dataset and writes it to the cache.
+func (d dataset) update(ctx context.Context, opts *options.Analyse) (string, error) {
+	cachePath, err := d.cachePath(opts)
+	if err != nil {
+		return "", err
+	}
+	b, err := d.download(ctx)
+	if err != nil {
+		return "", err
+	}
+	return cachePath, writeCache(cachePath, b)
+}
+
+func (d dataset) download(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d from %s", resp.StatusCode, d.url)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+func (d dataset) cachePath(opts *options.Analyse) (string, error) {
+	dir := opts.DataCacheDir
+	if dir == "" {
+		userCache, err := os.UserCacheDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(userCache, "paranoia")
+	}
+	return filepath.Join(dir, d.name), nil
+}
+
+// writeCache atomically replaces the cache file at path with the given data.
+func writeCache(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// UpdateData downloads every dataset used by the analyser into the cache,
+// returning the path each was written to, keyed by dataset name. Datasets read
+// from local files are skipped.
+func UpdateData(ctx context.Context, opts *options.Analyse) (map[string]string, error) {
+	ds, err := datasets(opts)
+	if err != nil {
+		return nil, err
+	}
+	paths := make(map[string]string)
+	for _, d := range ds {
+		if d.url == "" {
+			continue
+		}
+		p, err := d.update(ctx, opts)
+		if err != nil {
+			return paths, errors.Wrapf(err, "failed to update %s", d.name)
+		}
+		paths[d.name] = p
+	}
+	return paths, nil
+}
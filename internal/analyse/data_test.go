@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package analyse
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/cmd/options"
+)
+
+const testRemovedCSV = "SHA-256 Fingerprint,Comments\nABCDEF,removed for testing\n"
+
+func newTestServer(t *testing.T, status int, body string) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func TestNewAnalyser_Data(t *testing.T) {
+	t.Run("downloads the list and caches it", func(t *testing.T) {
+		srv, requests := newTestServer(t, http.StatusOK, testRemovedCSV)
+		opts := &options.Analyse{
+			MozillaRemovedCertsURL:  srv.URL,
+			MozillaIncludedCertsURL: srv.URL,
+			DataCacheDir:            t.TempDir(),
+			DataCacheTTL:            time.Hour,
+		}
+
+		an, err := NewAnalyser(opts)
+		require.NoError(t, err)
+		assert.Equal(t, []removedCertificate{{Fingerprint: "ABCDEF", Comments: "removed for testing"}}, an.RemovedCertificates)
+		assert.FileExists(t, filepath.Join(opts.DataCacheDir, mozillaRemovedDatasetName))
+		assert.Equal(t, int32(2), requests.Load(), "expected the removed and included lists to be downloaded")
+
+		_, err = NewAnalyser(opts)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), requests.Load(), "expected the second analyser to use the cache, without downloading again")
+	})
+
+	t.Run("downloads again once the cache has expired", func(t *testing.T) {
+		srv, requests := newTestServer(t, http.StatusOK, testRemovedCSV)
+		opts := &options.Analyse{
+			MozillaRemovedCertsURL:  srv.URL,
+			MozillaIncludedCertsURL: srv.URL,
+			DataCacheDir:            t.TempDir(),
+			DataCacheTTL:            time.Hour,
+		}
+
+		_, err := NewAnalyser(opts)
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), requests.Load())
+		old := time.Now().Add(-2 * time.Hour)
+		require.NoError(t, os.Chtimes(filepath.Join(opts.DataCacheDir, mozillaRemovedDatasetName), old, old))
+
+		_, err = NewAnalyser(opts)
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), requests.Load(), "expected only the expired dataset to be downloaded again")
+	})
+
+	t.Run("uses a stale cache when the download fails", func(t *testing.T) {
+		srv, _ := newTestServer(t, http.StatusInternalServerError, "oops")
+		opts := &options.Analyse{
+			MozillaRemovedCertsURL:  srv.URL,
+			MozillaIncludedCertsURL: srv.URL,
+			DataCacheDir:            t.TempDir(),
+		}
+		cachePath := filepath.Join(opts.DataCacheDir, mozillaRemovedDatasetName)
+		require.NoError(t, os.WriteFile(cachePath, []byte(testRemovedCSV), 0o644))
+		old := time.Now().Add(-48 * time.Hour)
+		require.NoError(t, os.Chtimes(cachePath, old, old))
+
+		b, source, warnings, err := mozillaRemovedDataset(opts).load(context.TODO(), opts)
+		require.NoError(t, err)
+		assert.Equal(t, dataSourceCache, source)
+		assert.Equal(t, testRemovedCSV, string(b))
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "using cached copy")
+	})
+
+	t.Run("falls back to the snapshot when there is no cache", func(t *testing.T) {
+		srv, _ := newTestServer(t, http.StatusNotFound, "")
+		opts := &options.Analyse{
+			MozillaRemovedCertsURL:  srv.URL,
+			MozillaIncludedCertsURL: srv.URL,
+			DataCacheDir:            t.TempDir(),
+		}
+
+		_, source, warnings, err := mozillaRemovedDataset(opts).load(context.TODO(), opts)
+		require.NoError(t, err)
+		assert.Equal(t, dataSourceSnapshot, source)
+		require.Len(t, warnings, 1)
+		assert.Contains(t, warnings[0], "using built-in snapshot")
+
+		an, err := NewAnalyser(opts)
+		require.NoError(t, err)
+		assert.NotEmpty(t, an.RemovedCertificates, "expected the snapshot to have entries")
+		assert.Len(t, an.Warnings, 2, "expected a warning for each list")
+	})
+
+	t.Run("fails when a list has no entries", func(t *testing.T) {
+		srv, _ := newTestServer(t, http.StatusOK, testRemovedCSV)
+		file := filepath.Join(t.TempDir(), "removed.csv")
+		require.NoError(t, os.WriteFile(file, []byte("SHA-256 Fingerprint,Comments\n"), 0o644))
+		opts := &options.Analyse{
+			MozillaRemovedCertsURL:  srv.URL,
+			MozillaIncludedCertsURL: srv.URL,
+			MozillaRemovedCertsFile: file,
+			DataCacheDir:            t.TempDir(),
+		}
+
+		_, err := NewAnalyser(opts)
+		assert.ErrorContains(t, err, "mozilla-removed-ca-certificates.csv from the file has no entries")
+	})
+
+	t.Run("offline mode never downloads", func(t *testing.T) {
+		srv, requests := newTestServer(t, http.StatusOK, testRemovedCSV)
+		opts := &options.Analyse{
+			MozillaRemovedCertsURL:  srv.URL,
+			MozillaIncludedCertsURL: srv.URL,
+			DataCacheDir:            t.TempDir(),
+			Offline:                 true,
+		}
+
+		_, source, warnings, err := mozillaRemovedDataset(opts).load(context.TODO(), opts)
+		require.NoError(t, err)
+		assert.Equal(t, dataSourceSnapshot, source)
+		assert.Empty(t, warnings)
+
+		an, err := NewAnalyser(opts)
+		require.NoError(t, err)
+		assert.NotEmpty(t, an.RemovedCertificates, "expected the snapshot to have entries")
+		assert.Empty(t, an.Warnings)
+		assert.Equal(t, int32(0), requests.Load())
+	})
+
+	t.Run("builds from the snapshots alone", func(t *testing.T) {
+		srv, requests := newTestServer(t, http.StatusOK, testRemovedCSV)
+		opts := &options.Analyse{
+			MozillaRemovedCertsURL:  srv.URL,
+			MozillaIncludedCertsURL: srv.URL,
+			DataCacheDir:            t.TempDir(),
+			Offline:                 true,
+			UnknownCANoteLevel:      string(NoteLevelWarn),
+		}
+
+		an, err := NewAnalyser(opts)
+		require.NoError(t, err)
+		assert.NotEmpty(t, an.RemovedCertificates)
+		assert.NotEmpty(t, an.IncludedCertificates)
+		assert.Equal(t, int32(0), requests.Load())
+	})
+
+	t.Run("a local file is used in place of the URL", func(t *testing.T) {
+		srv, requests := newTestServer(t, http.StatusOK, testRemovedCSV)
+		file := filepath.Join(t.TempDir(), "removed.csv")
+		require.NoError(t, os.WriteFile(file, []byte(testRemovedCSV), 0o644))
+		opts := &options.Analyse{
+			MozillaRemovedCertsURL:  srv.URL,
+			MozillaIncludedCertsURL: srv.URL,
+			MozillaRemovedCertsFile: file,
+			DataCacheDir:            t.TempDir(),
+		}
+
+		an, err := NewAnalyser(opts)
+		require.NoError(t, err)
+		assert.Len(t, an.RemovedCertificates, 1)
+		assert.Equal(t, int32(1), requests.Load(), "expected only the included list to be downloaded")
+	})
+
+	t.Run("update writes to the cache", func(t *testing.T) {
+		srv, _ := newTestServer(t, http.StatusOK, testRemovedCSV)
+		opts := &options.Analyse{
+			MozillaRemovedCertsURL:  srv.URL,
+			MozillaIncludedCertsURL: srv.URL,
+			DataCacheDir:            t.TempDir(),
+		}
+
+		paths, err := UpdateData(context.TODO(), opts)
+		require.NoError(t, err)
+		assert.Len(t, paths, 2)
+		b, err := os.ReadFile(paths[mozillaRemovedDatasetName])
+		require.NoError(t, err)
+		assert.Equal(t, testRemovedCSV, string(b))
+	})
+}
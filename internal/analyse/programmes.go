@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package analyse
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/jetstack/paranoia/cmd/options"
+)
+
+// ProgrammeStatus is the status of a certificate in a root programme.
+type ProgrammeStatus string
+
+const (
+	ProgrammeStatusIncluded    ProgrammeStatus = "included"
+	ProgrammeStatusDistrusted  ProgrammeStatus = "distrusted"
+	ProgrammeStatusNotIncluded ProgrammeStatus = "not-included"
+)
+
+// RootProgramme is the set of certificates included in, or distrusted by, a
+// root programme such as the Chrome Root Store or Microsoft's Trusted Root
+// Program.
+type RootProgramme struct {
+	// Name is the user given name of the programme.
+	Name string
+	// Certificates maps SHA-256 fingerprints, formatted as upper case hex, to
+	// their status in the programme. Certificates not present in the map are
+	// not included in the programme.
+	Certificates map[string]ProgrammeStatus
+}
+
+// ProgrammeMembership is the status of a single certificate in a root
+// programme.
+type ProgrammeMembership struct {
+	Programme string
+	Status    ProgrammeStatus
+}
+
+var programmeNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// rootProgrammeDataset parses a root programme flag value of the form
+// name=location, where location is either a URL or a local file path.
+func rootProgrammeDataset(spec string) (string, dataset, error) {
+	name, location, ok := strings.Cut(spec, "=")
+	if !ok || location == "" {
+		return "", dataset{}, fmt.Errorf("invalid root programme %q, expected name=url or name=file", spec)
+	}
+	if !programmeNameRegexp.MatchString(name) {
+		return "", dataset{}, fmt.Errorf("invalid root programme name %q, must only contain letters, numbers, dashes and underscores", name)
+	}
+	d := dataset{name: "root-programme-" + name + ".csv"}
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		d.url = location
+	} else {
+		d.file = location
+	}
+	return name, d, nil
+}
+
+// LoadRootProgrammes loads every root programme configured in the options.
+// Programmes given by URL are cached in the same way as the Mozilla lists,
+// but have no snapshot to fall back to. Warnings are returned for programmes
+// which fell back to a stale cache.
+func LoadRootProgrammes(ctx context.Context, opts *options.Analyse) (programmes []RootProgramme, warnings []string, err error) {
+	for _, spec := range opts.RootProgrammes {
+		name, d, err := rootProgrammeDataset(spec)
+		if err != nil {
+			return nil, warnings, err
+		}
+		b, _, w, err := d.load(ctx, opts)
+		warnings = append(warnings, w...)
+		if err != nil {
+			return nil, warnings, errors.Wrapf(err, "failed to load %s root programme", name)
+		}
+		certificates, err := parseRootProgramme(name, bytes.NewReader(b))
+		if err != nil {
+			return nil, warnings, errors.Wrapf(err, "failed to parse %s root programme", name)
+		}
+		programmes = append(programmes, RootProgramme{Name: name, Certificates: certificates})
+	}
+	return programmes, warnings, nil
+}
+
+// parseRootProgramme reads a root programme from a CSV file with a header row.
+// The file must have a "SHA-256 Fingerprint" column. If it has a "Status"
+// column, or a "<name> Status" column as found in CCADB's all included roots
+// report, then that is used for the status of each certificate. Otherwise,
+// every listed certificate is considered to be included.
+func parseRootProgramme(name string, r io.Reader) (map[string]ProgrammeStatus, error) {
+	csvReader := csv.NewReader(r)
+	csvReader.FieldsPerRecord = -1
+	headers, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, status := -1, -1
+	for idx, header := range headers {
+		switch {
+		case header == fingerprintHeader:
+			fingerprint = idx
+		case strings.EqualFold(header, name+" Status"):
+			status = idx
+		case strings.EqualFold(header, "Status") && status == -1:
+			status = idx
+		}
+	}
+	if fingerprint == -1 {
+		return nil, fmt.Errorf("required column '%s' not found in CSV header", fingerprintHeader)
+	}
+
+	certificates := make(map[string]ProgrammeStatus)
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if fingerprint >= len(record) {
+			continue
+		}
+
+		s := ProgrammeStatusIncluded
+		if status != -1 && status < len(record) {
+			s = parseProgrammeStatus(record[status])
+		}
+		if s != ProgrammeStatusNotIncluded {
+			certificates[normaliseFingerprint(record[fingerprint])] = s
+		}
+	}
+
+	return certificates, nil
+}
+
+func parseProgrammeStatus(s string) ProgrammeStatus {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case strings.Contains(s, "distrust"), strings.Contains(s, "removed"),
+		strings.Contains(s, "revoked"), strings.Contains(s, "disabled"), strings.Contains(s, "block"):
+		return ProgrammeStatusDistrusted
+	case s == "", strings.Contains(s, "not included"), strings.Contains(s, "not in"):
+		return ProgrammeStatusNotIncluded
+	default:
+		return ProgrammeStatusIncluded
+	}
+}
+
+// Memberships returns the status of the certificate in each of the given root
+// programmes, in the order the programmes were given.
+func Memberships(programmes []RootProgramme, cert *x509.Certificate) []ProgrammeMembership {
+	fingerprint := fmt.Sprintf("%X", sha256.Sum256(cert.Raw))
+	memberships := make([]ProgrammeMembership, 0, len(programmes))
+	for _, p := range programmes {
+		status, ok := p.Certificates[fingerprint]
+		if !ok {
+			status = ProgrammeStatusNotIncluded
+		}
+		memberships = append(memberships, ProgrammeMembership{Programme: p.Name, Status: status})
+	}
+	return memberships
+}
@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package analyse
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/cmd/options"
+)
+
+func Test_parseRootProgramme(t *testing.T) {
+	tests := map[string]struct {
+		name string
+		csv  string
+		exp  map[string]ProgrammeStatus
+	}{
+		"without a status column, every certificate is included": {
+			name: "apple",
+			csv:  "SHA-256 Fingerprint\naa:bb\nCCDD\n",
+			exp: map[string]ProgrammeStatus{
+				"AABB": ProgrammeStatusIncluded,
+				"CCDD": ProgrammeStatusIncluded,
+			},
+		},
+		"a status column is used": {
+			name: "microsoft",
+			csv:  "SHA-256 Fingerprint,Status\nAABB,Included\nCCDD,Disabled\nEEFF,Not Included\n",
+			exp: map[string]ProgrammeStatus{
+				"AABB": ProgrammeStatusIncluded,
+				"CCDD": ProgrammeStatusDistrusted,
+			},
+		},
+		"a status column for the named programme is preferred": {
+			name: "chrome",
+			csv:  "Status,Chrome Status,Mozilla Status,SHA-256 Fingerprint\nIncluded,Included,Removed,AABB\nIncluded,,Included,CCDD\nIncluded,Distrusted,Included,EEFF\n",
+			exp: map[string]ProgrammeStatus{
+				"AABB": ProgrammeStatusIncluded,
+				"EEFF": ProgrammeStatusDistrusted,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := parseRootProgramme(test.name, strings.NewReader(test.csv))
+			require.NoError(t, err)
+			assert.Equal(t, test.exp, got)
+		})
+	}
+
+	t.Run("a missing fingerprint column is an error", func(t *testing.T) {
+		_, err := parseRootProgramme("chrome", strings.NewReader("Status\nIncluded\n"))
+		assert.Error(t, err)
+	})
+}
+
+func Test_rootProgrammeDataset(t *testing.T) {
+	name, d, err := rootProgrammeDataset("chrome=https://example.com/roots.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "chrome", name)
+	assert.Equal(t, "https://example.com/roots.csv", d.url)
+	assert.Empty(t, d.file)
+
+	_, d, err = rootProgrammeDataset("apple=./apple.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "./apple.csv", d.file)
+	assert.Empty(t, d.url)
+
+	for _, spec := range []string{"chrome", "chrome=", "../x=roots.csv"} {
+		_, _, err := rootProgrammeDataset(spec)
+		assert.Errorf(t, err, "expected error for %q", spec)
+	}
+}
+
+func TestLoadRootProgrammes(t *testing.T) {
+	oneHourAgo := time.Now().Add(-time.Hour)
+	inOneYear := time.Now().Add(time.Hour * 24 * 365)
+	includedCert, includedFingerprint, err := generateTestCertificate(oneHourAgo, inOneYear)
+	require.NoError(t, err)
+	distrustedCert, distrustedFingerprint, err := generateTestCertificate(oneHourAgo, inOneYear)
+	require.NoError(t, err)
+
+	srv, _ := newTestServer(t, http.StatusOK, "SHA-256 Fingerprint,Status\n"+includedFingerprint+",Included\n"+distrustedFingerprint+",Distrusted\n")
+	file := filepath.Join(t.TempDir(), "apple.csv")
+	require.NoError(t, os.WriteFile(file, []byte("SHA-256 Fingerprint\n"+includedFingerprint+"\n"), 0o644))
+
+	opts := &options.Analyse{
+		RootProgrammes: []string{"chrome=" + srv.URL, "apple=" + file},
+		DataCacheDir:   t.TempDir(),
+	}
+	programmes, warnings, err := LoadRootProgrammes(context.TODO(), opts)
+	require.NoError(t, err)
+	assert.Empty(t, warnings)
+	require.Len(t, programmes, 2)
+
+	assert.Equal(t, []ProgrammeMembership{
+		{Programme: "chrome", Status: ProgrammeStatusIncluded},
+		{Programme: "apple", Status: ProgrammeStatusIncluded},
+	}, Memberships(programmes, includedCert))
+	assert.Equal(t, []ProgrammeMembership{
+		{Programme: "chrome", Status: ProgrammeStatusDistrusted},
+		{Programme: "apple", Status: ProgrammeStatusNotIncluded},
+	}, Memberships(programmes, distrustedCert))
+
+	analyser := Analyser{RootProgrammes: programmes}
+	notes := analyser.AnalyseCertificate(distrustedCert)
+	require.Len(t, notes, 1)
+	assert.Equal(t, NoteLevelError, notes[0].Level)
+	assert.Contains(t, notes[0].Reason, "distrusted by the chrome root programme")
+}
@@ -0,0 +1,210 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package baseline compares the trust store of a container image against a
+// baseline, such as the stock certificate bundle of its distribution, to find
+// the certificate authorities which were added or removed.
+package baseline
+
+import (
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// Version is the version of the baseline file format.
+const Version = "1"
+
+// Baseline is the set of certificate authorities trusted by a reference image,
+// along with the distribution and package which provided them.
+type Baseline struct {
+	Version string `json:"version" yaml:"version"`
+	// Source is the image the baseline was built from, if any.
+	Source          string           `json:"source,omitempty" yaml:"source,omitempty"`
+	OperatingSystem *OperatingSystem `json:"operatingSystem,omitempty" yaml:"operatingSystem,omitempty"`
+	// Package is the package which installed most of the trusted certificates,
+	// usually ca-certificates.
+	Package      *Package      `json:"package,omitempty" yaml:"package,omitempty"`
+	Certificates []Certificate `json:"certificates" yaml:"certificates"`
+}
+
+type OperatingSystem struct {
+	ID         string `json:"id" yaml:"id"`
+	VersionID  string `json:"versionID,omitempty" yaml:"versionID,omitempty"`
+	PrettyName string `json:"prettyName,omitempty" yaml:"prettyName,omitempty"`
+}
+
+type Package struct {
+	Manager string `json:"manager" yaml:"manager"`
+	Name    string `json:"name" yaml:"name"`
+	Version string `json:"version" yaml:"version"`
+}
+
+type Certificate struct {
+	FingerprintSHA256 string `json:"fingerprintSHA256" yaml:"fingerprintSHA256"`
+	Subject           string `json:"subject,omitempty" yaml:"subject,omitempty"`
+}
+
+// New builds a baseline from the certificates trusted by the operating system
+// in the parsed image.
+func New(parsed *certificate.ParsedCertificates, source string) *Baseline {
+	b := &Baseline{
+		Version:         Version,
+		Source:          source,
+		OperatingSystem: operatingSystem(parsed.OperatingSystem),
+		Package:         trustStorePackage(parsed),
+		Certificates:    []Certificate{},
+	}
+	for _, found := range trusted(parsed) {
+		b.Certificates = append(b.Certificates, Certificate{
+			FingerprintSHA256: hex.EncodeToString(found.FingerprintSha256[:]),
+			Subject:           found.Certificate.Subject.String(),
+		})
+	}
+	return b
+}
+
+// Load reads a baseline from the given YAML or JSON file.
+func Load(fileName string) (*Baseline, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := yaml.Unmarshal(data, &b); err != nil {
+		return nil, errors.Wrap(err, "failed to parse baseline")
+	}
+	if b.Version != Version {
+		return nil, errors.Errorf("unsupported baseline version %q, expected %q", b.Version, Version)
+	}
+	return &b, nil
+}
+
+// Write writes the baseline as YAML.
+func (b *Baseline) Write(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(b); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// Comparison is the difference between the trust store of an image and a
+// baseline.
+type Comparison struct {
+	// OperatingSystem and Package describe the compared image, in the same
+	// way as the baseline.
+	OperatingSystem *OperatingSystem
+	Package         *Package
+	// Added are the certificates trusted by the image but not the baseline.
+	Added []certificate.Found
+	// Removed are the certificates in the baseline not trusted by the image.
+	Removed []Certificate
+}
+
+// Compare compares the certificates trusted by the operating system in the
+// parsed image against the baseline.
+func Compare(b *Baseline, parsed *certificate.ParsedCertificates) Comparison {
+	c := Comparison{
+		OperatingSystem: operatingSystem(parsed.OperatingSystem),
+		Package:         trustStorePackage(parsed),
+	}
+
+	inBaseline := make(map[string]bool)
+	for _, cert := range b.Certificates {
+		inBaseline[cert.FingerprintSHA256] = true
+	}
+	inImage := make(map[string]bool)
+	for _, found := range trusted(parsed) {
+		fingerprint := hex.EncodeToString(found.FingerprintSha256[:])
+		inImage[fingerprint] = true
+		if !inBaseline[fingerprint] {
+			c.Added = append(c.Added, found)
+		}
+	}
+	for _, cert := range b.Certificates {
+		if !inImage[cert.FingerprintSHA256] {
+			c.Removed = append(c.Removed, cert)
+		}
+	}
+
+	return c
+}
+
+// IsEmpty returns true if the image trusts exactly the baseline certificates.
+func (c Comparison) IsEmpty() bool {
+	return len(c.Added) == 0 && len(c.Removed) == 0
+}
+
+// SameOperatingSystem returns true if the baseline is for the same
+// distribution and version as the compared image, or if either is unknown.
+func (c Comparison) SameOperatingSystem(b *Baseline) bool {
+	if c.OperatingSystem == nil || b.OperatingSystem == nil {
+		return true
+	}
+	return c.OperatingSystem.ID == b.OperatingSystem.ID && c.OperatingSystem.VersionID == b.OperatingSystem.VersionID
+}
+
+// trusted returns the certificates trusted by the operating system, once per
+// fingerprint, since the same certificate is usually in both its source file
+// and the generated bundle.
+func trusted(parsed *certificate.ParsedCertificates) []certificate.Found {
+	var founds []certificate.Found
+	seen := make(map[[32]byte]bool)
+	for _, found := range parsed.Found {
+		if found.Trust != certificate.TrustTrusted || seen[found.FingerprintSha256] {
+			continue
+		}
+		seen[found.FingerprintSha256] = true
+		founds = append(founds, found)
+	}
+	return founds
+}
+
+func operatingSystem(system *certificate.OperatingSystem) *OperatingSystem {
+	if system == nil {
+		return nil
+	}
+	return &OperatingSystem{
+		ID:         system.ID,
+		VersionID:  system.VersionID,
+		PrettyName: system.PrettyName,
+	}
+}
+
+// trustStorePackage returns the package which installed the most trusted
+// certificates, or nil if none were installed by a package.
+func trustStorePackage(parsed *certificate.ParsedCertificates) *Package {
+	counts := make(map[Package]int)
+	for _, found := range trusted(parsed) {
+		if found.Package == nil {
+			continue
+		}
+		counts[Package{
+			Manager: found.Package.Manager,
+			Name:    found.Package.Name,
+			Version: found.Package.Version,
+		}]++
+	}
+
+	var pkgs []Package
+	for pkg := range counts {
+		pkgs = append(pkgs, pkg)
+	}
+	if len(pkgs) == 0 {
+		return nil
+	}
+	sort.Slice(pkgs, func(i, j int) bool {
+		if counts[pkgs[i]] != counts[pkgs[j]] {
+			return counts[pkgs[i]] > counts[pkgs[j]]
+		}
+		return pkgs[i].Name < pkgs[j].Name
+	})
+	return &pkgs[0]
+}
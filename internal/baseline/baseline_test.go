@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package baseline
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/certificate/certificatetest"
+)
+
+func found(name, location string, trust certificate.Trust, pkg *certificate.Package) certificate.Found {
+	f := certificatetest.Root(name, location)
+	f.Trust = trust
+	f.Package = pkg
+	return f
+}
+
+func TestNew(t *testing.T) {
+	pkg := &certificate.Package{Manager: "dpkg", Name: "ca-certificates", Version: "20230311"}
+	parsed := &certificate.ParsedCertificates{
+		OperatingSystem: &certificate.OperatingSystem{ID: "debian", VersionID: "12", PrettyName: "Debian GNU/Linux 12 (bookworm)"},
+		Found: []certificate.Found{
+			found("A", "/usr/share/ca-certificates/mozilla/A.crt", certificate.TrustTrusted, pkg),
+			found("B", "/usr/share/ca-certificates/mozilla/B.crt", certificate.TrustTrusted, pkg),
+			found("A", "/etc/ssl/certs/ca-certificates.crt", certificate.TrustTrusted, nil),
+			found("C", "/usr/share/ca-certificates/mozilla/C.crt", certificate.TrustDistrusted, pkg),
+			found("D", "/app/ca.pem", certificate.TrustUntrustedFile, nil),
+		},
+	}
+
+	assert.Equal(t, &Baseline{
+		Version:         Version,
+		Source:          "debian:12",
+		OperatingSystem: &OperatingSystem{ID: "debian", VersionID: "12", PrettyName: "Debian GNU/Linux 12 (bookworm)"},
+		Package:         &Package{Manager: "dpkg", Name: "ca-certificates", Version: "20230311"},
+		Certificates: []Certificate{
+			{FingerprintSHA256: certificatetest.Fingerprint("A"), Subject: "CN=A"},
+			{FingerprintSHA256: certificatetest.Fingerprint("B"), Subject: "CN=B"},
+		},
+	}, New(parsed, "debian:12"))
+}
+
+func TestWriteLoad(t *testing.T) {
+	b := &Baseline{
+		Version:         Version,
+		OperatingSystem: &OperatingSystem{ID: "alpine", VersionID: "3.19.1"},
+		Certificates: []Certificate{
+			{FingerprintSHA256: certificatetest.Fingerprint("A"), Subject: "CN=A"},
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, b.Write(&buf))
+	fileName := filepath.Join(t.TempDir(), "baseline.yaml")
+	require.NoError(t, os.WriteFile(fileName, buf.Bytes(), 0o644))
+
+	loaded, err := Load(fileName)
+	require.NoError(t, err)
+	assert.Equal(t, b, loaded)
+
+	require.NoError(t, os.WriteFile(fileName, []byte(`version: "2"`), 0o644))
+	_, err = Load(fileName)
+	assert.EqualError(t, err, `unsupported baseline version "2", expected "1"`)
+}
+
+func TestCompare(t *testing.T) {
+	b := &Baseline{
+		Version:         Version,
+		OperatingSystem: &OperatingSystem{ID: "debian", VersionID: "12"},
+		Certificates: []Certificate{
+			{FingerprintSHA256: certificatetest.Fingerprint("A"), Subject: "CN=A"},
+			{FingerprintSHA256: certificatetest.Fingerprint("B"), Subject: "CN=B"},
+			{FingerprintSHA256: certificatetest.Fingerprint("C"), Subject: "CN=C"},
+		},
+	}
+	parsed := &certificate.ParsedCertificates{
+		OperatingSystem: &certificate.OperatingSystem{ID: "debian", VersionID: "11"},
+		Found: []certificate.Found{
+			found("A", "/etc/ssl/certs/ca-certificates.crt", certificate.TrustTrusted, nil),
+			found("C", "/usr/share/ca-certificates/mozilla/C.crt", certificate.TrustDistrusted, nil),
+			found("Corp", "/usr/local/share/ca-certificates/corp.crt", certificate.TrustTrusted, nil),
+			found("Corp", "/etc/ssl/certs/ca-certificates.crt", certificate.TrustTrusted, nil),
+		},
+	}
+
+	c := Compare(b, parsed)
+	assert.False(t, c.IsEmpty())
+	assert.False(t, c.SameOperatingSystem(b))
+	require.Len(t, c.Added, 1)
+	assert.Equal(t, "/usr/local/share/ca-certificates/corp.crt", c.Added[0].Location)
+	assert.Equal(t, []Certificate{
+		{FingerprintSHA256: certificatetest.Fingerprint("B"), Subject: "CN=B"},
+		{FingerprintSHA256: certificatetest.Fingerprint("C"), Subject: "CN=C"},
+	}, c.Removed)
+}
@@ -6,6 +6,10 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
 	"crypto/x509"
 	"fmt"
 	"io"
@@ -33,6 +37,117 @@ type Found struct {
 
 	// Fingerprint is the SHA-256 fingerprint of the certificate.
 	FingerprintSha256 [32]byte
+
+	// TrustSettings are the trust settings of an OpenSSL trusted certificate.
+	// Nil for other certificates.
+	TrustSettings *TrustSettings
+
+	// Trust is whether the operating system trusts the certificate, based on
+	// where it was found and the trust store configuration of the image. Empty
+	// if this has not been determined.
+	Trust Trust
+
+	// TrustVariables are the names of the environment variables in the image
+	// config through which applications are configured to trust the
+	// certificate, such as SSL_CERT_FILE.
+	TrustVariables []string
+
+	// Package is the operating system package which installed the file the
+	// certificate was found in, if any.
+	Package *Package
+}
+
+// Package is an operating system package which owns a file in the image.
+type Package struct {
+	// Manager is the package manager which installed the package, one of
+	// "dpkg", "apk" or "rpm".
+	Manager string
+
+	// Name and Version identify the package.
+	Name    string
+	Version string
+
+	// Modified is true if the contents of the file differ from the checksum
+	// recorded by the package manager.
+	Modified bool
+}
+
+// TrustVariable is a path given by an environment variable in the image config
+// which configures the certificates trusted by applications.
+type TrustVariable struct {
+	// Name is the name of the environment variable, such as SSL_CERT_FILE.
+	Name string
+
+	// Path is the path as given in the variable.
+	Path string
+
+	// Resolved is the absolute path in the image, after following any
+	// symbolic links.
+	Resolved string
+
+	// Exists is true if the path exists in the image.
+	Exists bool
+}
+
+// Trust is the effective trust of a certificate by the operating system.
+type Trust string
+
+const (
+	// TrustTrusted is a certificate authority trusted by the operating system.
+	TrustTrusted Trust = "trusted"
+	// TrustDistrusted is a certificate explicitly disabled or blocklisted in
+	// the trust store configuration.
+	TrustDistrusted Trust = "distrusted"
+	// TrustUntrustedFile is a certificate in a file which isn't part of the
+	// trust store, though applications may still be configured to use it.
+	TrustUntrustedFile Trust = "untrusted-file"
+)
+
+// TrustSettings are the auxiliary trust settings stored alongside a
+// certificate in OpenSSL's "TRUSTED CERTIFICATE" format. These explicitly
+// trust or reject the certificate for the given extended key usages.
+type TrustSettings struct {
+	// Trusted are the extended key usages the certificate is trusted for.
+	Trusted []string
+
+	// Rejected are the extended key usages the certificate is explicitly
+	// rejected for.
+	Rejected []string
+
+	// Alias is the friendly name of the certificate.
+	Alias string
+}
+
+// CRL is a single X.509 certificate revocation list which was found by a
+// parser inside the given image.
+type CRL struct {
+	// Location is the filepath location where the CRL was found.
+	Location string
+
+	// Parser is the name of the parser which discovered the CRL.
+	Parser string
+
+	// RevocationList is the parsed CRL.
+	RevocationList *x509.RevocationList
+
+	// Fingerprint is the SHA-256 fingerprint of the CRL.
+	FingerprintSha256 [32]byte
+}
+
+// CertificateRequest is a single certificate signing request which was found
+// by a parser inside the given image.
+type CertificateRequest struct {
+	// Location is the filepath location where the request was found.
+	Location string
+
+	// Parser is the name of the parser which discovered the request.
+	Parser string
+
+	// Request is the parsed certificate request.
+	Request *x509.CertificateRequest
+
+	// Fingerprint is the SHA-256 fingerprint of the request.
+	FingerprintSha256 [32]byte
 }
 
 // Partial is a "partial" certificate. Usually the result of parsing something that looks like a certificate but isn't
@@ -50,6 +165,82 @@ type Partial struct {
 	Reason string
 }
 
+// PublicKeySize returns the size in bits of the given public key, or zero if
+// the key type is not known.
+func PublicKeySize(pub any) int {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	case *dsa.PublicKey:
+		return k.P.BitLen()
+	default:
+		return 0
+	}
+}
+
+// ExtKeyUsageNames are the names of extended key usages, as used in output
+// and trust settings.
+var ExtKeyUsageNames = map[x509.ExtKeyUsage]string{
+	x509.ExtKeyUsageAny:                            "any",
+	x509.ExtKeyUsageServerAuth:                     "serverAuth",
+	x509.ExtKeyUsageClientAuth:                     "clientAuth",
+	x509.ExtKeyUsageCodeSigning:                    "codeSigning",
+	x509.ExtKeyUsageEmailProtection:                "emailProtection",
+	x509.ExtKeyUsageIPSECEndSystem:                 "ipsecEndSystem",
+	x509.ExtKeyUsageIPSECTunnel:                    "ipsecTunnel",
+	x509.ExtKeyUsageIPSECUser:                      "ipsecUser",
+	x509.ExtKeyUsageTimeStamping:                   "timeStamping",
+	x509.ExtKeyUsageOCSPSigning:                    "ocspSigning",
+	x509.ExtKeyUsageMicrosoftServerGatedCrypto:     "microsoftServerGatedCrypto",
+	x509.ExtKeyUsageNetscapeServerGatedCrypto:      "netscapeServerGatedCrypto",
+	x509.ExtKeyUsageMicrosoftCommercialCodeSigning: "microsoftCommercialCodeSigning",
+	x509.ExtKeyUsageMicrosoftKernelCodeSigning:     "microsoftKernelCodeSigning",
+}
+
+// IsSelfSigned returns true if the certificate is self-issued, with the same
+// subject and issuer, and where key identifiers are present they match.
+func IsSelfSigned(cert *x509.Certificate) bool {
+	if !bytes.Equal(cert.RawSubject, cert.RawIssuer) {
+		return false
+	}
+	if len(cert.AuthorityKeyId) > 0 && len(cert.SubjectKeyId) > 0 {
+		return bytes.Equal(cert.AuthorityKeyId, cert.SubjectKeyId)
+	}
+	return true
+}
+
+// Classification is the role of a certificate in a chain of trust.
+type Classification string
+
+const (
+	ClassificationRoot         Classification = "root"
+	ClassificationIntermediate Classification = "intermediate"
+	ClassificationLeaf         Classification = "leaf"
+)
+
+// Classify returns whether the certificate is a root, intermediate or leaf
+// certificate. A certificate is a leaf if its basic constraints say it is not a
+// certificate authority, if its key usage doesn't permit signing certificates,
+// or if it isn't self-signed and lacks basic constraints entirely. Otherwise,
+// self-signed certificates are roots and all others are intermediates.
+func Classify(cert *x509.Certificate) Classification {
+	selfSigned := IsSelfSigned(cert)
+	switch {
+	case cert.BasicConstraintsValid && !cert.IsCA,
+		cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageCertSign == 0,
+		!cert.BasicConstraintsValid && !selfSigned:
+		return ClassificationLeaf
+	case selfSigned:
+		return ClassificationRoot
+	default:
+		return ClassificationIntermediate
+	}
+}
+
 type rseekerOpener func() (io.ReadSeeker, error)
 
 type ParsedCertificates struct {
@@ -58,20 +249,60 @@ type ParsedCertificates struct {
 	// Partials is a slice of any partial certificates we've found. This might be fragments of certificates in memory
 	// or other anomalies.
 	Partials []Partial
+	// PrivateKeys is a slice of any private keys we've found.
+	PrivateKeys []PrivateKey
+	// CRLs is a slice of any certificate revocation lists we've found.
+	CRLs []CRL
+	// CertificateRequests is a slice of any certificate signing requests we've
+	// found.
+	CertificateRequests []CertificateRequest
+	// Files is an index of every file in the container image, keyed by
+	// absolute path.
+	Files map[string]File
+	// TrustVariables are the paths given by trust related environment
+	// variables in the image config.
+	TrustVariables []TrustVariable
+	// OperatingSystem is the distribution of the container image, if it could
+	// be detected.
+	OperatingSystem *OperatingSystem
+}
+
+// OperatingSystem identifies the distribution of a container image, from the
+// fields of its os-release file.
+type OperatingSystem struct {
+	// ID is the lower case identifier of the distribution, such as "debian".
+	ID string
+	// VersionID is the version of the distribution, such as "12". Empty for
+	// rolling releases.
+	VersionID string
+	// PrettyName is the human readable name of the distribution and version,
+	// such as "Debian GNU/Linux 12 (bookworm)".
+	PrettyName string
 }
 
 func (p *ParsedCertificates) appendParsed(q *ParsedCertificates) {
 	p.Found = append(p.Found, q.Found...)
 	p.Partials = append(p.Partials, q.Partials...)
+	p.PrivateKeys = append(p.PrivateKeys, q.PrivateKeys...)
+	p.CRLs = append(p.CRLs, q.CRLs...)
+	p.CertificateRequests = append(p.CertificateRequests, q.CertificateRequests...)
 }
 
-// parser is the interface implemented by X.509 certificate parsers.
+// parser is the interface implemented by X.509 certificate and private key
+// parsers.
 type parser interface {
 	Find(context.Context, string, rseekerOpener) (*ParsedCertificates, error)
 }
 
 // FindCertificates will scan a container image, given as a file handler to a TAR file, for certificates and return them.
 func FindCertificates(ctx context.Context, imageTar io.Reader) (*ParsedCertificates, error) {
+	return findCertificates(ctx, imageTar, nil)
+}
+
+// findCertificates scans a TAR file for certificates. If skip is not nil, it
+// is called for each entry first, and entries for which it returns true are
+// neither scanned nor recorded in the file index.
+func findCertificates(ctx context.Context, imageTar io.Reader, skip func(*tar.Header) bool) (*ParsedCertificates, error) {
 	var (
 		parsers = []parser{pem{}}
 		parsed  = &ParsedCertificates{}
@@ -89,8 +320,15 @@ func FindCertificates(ctx context.Context, imageTar io.Reader) (*ParsedCertifica
 			return nil, err
 		}
 
-		// If file is not a regular file, ignore.
+		if skip != nil && skip(header) {
+			continue
+		}
+
+		// If file is not a regular file, only record it in the index.
 		if header.Typeflag != tar.TypeReg {
+			if err := parsed.addFile(header, nil); err != nil {
+				return nil, err
+			}
 			continue
 		}
 
@@ -99,12 +337,18 @@ func FindCertificates(ctx context.Context, imageTar io.Reader) (*ParsedCertifica
 			return nil, err
 		}
 
+		if err := parsed.addFile(header, opener); err != nil {
+			return nil, err
+		}
+
 		var (
 			wg   sync.WaitGroup
 			lock sync.Mutex
 			errs []string
 		)
 
+		found, keys := len(parsed.Found), len(parsed.PrivateKeys)
+
 		wg.Add(len(parsers))
 
 		// Run all parsers.
@@ -123,6 +367,14 @@ func FindCertificates(ctx context.Context, imageTar io.Reader) (*ParsedCertifica
 
 		wg.Wait()
 
+		// Keep the digests of files containing certificates or keys, so they
+		// can be checked against package databases.
+		if len(parsed.Found) > found || len(parsed.PrivateKeys) > keys {
+			if err := parsed.addDigests(header, opener); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+
 		select {
 		case <-ctx.Done():
 			return nil, ctx.Err()
@@ -138,6 +390,8 @@ func FindCertificates(ctx context.Context, imageTar io.Reader) (*ParsedCertifica
 		}
 	}
 
+	parsed.matchPrivateKeys()
+
 	return parsed, nil
 }
 
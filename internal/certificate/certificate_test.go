@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"context"
+	"crypto/x509"
 	"fmt"
 	"io"
 	"os"
@@ -85,3 +86,49 @@ func Test_openerForFile(t *testing.T) {
 		assert.NoFileExists(t, filename)
 	})
 }
+
+func TestClassify(t *testing.T) {
+	tests := map[string]struct {
+		cert *x509.Certificate
+		exp  Classification
+	}{
+		"self-signed CA is a root": {
+			cert: &x509.Certificate{RawSubject: []byte("a"), RawIssuer: []byte("a"), BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageCertSign},
+			exp:  ClassificationRoot,
+		},
+		"self-signed version 1 certificate is a root": {
+			cert: &x509.Certificate{RawSubject: []byte("a"), RawIssuer: []byte("a")},
+			exp:  ClassificationRoot,
+		},
+		"CA signed by another is an intermediate": {
+			cert: &x509.Certificate{RawSubject: []byte("a"), RawIssuer: []byte("b"), BasicConstraintsValid: true, IsCA: true},
+			exp:  ClassificationIntermediate,
+		},
+		"self-issued with mismatched key IDs is an intermediate": {
+			cert: &x509.Certificate{RawSubject: []byte("a"), RawIssuer: []byte("a"), SubjectKeyId: []byte{1}, AuthorityKeyId: []byte{2}, BasicConstraintsValid: true, IsCA: true},
+			exp:  ClassificationIntermediate,
+		},
+		"basic constraints without CA is a leaf": {
+			cert: &x509.Certificate{RawSubject: []byte("a"), RawIssuer: []byte("b"), BasicConstraintsValid: true},
+			exp:  ClassificationLeaf,
+		},
+		"self-signed server certificate is a leaf": {
+			cert: &x509.Certificate{RawSubject: []byte("a"), RawIssuer: []byte("a"), BasicConstraintsValid: true, IsCA: false},
+			exp:  ClassificationLeaf,
+		},
+		"CA without keyCertSign is a leaf": {
+			cert: &x509.Certificate{RawSubject: []byte("a"), RawIssuer: []byte("b"), BasicConstraintsValid: true, IsCA: true, KeyUsage: x509.KeyUsageDigitalSignature},
+			exp:  ClassificationLeaf,
+		},
+		"missing basic constraints signed by another is a leaf": {
+			cert: &x509.Certificate{RawSubject: []byte("a"), RawIssuer: []byte("b")},
+			exp:  ClassificationLeaf,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.exp, Classify(test.cert))
+		})
+	}
+}
@@ -0,0 +1,52 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package certificatetest provides found certificates for tests. The
+// certificates aren't signed or encoded, and are identified by fingerprints of
+// their subjects.
+package certificatetest
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"time"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// Root returns a self-signed certificate authority with the given subject
+// common name, found at the location.
+func Root(subject, location string) certificate.Found {
+	found := Leaf(subject, location)
+	found.Certificate.Issuer = found.Certificate.Subject
+	found.Certificate.RawIssuer = found.Certificate.RawSubject
+	found.Certificate.IsCA = true
+	return found
+}
+
+// Leaf returns a certificate with the given subject common name, issued by
+// another certificate and not itself a certificate authority, found at the
+// location.
+func Leaf(subject, location string) certificate.Found {
+	return certificate.Found{
+		Location: location,
+		Certificate: &x509.Certificate{
+			Subject:               pkix.Name{CommonName: subject},
+			Issuer:                pkix.Name{CommonName: "Example Issuing CA"},
+			RawSubject:            []byte(subject),
+			RawIssuer:             []byte("Example Issuing CA"),
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().AddDate(1, 0, 0),
+			BasicConstraintsValid: true,
+		},
+		FingerprintSha256: sha256.Sum256([]byte(subject)),
+	}
+}
+
+// Fingerprint returns the hex SHA-256 fingerprint of the certificates Root and
+// Leaf return for the subject.
+func Fingerprint(subject string) string {
+	sum := sha256.Sum256([]byte(subject))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package certificate
+
+import (
+	"archive/tar"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"io"
+	"path"
+	"strings"
+)
+
+// File is an entry in the filesystem of the scanned image.
+type File struct {
+	// Type is the tar header type of the entry, such as tar.TypeReg or
+	// tar.TypeSymlink.
+	Type byte
+
+	// Linkname is the target of a symbolic or hard link.
+	Linkname string
+
+	// Size is the size of a regular file in bytes.
+	Size int64
+
+	// Contents is the content of the file, if it is one of the metadata files
+	// captured while scanning. Nil for all other files.
+	Contents []byte
+
+	// Digests are the digests of the file's contents. Only computed for files
+	// containing certificates or private keys, and nil for all other files.
+	Digests *Digests
+}
+
+// Digests are the digests of a file's contents, in each of the algorithms used
+// by package managers to verify installed files.
+type Digests struct {
+	MD5    [md5.Size]byte
+	SHA1   [sha1.Size]byte
+	SHA256 [sha256.Size]byte
+}
+
+// capturedFiles are patterns, as understood by path.Match, of the metadata
+// files whose contents are kept while scanning. These describe the operating
+// system, and how it uses the certificates found.
+var capturedFiles = []string{
+	// Trust store configuration.
+	"/etc/ca-certificates.conf",
+	"/etc/pki/ca-trust/source/*.p11-kit",
+	"/usr/share/pki/ca-trust-source/*.p11-kit",
+	// Distribution identification.
+	"/etc/os-release",
+	"/usr/lib/os-release",
+	// Package databases.
+	"/var/lib/dpkg/status",
+	"/var/lib/dpkg/status.d/*",
+	"/var/lib/dpkg/info/*.list",
+	"/var/lib/dpkg/info/*.md5sums",
+	"/lib/apk/db/installed",
+	"/var/lib/rpm/rpmdb.sqlite",
+	"/var/lib/rpm/Packages",
+	"/usr/lib/sysimage/rpm/rpmdb.sqlite",
+	"/usr/lib/sysimage/rpm/Packages.db",
+}
+
+// maxCapturedFileSize is the largest metadata file that will be captured.
+const maxCapturedFileSize = 128 << 20
+
+// maxSymlinks is the most symbolic links followed when resolving a path.
+const maxSymlinks = 40
+
+func isCapturedFile(name string) bool {
+	for _, pattern := range capturedFiles {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// addFile records the given tar entry in the file index, capturing its
+// contents if it is a metadata file.
+func (p *ParsedCertificates) addFile(header *tar.Header, opener rseekerOpener) error {
+	if p.Files == nil {
+		p.Files = make(map[string]File)
+	}
+
+	name := path.Join("/", header.Name)
+	f := File{
+		Type:     header.Typeflag,
+		Linkname: header.Linkname,
+		Size:     header.Size,
+	}
+	if opener != nil && header.Size <= maxCapturedFileSize && isCapturedFile(name) {
+		r, err := opener()
+		if err != nil {
+			return err
+		}
+		if f.Contents, err = io.ReadAll(r); err != nil {
+			return err
+		}
+	}
+	p.Files[name] = f
+
+	return nil
+}
+
+// addDigests computes the digests of the given file in the index.
+func (p *ParsedCertificates) addDigests(header *tar.Header, opener rseekerOpener) error {
+	r, err := opener()
+	if err != nil {
+		return err
+	}
+	var (
+		m5   = md5.New()
+		s1   = sha1.New()
+		s256 = sha256.New()
+	)
+	if _, err := io.Copy(io.MultiWriter(m5, s1, s256), r); err != nil {
+		return err
+	}
+
+	var d Digests
+	m5.Sum(d.MD5[:0])
+	s1.Sum(d.SHA1[:0])
+	s256.Sum(d.SHA256[:0])
+
+	name := path.Join("/", header.Name)
+	f := p.Files[name]
+	f.Digests = &d
+	p.Files[name] = f
+
+	return nil
+}
+
+// Resolve follows any symbolic links in the given absolute path within the
+// scanned image, returning the resolved path and whether it exists.
+func (p *ParsedCertificates) Resolve(name string) (string, bool) {
+	resolved := "/"
+	rest := strings.Split(strings.TrimPrefix(path.Clean(path.Join("/", name)), "/"), "/")
+	for links := 0; len(rest) > 0; {
+		next := path.Join(resolved, rest[0])
+		rest = rest[1:]
+
+		f, ok := p.Files[next]
+		if !ok || f.Type != tar.TypeSymlink {
+			resolved = next
+			continue
+		}
+
+		if links++; links > maxSymlinks {
+			return next, false
+		}
+		target := f.Linkname
+		if !path.IsAbs(target) {
+			target = path.Join(resolved, target)
+		}
+		rest = append(strings.Split(strings.TrimPrefix(path.Clean(target), "/"), "/"), rest...)
+		resolved = "/"
+	}
+
+	return resolved, p.exists(resolved)
+}
+
+// exists returns true if the path is a file or directory in the scanned image.
+// Directories don't always have their own entry, so are also found through the
+// files within them.
+func (p *ParsedCertificates) exists(name string) bool {
+	if name == "/" {
+		return true
+	}
+	if _, ok := p.Files[name]; ok {
+		return true
+	}
+	prefix := name + "/"
+	for f := range p.Files {
+		if strings.HasPrefix(f, prefix) {
+			return true
+		}
+	}
+	return false
+}
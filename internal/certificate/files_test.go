@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package certificate
+
+import (
+	"archive/tar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsedCertificates_Resolve(t *testing.T) {
+	parsed := &ParsedCertificates{Files: map[string]File{
+		"/etc/ssl/certs/ca-certificates.crt": {Type: tar.TypeReg},
+		"/etc/ssl/cert.pem":                  {Type: tar.TypeSymlink, Linkname: "certs/ca-certificates.crt"},
+		"/etc/pki/tls":                       {Type: tar.TypeSymlink, Linkname: "/etc/ssl"},
+		"/etc/loop":                          {Type: tar.TypeSymlink, Linkname: "loop"},
+		"/etc/dangling":                      {Type: tar.TypeSymlink, Linkname: "/nowhere"},
+	}}
+
+	tests := map[string]struct {
+		name     string
+		expPath  string
+		expFound bool
+	}{
+		"regular file":          {name: "/etc/ssl/certs/ca-certificates.crt", expPath: "/etc/ssl/certs/ca-certificates.crt", expFound: true},
+		"directory":             {name: "/etc/ssl/certs", expPath: "/etc/ssl/certs", expFound: true},
+		"relative symlink":      {name: "/etc/ssl/cert.pem", expPath: "/etc/ssl/certs/ca-certificates.crt", expFound: true},
+		"symlinked directory":   {name: "/etc/pki/tls/cert.pem", expPath: "/etc/ssl/certs/ca-certificates.crt", expFound: true},
+		"unclean path":          {name: "etc/ssl/../ssl/./cert.pem", expPath: "/etc/ssl/certs/ca-certificates.crt", expFound: true},
+		"dangling symlink":      {name: "/etc/dangling", expPath: "/nowhere", expFound: false},
+		"missing file":          {name: "/etc/missing", expPath: "/etc/missing", expFound: false},
+		"symlink loop gives up": {name: "/etc/loop", expPath: "/etc/loop", expFound: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path, found := parsed.Resolve(test.name)
+			assert.Equal(t, test.expPath, path)
+			assert.Equal(t, test.expFound, found)
+		})
+	}
+}
+
+func Test_isCapturedFile(t *testing.T) {
+	assert.True(t, isCapturedFile("/etc/ca-certificates.conf"))
+	assert.True(t, isCapturedFile("/usr/share/pki/ca-trust-source/ca-bundle.trust.p11-kit"))
+	assert.False(t, isCapturedFile("/etc/ssl/certs/ca-certificates.crt"))
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package certificate
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	encpem "encoding/pem"
+	"strings"
+)
+
+// PrivateKey is a private key which was found by a parser inside the given
+// image. Private keys should never be shipped in an image alongside
+// certificates, so finding one is always worth investigating.
+type PrivateKey struct {
+	// Location is the filepath location where the private key was found.
+	Location string
+
+	// Parser is the name of the parser which discovered the private key.
+	Parser string
+
+	// Format is the encoding of the private key, either PKCS#8, PKCS#1 or
+	// SEC 1.
+	Format string
+
+	// Type is the algorithm of the private key, such as RSA or ECDSA. Empty if
+	// the key is encrypted or could not be parsed.
+	Type string
+
+	// Size is the size of the private key in bits. Zero if unknown.
+	Size int
+
+	// Encrypted is true if the private key is protected by a passphrase.
+	Encrypted bool
+
+	// SPKISHA256 is the SHA-256 hash of the DER encoded public key matching the
+	// private key. Zero if the key is encrypted or could not be parsed.
+	SPKISHA256 [32]byte
+
+	// MatchingCertificates are the SHA-256 fingerprints of found certificates
+	// whose public key matches this private key.
+	MatchingCertificates [][32]byte
+}
+
+// privateKeyFormats maps the PEM labels of private keys to their encoding.
+var privateKeyFormats = map[string]string{
+	"PRIVATE KEY":           "PKCS#8",
+	"ENCRYPTED PRIVATE KEY": "PKCS#8",
+	"RSA PRIVATE KEY":       "PKCS#1",
+	"EC PRIVATE KEY":        "SEC 1",
+}
+
+// parsePrivateKeyBlock decodes a PEM block found by the pem parser as a
+// private key.
+// Exactly one of the returned values is non-nil. Keys which can be PEM decoded
+// are always returned, even if the key itself cannot be parsed.
+func parsePrivateKeyBlock(location, label string, data []byte, complete bool) (*PrivateKey, *Partial) {
+	partial := &Partial{
+		Location: location,
+		Parser:   "pem",
+	}
+	if !complete {
+		partial.Reason = "found start of PEM encoded private key, but could not find end"
+		return nil, partial
+	}
+	block, _ := encpem.Decode(data)
+	if block == nil {
+		partial.Reason = "a block of data looks like a PEM private key, but cannot be decoded"
+		return nil, partial
+	}
+
+	key := &PrivateKey{
+		Location: location,
+		Parser:   "pem",
+		Format:   privateKeyFormats[label],
+	}
+
+	// Legacy OpenSSL encryption is signalled by PEM headers, whereas PKCS#8
+	// has its own label.
+	if label == "ENCRYPTED PRIVATE KEY" || strings.Contains(block.Headers["Proc-Type"], "ENCRYPTED") {
+		key.Encrypted = true
+		return key, nil
+	}
+
+	var (
+		priv any
+		err  error
+	)
+	switch label {
+	case "PRIVATE KEY":
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		priv, err = x509.ParseECPrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return key, nil
+	}
+
+	switch k := priv.(type) {
+	case *rsa.PrivateKey:
+		key.Type = x509.RSA.String()
+	case *ecdsa.PrivateKey:
+		key.Type = x509.ECDSA.String()
+	case ed25519.PrivateKey:
+		key.Type = x509.Ed25519.String()
+	case *ecdh.PrivateKey:
+		key.Type = "X25519"
+		key.Size = len(k.PublicKey().Bytes()) * 8
+	}
+
+	if signer, ok := priv.(interface{ Public() crypto.PublicKey }); ok {
+		pub := signer.Public()
+		if key.Size == 0 {
+			key.Size = PublicKeySize(pub)
+		}
+		if der, err := x509.MarshalPKIXPublicKey(pub); err == nil {
+			key.SPKISHA256 = sha256.Sum256(der)
+		}
+	}
+
+	return key, nil
+}
+
+// matchPrivateKeys records which of the found certificates match each of the
+// found private keys.
+func (p *ParsedCertificates) matchPrivateKeys() {
+	for i, key := range p.PrivateKeys {
+		if key.SPKISHA256 == ([32]byte{}) {
+			continue
+		}
+		for _, found := range p.Found {
+			if sha256.Sum256(found.Certificate.RawSubjectPublicKeyInfo) == key.SPKISHA256 {
+				p.PrivateKeys[i].MatchingCertificates = append(p.PrivateKeys[i].MatchingCertificates, found.FingerprintSha256)
+			}
+		}
+	}
+}
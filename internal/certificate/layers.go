@@ -0,0 +1,258 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package certificate
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	whiteoutPrefix = ".wh."
+	whiteoutOpaque = whiteoutPrefix + whiteoutPrefix + ".opq"
+)
+
+// Layer is the result of scanning a single layer of a container image, before
+// it is merged with the layers beneath it.
+type Layer struct {
+	// Parsed are the certificates found in the layer, and its file index.
+	Parsed *ParsedCertificates
+
+	// Whiteouts are the absolute paths the layer deletes from the layers
+	// beneath it.
+	Whiteouts []string
+
+	// OpaqueDirs are the absolute paths of directories whose contents in the
+	// layers beneath are hidden by the layer.
+	OpaqueDirs []string
+}
+
+// FindLayerCertificates scans a single image layer, given as its uncompressed
+// TAR file, for certificates. Whiteout entries are recorded, rather than
+// scanned.
+func FindLayerCertificates(ctx context.Context, layerTar io.Reader) (*Layer, error) {
+	l := &Layer{}
+	parsed, err := findCertificates(ctx, layerTar, func(header *tar.Header) bool {
+		name := path.Join("/", header.Name)
+		dir, base := path.Split(name)
+		switch {
+		case base == whiteoutOpaque:
+			l.OpaqueDirs = append(l.OpaqueDirs, path.Clean(dir))
+		case strings.HasPrefix(base, whiteoutPrefix):
+			l.Whiteouts = append(l.Whiteouts, path.Join(dir, strings.TrimPrefix(base, whiteoutPrefix)))
+		default:
+			return false
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.Parsed = parsed
+	return l, nil
+}
+
+// MergeLayers computes the certificates found in an image from those found in
+// each of its layers, ordered from the base layer up, as if the flattened
+// filesystem of the image had been scanned.
+func MergeLayers(layers []*Layer) *ParsedCertificates {
+	merged := &ParsedCertificates{Files: make(map[string]File)}
+
+	// seen are the paths of the layers merged so far, which hide the same path
+	// in the layers beneath them. Where true, the path is a file or whiteout
+	// which also hides everything within it.
+	seen := make(map[string]bool)
+	var opaque []string
+
+	visible := func(name string) bool {
+		if _, ok := seen[name]; ok {
+			return false
+		}
+		for dir := path.Dir(name); ; dir = path.Dir(dir) {
+			if seen[dir] {
+				return false
+			}
+			if dir == "/" {
+				break
+			}
+		}
+		for _, dir := range opaque {
+			if strings.HasPrefix(name, dir+"/") {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Merge from the top layer down, as the image is flattened.
+	for i := len(layers) - 1; i >= 0; i-- {
+		l := layers[i]
+
+		kept := make(map[string]bool)
+		for name, f := range l.Parsed.Files {
+			if visible(name) {
+				kept[name] = true
+				merged.Files[name] = f
+			}
+		}
+		var whiteouts []string
+		for _, name := range l.Whiteouts {
+			if visible(name) {
+				whiteouts = append(whiteouts, name)
+			}
+		}
+
+		for _, found := range l.Parsed.Found {
+			if kept[found.Location] {
+				merged.Found = append(merged.Found, found)
+			}
+		}
+		for _, partial := range l.Parsed.Partials {
+			if kept[partial.Location] {
+				merged.Partials = append(merged.Partials, partial)
+			}
+		}
+		for _, key := range l.Parsed.PrivateKeys {
+			if kept[key.Location] {
+				key.MatchingCertificates = nil
+				merged.PrivateKeys = append(merged.PrivateKeys, key)
+			}
+		}
+		for _, crl := range l.Parsed.CRLs {
+			if kept[crl.Location] {
+				merged.CRLs = append(merged.CRLs, crl)
+			}
+		}
+		for _, req := range l.Parsed.CertificateRequests {
+			if kept[req.Location] {
+				merged.CertificateRequests = append(merged.CertificateRequests, req)
+			}
+		}
+
+		for name := range kept {
+			seen[name] = l.Parsed.Files[name].Type != tar.TypeDir
+		}
+		for _, name := range whiteouts {
+			seen[name] = true
+		}
+		opaque = append(opaque, l.OpaqueDirs...)
+	}
+
+	merged.matchPrivateKeys()
+
+	return merged
+}
+
+// encodedLayer is the encoding of a Layer, with certificates, CRLs and
+// requests stored as DER and parsed again when decoded.
+type encodedLayer struct {
+	Found               []encodedFound
+	Partials            []Partial
+	PrivateKeys         []PrivateKey
+	CRLs                []encodedDER
+	CertificateRequests []encodedDER
+	Files               map[string]File
+	Whiteouts           []string
+	OpaqueDirs          []string
+}
+
+type encodedFound struct {
+	Location      string
+	Parser        string
+	DER           []byte
+	TrustSettings *TrustSettings
+}
+
+type encodedDER struct {
+	Location string
+	Parser   string
+	DER      []byte
+}
+
+// Encode writes the layer in a form read by DecodeLayer.
+func (l *Layer) Encode(w io.Writer) error {
+	e := encodedLayer{
+		Partials:    l.Parsed.Partials,
+		PrivateKeys: l.Parsed.PrivateKeys,
+		Files:       l.Parsed.Files,
+		Whiteouts:   l.Whiteouts,
+		OpaqueDirs:  l.OpaqueDirs,
+	}
+	for _, found := range l.Parsed.Found {
+		e.Found = append(e.Found, encodedFound{
+			Location:      found.Location,
+			Parser:        found.Parser,
+			DER:           found.Certificate.Raw,
+			TrustSettings: found.TrustSettings,
+		})
+	}
+	for _, crl := range l.Parsed.CRLs {
+		e.CRLs = append(e.CRLs, encodedDER{Location: crl.Location, Parser: crl.Parser, DER: crl.RevocationList.Raw})
+	}
+	for _, req := range l.Parsed.CertificateRequests {
+		e.CertificateRequests = append(e.CertificateRequests, encodedDER{Location: req.Location, Parser: req.Parser, DER: req.Request.Raw})
+	}
+	return gob.NewEncoder(w).Encode(e)
+}
+
+// DecodeLayer reads a layer written by Encode.
+func DecodeLayer(r io.Reader) (*Layer, error) {
+	var e encodedLayer
+	if err := gob.NewDecoder(r).Decode(&e); err != nil {
+		return nil, err
+	}
+
+	parsed := &ParsedCertificates{
+		Partials:    e.Partials,
+		PrivateKeys: e.PrivateKeys,
+		Files:       e.Files,
+	}
+	for _, f := range e.Found {
+		cert, err := x509.ParseCertificate(f.DER)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate in %s: %w", f.Location, err)
+		}
+		parsed.Found = append(parsed.Found, Found{
+			Location:          f.Location,
+			Parser:            f.Parser,
+			Certificate:       cert,
+			FingerprintSha1:   sha1.Sum(f.DER),
+			FingerprintSha256: sha256.Sum256(f.DER),
+			TrustSettings:     f.TrustSettings,
+		})
+	}
+	for _, c := range e.CRLs {
+		rl, err := x509.ParseRevocationList(c.DER)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse CRL in %s: %w", c.Location, err)
+		}
+		parsed.CRLs = append(parsed.CRLs, CRL{
+			Location:          c.Location,
+			Parser:            c.Parser,
+			RevocationList:    rl,
+			FingerprintSha256: sha256.Sum256(c.DER),
+		})
+	}
+	for _, c := range e.CertificateRequests {
+		req, err := x509.ParseCertificateRequest(c.DER)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate request in %s: %w", c.Location, err)
+		}
+		parsed.CertificateRequests = append(parsed.CertificateRequests, CertificateRequest{
+			Location:          c.Location,
+			Parser:            c.Parser,
+			Request:           req,
+			FingerprintSha256: sha256.Sum256(c.DER),
+		})
+	}
+
+	return &Layer{Parsed: parsed, Whiteouts: e.Whiteouts, OpaqueDirs: e.OpaqueDirs}, nil
+}
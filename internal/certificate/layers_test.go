@@ -0,0 +1,149 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package certificate
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	encpem "encoding/pem"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// layerEntry is an entry in a test layer. Entries with no contents are
+// directories if their name ends in a slash.
+type layerEntry struct {
+	name     string
+	contents string
+}
+
+func scanLayer(t *testing.T, entries ...layerEntry) *Layer {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Typeflag: tar.TypeReg, Size: int64(len(e.contents)), Mode: 0o644}
+		if e.name[len(e.name)-1] == '/' {
+			header.Typeflag, header.Mode = tar.TypeDir, 0o755
+		}
+		require.NoError(t, tw.WriteHeader(header))
+		_, err := tw.Write([]byte(e.contents))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+
+	l, err := FindLayerCertificates(context.Background(), &buf)
+	require.NoError(t, err)
+	return l
+}
+
+func locations(parsed *ParsedCertificates) map[string]int {
+	out := make(map[string]int)
+	for _, found := range parsed.Found {
+		out[found.Location]++
+	}
+	return out
+}
+
+func TestMergeLayers(t *testing.T) {
+	certs, err := os.ReadFile("testdata/test-1")
+	require.NoError(t, err)
+	other, err := os.ReadFile("testdata/test-5")
+	require.NoError(t, err)
+
+	base := scanLayer(t,
+		layerEntry{name: "etc/"},
+		layerEntry{name: "etc/ssl/certs/ca.pem", contents: string(certs)},
+		layerEntry{name: "etc/ssl/certs/removed.pem", contents: string(certs)},
+		layerEntry{name: "opt/app/old.pem", contents: string(certs)},
+		layerEntry{name: "srv/replaced", contents: string(certs)},
+		layerEntry{name: "srv/replaced/nested.pem", contents: string(certs)},
+	)
+	top := scanLayer(t,
+		layerEntry{name: "etc/ssl/certs/.wh.removed.pem"},
+		layerEntry{name: "etc/ssl/certs/ca.pem", contents: string(other)},
+		layerEntry{name: "opt/app/.wh..wh..opq"},
+		layerEntry{name: "opt/app/new.pem", contents: string(certs)},
+		layerEntry{name: "srv/replaced", contents: "a file now"},
+	)
+
+	assert.Equal(t, []string{"/etc/ssl/certs/removed.pem"}, top.Whiteouts)
+	assert.Equal(t, []string{"/opt/app"}, top.OpaqueDirs)
+	assert.NotContains(t, top.Parsed.Files, "/etc/ssl/certs/.wh.removed.pem")
+
+	merged := MergeLayers([]*Layer{base, top})
+	assert.Equal(t, map[string]int{
+		"/etc/ssl/certs/ca.pem": 2,
+		"/opt/app/new.pem":      3,
+	}, locations(merged))
+	assert.Len(t, merged.CRLs, 1)
+	assert.Len(t, merged.CertificateRequests, 2)
+
+	assert.Contains(t, merged.Files, "/etc")
+	assert.Contains(t, merged.Files, "/etc/ssl/certs/ca.pem")
+	assert.NotContains(t, merged.Files, "/etc/ssl/certs/removed.pem")
+	assert.NotContains(t, merged.Files, "/opt/app/old.pem")
+	assert.NotContains(t, merged.Files, "/srv/replaced/nested.pem")
+	assert.Equal(t, int64(len("a file now")), merged.Files["/srv/replaced"].Size)
+
+	// Merging only the base layer gives the same results as scanning it.
+	assert.Equal(t, map[string]int{
+		"/etc/ssl/certs/ca.pem":      3,
+		"/etc/ssl/certs/removed.pem": 3,
+		"/opt/app/old.pem":           3,
+		"/srv/replaced":              3,
+		"/srv/replaced/nested.pem":   3,
+	}, locations(MergeLayers([]*Layer{base})))
+}
+
+func TestEncodeLayer(t *testing.T) {
+	certs, err := os.ReadFile("testdata/test-5")
+	require.NoError(t, err)
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	key := encpem.EncodeToMemory(&encpem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	l := scanLayer(t,
+		layerEntry{name: "etc/ssl/certs/test.pem", contents: string(certs)},
+		layerEntry{name: "etc/ssl/private/test.key", contents: string(key)},
+		layerEntry{name: "etc/os-release", contents: "ID=debian\n"},
+		layerEntry{name: "tmp/.wh.gone"},
+		layerEntry{name: "var/.wh..wh..opq"},
+	)
+	require.NotEmpty(t, l.Parsed.Found)
+	require.NotEmpty(t, l.Parsed.PrivateKeys)
+
+	var buf bytes.Buffer
+	require.NoError(t, l.Encode(&buf))
+	decoded, err := DecodeLayer(&buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, l.Whiteouts, decoded.Whiteouts)
+	assert.Equal(t, l.OpaqueDirs, decoded.OpaqueDirs)
+	assert.Equal(t, l.Parsed.Files, decoded.Parsed.Files)
+	assert.Equal(t, l.Parsed.Partials, decoded.Parsed.Partials)
+	assert.Equal(t, l.Parsed.PrivateKeys, decoded.Parsed.PrivateKeys)
+	require.Len(t, decoded.Parsed.Found, len(l.Parsed.Found))
+	for i, found := range l.Parsed.Found {
+		assert.Equal(t, found.Location, decoded.Parsed.Found[i].Location)
+		assert.Equal(t, found.FingerprintSha1, decoded.Parsed.Found[i].FingerprintSha1)
+		assert.Equal(t, found.FingerprintSha256, decoded.Parsed.Found[i].FingerprintSha256)
+		assert.True(t, found.Certificate.Equal(decoded.Parsed.Found[i].Certificate))
+	}
+	require.Len(t, decoded.Parsed.CRLs, len(l.Parsed.CRLs))
+	assert.Equal(t, l.Parsed.CRLs[0].FingerprintSha256, decoded.Parsed.CRLs[0].FingerprintSha256)
+	require.Len(t, decoded.Parsed.CertificateRequests, len(l.Parsed.CertificateRequests))
+	assert.Equal(t, l.Parsed.CertificateRequests[0].FingerprintSha256, decoded.Parsed.CertificateRequests[0].FingerprintSha256)
+
+	_, err = DecodeLayer(bytes.NewReader([]byte("not a layer")))
+	assert.Error(t, err)
+}
@@ -8,196 +8,398 @@ import (
 	"crypto/sha1"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/asn1"
 	encpem "encoding/pem"
 	"errors"
 	"fmt"
 	"io"
+	"strings"
 )
 
 type pem struct{}
 
-// Find finds X.509 PEM encoded certificates in the given reader. It does this
-// by greping through the input and attempting to find the PEM Certificate
-// header. Once found, it attempts to find the end footer. Even if the end
-// footer is not found, a Certificate is still recorded, but marked as not
-// correctly decoded.
+var (
+	// pemIgnored are the tokens skipped when matching PEM headers and footers,
+	// so that malformed blocks can still be found.
+	pemIgnored = []byte{'\n', '\t', '\r', ' ', '\f', '\v', '\b', '\x00', '"', '\''}
+	pemBegin   = []byte("-----BEGIN")
+	pemEnd     = []byte("-----END")
+	pemDashes  = []byte("-----")
+)
+
+// maxPEMLabelLength is the longest PEM label we will attempt to match.
+const maxPEMLabelLength = 64
+
+// pemLabels are the PEM block labels the pem parser handles, along with the
+// kind of object each contains.
+var pemLabels = map[string]string{
+	"CERTIFICATE":             "certificate",
+	"X509 CERTIFICATE":        "certificate",
+	"TRUSTED CERTIFICATE":     "certificate",
+	"X509 CRL":                "CRL",
+	"CERTIFICATE REQUEST":     "certificate request",
+	"NEW CERTIFICATE REQUEST": "certificate request",
+}
+
+// Find finds X.509 PEM encoded certificates, CRLs, certificate requests and
+// private keys in the given reader. It does this by greping through the input and attempting
+// to find a PEM header with a known label. Once found, it attempts to find the
+// matching end footer. Even if the end footer is not found, a Partial is still
+// recorded.
 func (_ pem) Find(ctx context.Context, location string, rs rseekerOpener) (*ParsedCertificates, error) {
-	ignored := []byte{'\n', '\t', '\r', ' ', '\f', '\v', '\b', '\x00', '"', '\''}
-	pemStart := []byte("-----BEGIN CERTIFICATE-----")
-	pemEnd := []byte("-----END CERTIFICATE-----")
+	parsed := &ParsedCertificates{}
 
-	file, err := rs()
+	labels := make([]string, 0, len(pemLabels)+len(privateKeyFormats))
+	for label := range pemLabels {
+		labels = append(labels, label)
+	}
+	for label := range privateKeyFormats {
+		labels = append(labels, label)
+	}
+
+	err := scanPEM(ctx, rs, labels, func(label string, data []byte, complete bool) {
+		if _, ok := privateKeyFormats[label]; ok {
+			key, partial := parsePrivateKeyBlock(location, label, data, complete)
+			if key != nil {
+				parsed.PrivateKeys = append(parsed.PrivateKeys, *key)
+			} else {
+				parsed.Partials = append(parsed.Partials, *partial)
+			}
+			return
+		}
+
+		partial := Partial{
+			Location: location,
+			Parser:   "pem",
+		}
+		if !complete {
+			partial.Reason = fmt.Sprintf("found start of PEM encoded %s, but could not find end", pemLabels[label])
+			parsed.Partials = append(parsed.Partials, partial)
+			return
+		}
+		block, _ := encpem.Decode(data)
+		if block == nil {
+			partial.Reason = fmt.Sprintf("a block of data looks like a PEM %s, but cannot be decoded", pemLabels[label])
+			parsed.Partials = append(parsed.Partials, partial)
+			return
+		}
+
+		var err error
+		switch label {
+		case "CERTIFICATE", "X509 CERTIFICATE", "TRUSTED CERTIFICATE":
+			var found *Found
+			found, err = parseCertificateBlock(location, block)
+			if err == nil {
+				parsed.Found = append(parsed.Found, *found)
+			}
+		case "X509 CRL":
+			var crl *x509.RevocationList
+			crl, err = x509.ParseRevocationList(block.Bytes)
+			if err == nil {
+				parsed.CRLs = append(parsed.CRLs, CRL{
+					Location:          location,
+					Parser:            "pem",
+					RevocationList:    crl,
+					FingerprintSha256: sha256.Sum256(block.Bytes),
+				})
+			}
+		case "CERTIFICATE REQUEST", "NEW CERTIFICATE REQUEST":
+			var csr *x509.CertificateRequest
+			csr, err = x509.ParseCertificateRequest(block.Bytes)
+			if err == nil {
+				parsed.CertificateRequests = append(parsed.CertificateRequests, CertificateRequest{
+					Location:          location,
+					Parser:            "pem",
+					Request:           csr,
+					FingerprintSha256: sha256.Sum256(block.Bytes),
+				})
+			}
+		}
+		if err != nil {
+			partial.Reason = fmt.Sprintf("failed to parse PEM %s: %s", pemLabels[label], err)
+			parsed.Partials = append(parsed.Partials, partial)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// parseCertificateBlock parses a PEM block containing an X.509 certificate.
+// OpenSSL trusted certificates are followed by auxiliary trust settings, which
+// are returned with the certificate when they can be parsed.
+func parseCertificateBlock(location string, block *encpem.Block) (*Found, error) {
+	der := block.Bytes
+	var aux []byte
+	if block.Type == "TRUSTED CERTIFICATE" {
+		var raw asn1.RawValue
+		rest, err := asn1.Unmarshal(block.Bytes, &raw)
+		if err != nil {
+			return nil, err
+		}
+		der, aux = raw.FullBytes, rest
+	}
+
+	cert, err := x509.ParseCertificate(der)
 	if err != nil {
 		return nil, err
 	}
 
+	found := &Found{
+		Location:          location,
+		Parser:            "pem",
+		Certificate:       cert,
+		FingerprintSha1:   sha1.Sum(der),
+		FingerprintSha256: sha256.Sum256(der),
+	}
+	if len(aux) > 0 {
+		found.TrustSettings = parseTrustSettings(aux)
+	}
+	return found, nil
+}
+
+// certAux is OpenSSL's X509_CERT_AUX structure, which follows the certificate
+// in a trusted certificate.
+type certAux struct {
+	Trust  []asn1.ObjectIdentifier `asn1:"optional"`
+	Reject []asn1.ObjectIdentifier `asn1:"optional,tag:0"`
+	Alias  string                  `asn1:"optional,utf8"`
+	KeyID  []byte                  `asn1:"optional"`
+	Other  asn1.RawValue           `asn1:"optional,tag:1"`
+}
+
+// extKeyUsageOIDs maps the object identifiers of extended key usages, as
+// found in trust settings, to the usages named by ExtKeyUsageNames.
+var extKeyUsageOIDs = map[string]x509.ExtKeyUsage{
+	"2.5.29.37.0":            x509.ExtKeyUsageAny,
+	"1.3.6.1.5.5.7.3.1":      x509.ExtKeyUsageServerAuth,
+	"1.3.6.1.5.5.7.3.2":      x509.ExtKeyUsageClientAuth,
+	"1.3.6.1.5.5.7.3.3":      x509.ExtKeyUsageCodeSigning,
+	"1.3.6.1.5.5.7.3.4":      x509.ExtKeyUsageEmailProtection,
+	"1.3.6.1.5.5.7.3.5":      x509.ExtKeyUsageIPSECEndSystem,
+	"1.3.6.1.5.5.7.3.6":      x509.ExtKeyUsageIPSECTunnel,
+	"1.3.6.1.5.5.7.3.7":      x509.ExtKeyUsageIPSECUser,
+	"1.3.6.1.5.5.7.3.8":      x509.ExtKeyUsageTimeStamping,
+	"1.3.6.1.5.5.7.3.9":      x509.ExtKeyUsageOCSPSigning,
+	"1.3.6.1.4.1.311.10.3.3": x509.ExtKeyUsageMicrosoftServerGatedCrypto,
+	"2.16.840.1.113730.4.1":  x509.ExtKeyUsageNetscapeServerGatedCrypto,
+	"1.3.6.1.4.1.311.2.1.22": x509.ExtKeyUsageMicrosoftCommercialCodeSigning,
+	"1.3.6.1.4.1.311.61.1.1": x509.ExtKeyUsageMicrosoftKernelCodeSigning,
+}
+
+// parseTrustSettings parses the DER encoded auxiliary data of a trusted
+// certificate. Returns nil if the data is malformed.
+func parseTrustSettings(der []byte) *TrustSettings {
+	var aux certAux
+	if _, err := asn1.Unmarshal(der, &aux); err != nil {
+		return nil
+	}
+
+	names := func(oids []asn1.ObjectIdentifier) []string {
+		var out []string
+		for _, oid := range oids {
+			if eku, ok := extKeyUsageOIDs[oid.String()]; ok {
+				out = append(out, ExtKeyUsageNames[eku])
+			} else {
+				out = append(out, oid.String())
+			}
+		}
+		return out
+	}
+
+	return &TrustSettings{
+		Trusted:  names(aux.Trust),
+		Rejected: names(aux.Reject),
+		Alias:    aux.Alias,
+	}
+}
+
+// scanPEM scans the reader for PEM blocks with one of the given labels. For
+// each block found, fn is called with the label and the PEM encoded block. If
+// the end footer of a block could not be found, fn is called with complete
+// set to false and the file is re-scanned from just after the header.
+//
+// Whitespace and quotes are ignored while matching headers and footers, so
+// that blocks embedded in source code or split across lines are still found.
+func scanPEM(ctx context.Context, rs rseekerOpener, labels []string, fn func(label string, block []byte, complete bool)) error {
+	// Labels are matched with their spaces removed, since spaces are ignored
+	// while scanning.
+	byMatch := make(map[string]string, len(labels))
+	for _, l := range labels {
+		byMatch[strings.ReplaceAll(l, " ", "")] = l
+	}
+
+	file, err := rs()
+	if err != nil {
+		return err
+	}
+
 	var (
 		// token is the single token buffer we use to scan each file.
 		token = make([]byte, 1)
-		// results is the end result of the found certificates for this file
-		// location.
-		results []Found
-		// partials is the list of partial certificates found
-		partials []Partial
-		// Current is the current successfully decoded certificate buffer. Starts
-		// empty until we start to scan with a successful header.
+		// current is the matched portion of the PEM header.
 		current []byte
 	)
 	for {
 		// Read a single token from the file. Exit scanning if we reach the end of
 		// the file.
-		_, err := file.Read(token)
-		if errors.Is(err, io.EOF) {
+		if err := readToken(ctx, file, token); errors.Is(err, io.EOF) {
 			break
-		}
-
-		// Exit if we encounter an error reading from file.
-		if err != nil {
-			return nil, err
-		}
-
-		// If  context has been cancelled, exit scanning.
-		select {
-		case <-ctx.Done():
-			return nil, ctx.Err()
-		default:
+		} else if err != nil {
+			return err
 		}
 
 		// We ignore space tokens so allow for correctly scanning malformed
-		// certificates.
-		if bytes.Contains(ignored, token) {
+		// blocks.
+		if bytes.Contains(pemIgnored, token) {
 			continue
 		}
 
-		if token[0] == pemStart[len(current)] {
+		if token[0] == pemBegin[len(current)] {
 			// If the scanned token matches the current PEM start token, we append to
 			// the current.
 			current = append(current, token[0])
+		} else if token[0] == pemBegin[0] {
+			// Did not match, but may be the start of a new header.
+			current = append(current[:0], token[0])
 		} else {
 			// Did not match, reset current to empty.
 			current = current[:0]
 		}
 
-		if len(current) == 10 {
-			// Make sure we add the space character from PEM start since those get
-			// ignored.
-			current = append(current, ' ')
-		}
-
-		// If we have the PEM header, then we can start to scan for the footer.
-		if len(current) == len(pemStart) {
-			// footer is the buffer we use to match on the PEM footer.
-			var footer []byte
-
-			for {
-				// Check errors and return/break appropriately.
-				_, err := file.Read(token)
-				if errors.Is(err, io.EOF) {
-					break
-				}
-				if err != nil {
-					return nil, err
-				}
-
-				// Again, check context.
-				select {
-				case <-ctx.Done():
-					return nil, ctx.Err()
-				default:
-				}
-
-				// continue for ignored characters.
-				if bytes.Contains(ignored, token) {
-					// Append if we haven't reached the footer yet, or need to add the
-					// space character.
-					if len(footer) == 0 || len(footer) == 9 {
-						current = append(current, token[0])
-					}
-					continue
-				}
-
-				// Always append to current to catch all certificate data.
-				current = append(current, token[0])
-
-				// Check for PEM footer character, or reset footer.
-				if token[0] == pemEnd[len(footer)] {
-					footer = append(footer, token[0])
-				} else {
-					footer = footer[:0]
-				}
-
-				// Add in the space character we ignore.
-				if len(footer) == 8 {
-					footer = append(footer, ' ')
-				}
-
-				// If the length of footer matches the PEM footer, then we have scanned
-				// a certificate.
-				if len(footer) == len(pemEnd) {
-					//current = append(current, footer...)
-					break
-				}
-			}
+		if len(current) < len(pemBegin) {
+			continue
+		}
+		current = current[:0]
 
-			// Here we stopped scanning for the footer. This might be because we got
-			// to the end of the file, or we matched on the footer.
-
-			var (
-				valid    = false
-				reason   string
-				cert     *x509.Certificate
-				fpsha1   [20]byte
-				fpsha256 [32]byte
-			)
-
-			// If we did match on the footer, then attempt to decode the actual
-			// certificate.
-			if len(footer) == len(pemEnd) {
-				block, _ := encpem.Decode(current)
-
-				if block == nil {
-					reason = fmt.Sprintf("a block of data looks like a PEM certificate, but cannot be decoded")
-				} else {
-					cert, err = x509.ParseCertificate(block.Bytes)
-					if err != nil {
-						reason = fmt.Sprintf("failed to parse PEM certificate: %s", err)
-					} else {
-						fpsha1 = sha1.Sum(block.Bytes)
-						fpsha256 = sha256.Sum256(block.Bytes)
-						valid = true
-					}
-				}
-			} else {
-				// If we didn't actually decode an entire certificate, then set an
-				// appropriate reason, and reset the file so we can re-scan.
-				reason = "found start of PEM encoded certificate, but could not find end"
-				if _, err := file.Seek(-int64(len(current)-len(pemStart)+1), io.SeekCurrent); err != nil {
-					return nil, fmt.Errorf("failed to seek: %w", err)
-				}
+		// We have the start of the PEM header, so read the label.
+		match, err := readPEMLabel(ctx, file, token)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return err
+		}
+		label, ok := byMatch[match]
+		if !ok {
+			continue
+		}
+
+		// We have the PEM header, so we can start to scan for the footer.
+		footer := append(append(append([]byte{}, pemEnd...), match...), pemDashes...)
+		body, read, complete, err := readPEMBody(ctx, file, token, footer)
+		if err != nil {
+			return err
+		}
+
+		if !complete {
+			// If we didn't find the end of the block, reset the file so we can
+			// re-scan everything after the header.
+			if _, err := file.Seek(-read, io.SeekCurrent); err != nil {
+				return fmt.Errorf("failed to seek: %w", err)
 			}
+		}
 
-			// Capture result.
-			if valid {
-				results = append(results, Found{
-					Location:          location,
-					Parser:            "pem",
-					Certificate:       cert,
-					FingerprintSha1:   fpsha1,
-					FingerprintSha256: fpsha256,
-				})
-			} else {
-				partials = append(partials, Partial{
-					Location: location,
-					Parser:   "pem",
-					Reason:   reason,
-				})
+		var block bytes.Buffer
+		block.WriteString("-----BEGIN " + label + "-----")
+		if len(body) == 0 || (body[0] != '\n' && body[0] != '\r') {
+			block.WriteByte('\n')
+		}
+		block.Write(body)
+		block.WriteString("\n-----END " + label + "-----\n")
+
+		fn(label, block.Bytes(), complete)
+	}
+
+	return nil
+}
+
+// readToken reads a single token from the reader, returning early if the
+// context has been cancelled.
+func readToken(ctx context.Context, r io.Reader, token []byte) error {
+	if _, err := r.Read(token); err != nil {
+		return err
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	return nil
+}
+
+// readPEMLabel reads a PEM label, up to and including the five dashes which
+// end the header. Ignored tokens are skipped, so the returned label has no
+// spaces. An empty label is returned if the tokens read can't be a label.
+func readPEMLabel(ctx context.Context, r io.Reader, token []byte) (string, error) {
+	var (
+		label  []byte
+		dashes int
+	)
+	for {
+		if err := readToken(ctx, r, token); err != nil {
+			return "", err
+		}
+		if bytes.Contains(pemIgnored, token) {
+			continue
+		}
+
+		switch c := token[0]; {
+		case c == '-':
+			dashes++
+			if dashes == len(pemDashes) {
+				return string(label), nil
 			}
-			current = current[:0]
+		case dashes == 0 && len(label) < maxPEMLabelLength && (c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'):
+			label = append(label, c)
+		default:
+			return "", nil
 		}
 	}
+}
+
+// readPEMBody reads everything up to the given PEM footer. The returned body
+// excludes the footer, and read is the number of bytes read from the reader.
+// If the end of the reader is reached first, complete is false.
+func readPEMBody(ctx context.Context, r io.Reader, token, footer []byte) (body []byte, read int64, complete bool, err error) {
+	var (
+		// matched is the number of footer tokens matched so far.
+		matched int
+		// footerStart is the position in body where the footer starts.
+		footerStart int
+	)
+	for {
+		if err := readToken(ctx, r, token); errors.Is(err, io.EOF) {
+			return body, read, false, nil
+		} else if err != nil {
+			return nil, read, false, err
+		}
+		read++
+
+		body = append(body, token[0])
+
+		// Ignored tokens are kept in the body, but skipped when matching the
+		// footer.
+		if bytes.Contains(pemIgnored, token) {
+			continue
+		}
+
+		if token[0] == footer[matched] {
+			if matched == 0 {
+				footerStart = len(body) - 1
+			}
+			matched++
+		} else if token[0] == footer[0] {
+			footerStart, matched = len(body)-1, 1
+		} else {
+			matched = 0
+		}
 
-	return &ParsedCertificates{
-		Found:    results,
-		Partials: partials,
-	}, nil
+		if matched == len(footer) {
+			return body[:footerStart], read, true, nil
+		}
+	}
 }
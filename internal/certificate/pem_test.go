@@ -16,6 +16,8 @@ func Test_x509pem(t *testing.T) {
 		file              string
 		expSubjects       []string
 		expPartialReasons []string
+		expCRLIssuers     []string
+		expCSRSubjects    []string
 	}{
 		"simple certificate list should parse": {
 			file: "testdata/test-1",
@@ -55,6 +57,20 @@ func Test_x509pem(t *testing.T) {
 				"failed to parse PEM certificate: x509: malformed certificate",
 			},
 		},
+		"other PEM block types should be found": {
+			file: "testdata/test-5",
+			expSubjects: []string{
+				"CN=Paranoia Test CA",
+				"CN=leaf.example.com",
+			},
+			expCRLIssuers: []string{
+				"CN=Paranoia Test CA",
+			},
+			expCSRSubjects: []string{
+				"CN=leaf.example.com",
+				"CN=leaf.example.com",
+			},
+		},
 	}
 
 	for name, test := range tests {
@@ -85,6 +101,43 @@ func Test_x509pem(t *testing.T) {
 				partialsReasons = append(partialsReasons, r.Reason)
 			}
 			assert.ElementsMatch(t, test.expPartialReasons, partialsReasons)
+
+			var crlIssuers []string
+			for _, r := range parsedCerts.CRLs {
+				assert.Equal(t, test.file, r.Location)
+				crlIssuers = append(crlIssuers, r.RevocationList.Issuer.String())
+			}
+			assert.ElementsMatch(t, test.expCRLIssuers, crlIssuers)
+
+			var csrSubjects []string
+			for _, r := range parsedCerts.CertificateRequests {
+				assert.Equal(t, test.file, r.Location)
+				csrSubjects = append(csrSubjects, r.Request.Subject.String())
+			}
+			assert.ElementsMatch(t, test.expCSRSubjects, csrSubjects)
 		})
 	}
 }
+
+func Test_x509pem_trustedCertificate(t *testing.T) {
+	f, err := os.ReadFile("testdata/test-5")
+	require.NoError(t, err)
+
+	parsedCerts, err := (pem{}).Find(context.TODO(), "test-5", func() (io.ReadSeeker, error) {
+		return bytes.NewReader(f), nil
+	})
+	require.NoError(t, err)
+
+	trust := make(map[string]*TrustSettings)
+	for _, r := range parsedCerts.Found {
+		trust[r.Certificate.Subject.String()] = r.TrustSettings
+	}
+	assert.Equal(t, map[string]*TrustSettings{
+		"CN=Paranoia Test CA": {
+			Trusted:  []string{"serverAuth", "clientAuth"},
+			Rejected: []string{"emailProtection"},
+			Alias:    "Paranoia Test CA",
+		},
+		"CN=leaf.example.com": nil,
+	}, trust)
+}
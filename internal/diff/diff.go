@@ -0,0 +1,168 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package diff compares the certificates found in two container images, such
+// as before and after a base image update.
+package diff
+
+import (
+	"slices"
+	"sort"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// Kind is the kind of change to a certificate between two images.
+type Kind string
+
+const (
+	// KindAdded is a certificate only in the second image.
+	KindAdded Kind = "added"
+	// KindRemoved is a certificate only in the first image.
+	KindRemoved Kind = "removed"
+	// KindMoved is a certificate in both images, but in different files.
+	KindMoved Kind = "moved"
+	// KindChanged is a certificate replaced by one with the same subject, but
+	// a different fingerprint, such as a renewed certificate authority.
+	KindChanged Kind = "changed"
+)
+
+// kindOrder is the order changes are reported in.
+var kindOrder = map[Kind]int{
+	KindAdded:   0,
+	KindChanged: 1,
+	KindRemoved: 2,
+	KindMoved:   3,
+}
+
+// Change is a difference in a certificate between two images.
+type Change struct {
+	Kind Kind
+	// Before is the certificate in the first image, and nil if it was added.
+	Before *Side
+	// After is the certificate in the second image, and nil if it was removed.
+	After *Side
+}
+
+// Side is a certificate in one of the compared images, and every file it was
+// found in.
+type Side struct {
+	Found     certificate.Found
+	Locations []string
+}
+
+// Subject returns the subject of the changed certificate.
+func (c Change) Subject() string {
+	if c.After != nil {
+		return c.After.Found.Certificate.Subject.String()
+	}
+	return c.Before.Found.Certificate.Subject.String()
+}
+
+// AddsTrustAnchor returns true if the change introduces a trust anchor, which
+// is a self-signed certificate authority, to the second image.
+func (c Change) AddsTrustAnchor() bool {
+	if c.Kind != KindAdded && c.Kind != KindChanged {
+		return false
+	}
+	return certificate.Classify(c.After.Found.Certificate) == certificate.ClassificationRoot
+}
+
+// AddsTrustAnchor returns true if any of the changes introduce a trust anchor.
+func AddsTrustAnchor(changes []Change) bool {
+	for _, c := range changes {
+		if c.AddsTrustAnchor() {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff returns the changes to the certificates found in the first image,
+// before, compared to the second image, after. Certificates are identified by
+// their SHA-256 fingerprint.
+func Diff(before, after *certificate.ParsedCertificates) []Change {
+	b, a := sides(before), sides(after)
+	bIndex, aIndex := index(b), index(a)
+
+	var (
+		changes []Change
+		added   []*Side
+		removed []*Side
+	)
+	for _, s := range a {
+		prev, ok := bIndex[s.Found.FingerprintSha256]
+		switch {
+		case !ok:
+			added = append(added, s)
+		case !slices.Equal(prev.Locations, s.Locations):
+			changes = append(changes, Change{Kind: KindMoved, Before: prev, After: s})
+		}
+	}
+	for _, s := range b {
+		if _, ok := aIndex[s.Found.FingerprintSha256]; !ok {
+			removed = append(removed, s)
+		}
+	}
+
+	// Pair added and removed certificates with the same subject as changed.
+	for _, s := range added {
+		subject := s.Found.Certificate.Subject.String()
+		i := -1
+		for j, r := range removed {
+			if r.Found.Certificate.Subject.String() == subject {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			changes = append(changes, Change{Kind: KindAdded, After: s})
+			continue
+		}
+		changes = append(changes, Change{Kind: KindChanged, Before: removed[i], After: s})
+		removed = append(removed[:i], removed[i+1:]...)
+	}
+	for _, s := range removed {
+		changes = append(changes, Change{Kind: KindRemoved, Before: s})
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Kind != changes[j].Kind {
+			return kindOrder[changes[i].Kind] < kindOrder[changes[j].Kind]
+		}
+		return changes[i].Subject() < changes[j].Subject()
+	})
+
+	return changes
+}
+
+// sides groups the certificates found by fingerprint, in the order they were
+// first found.
+func sides(parsed *certificate.ParsedCertificates) []*Side {
+	var (
+		result []*Side
+		byFP   = make(map[[32]byte]*Side)
+	)
+	for _, found := range parsed.Found {
+		s, ok := byFP[found.FingerprintSha256]
+		if !ok {
+			s = &Side{Found: found}
+			byFP[found.FingerprintSha256] = s
+			result = append(result, s)
+		}
+		if !slices.Contains(s.Locations, found.Location) {
+			s.Locations = append(s.Locations, found.Location)
+		}
+	}
+	for _, s := range result {
+		sort.Strings(s.Locations)
+	}
+	return result
+}
+
+func index(sides []*Side) map[[32]byte]*Side {
+	m := make(map[[32]byte]*Side, len(sides))
+	for _, s := range sides {
+		m[s.Found.FingerprintSha256] = s
+	}
+	return m
+}
@@ -0,0 +1,102 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package diff
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/certificate/certificatetest"
+)
+
+// found returns a certificate with the given subject, distinguished by its
+// contents. Roots are self-signed certificate authorities, and all others are
+// leaves.
+func found(subject, contents, location string, root bool) certificate.Found {
+	f := certificatetest.Leaf(subject, location)
+	if root {
+		f = certificatetest.Root(subject, location)
+	}
+	f.FingerprintSha256 = sha256.Sum256([]byte(contents))
+	return f
+}
+
+func TestDiff(t *testing.T) {
+	before := &certificate.ParsedCertificates{Found: []certificate.Found{
+		found("Kept", "kept", "/etc/ssl/certs/ca-certificates.crt", true),
+		found("Moved", "moved", "/etc/ssl/certs/ca-certificates.crt", true),
+		found("Renewed", "renewed-old", "/etc/ssl/certs/ca-certificates.crt", true),
+		found("Removed", "removed", "/etc/ssl/certs/ca-certificates.crt", true),
+	}}
+	after := &certificate.ParsedCertificates{Found: []certificate.Found{
+		found("Kept", "kept", "/etc/ssl/certs/ca-certificates.crt", true),
+		found("Moved", "moved", "/etc/pki/tls/certs/ca-bundle.crt", true),
+		found("Renewed", "renewed-new", "/etc/ssl/certs/ca-certificates.crt", true),
+		found("Added", "added", "/app/ca.pem", true),
+		found("Added", "added", "/etc/ssl/certs/ca-certificates.crt", true),
+	}}
+
+	type summary struct {
+		kind    Kind
+		subject string
+		before  []string
+		after   []string
+	}
+	var got []summary
+	for _, c := range Diff(before, after) {
+		s := summary{kind: c.Kind, subject: c.Subject()}
+		if c.Before != nil {
+			s.before = c.Before.Locations
+		}
+		if c.After != nil {
+			s.after = c.After.Locations
+		}
+		got = append(got, s)
+	}
+
+	assert.Equal(t, []summary{
+		{kind: KindAdded, subject: "CN=Added", after: []string{"/app/ca.pem", "/etc/ssl/certs/ca-certificates.crt"}},
+		{kind: KindChanged, subject: "CN=Renewed", before: []string{"/etc/ssl/certs/ca-certificates.crt"}, after: []string{"/etc/ssl/certs/ca-certificates.crt"}},
+		{kind: KindRemoved, subject: "CN=Removed", before: []string{"/etc/ssl/certs/ca-certificates.crt"}},
+		{kind: KindMoved, subject: "CN=Moved", before: []string{"/etc/ssl/certs/ca-certificates.crt"}, after: []string{"/etc/pki/tls/certs/ca-bundle.crt"}},
+	}, got)
+}
+
+func TestAddsTrustAnchor(t *testing.T) {
+	before := &certificate.ParsedCertificates{Found: []certificate.Found{
+		found("Removed", "removed", "/etc/ssl/certs/ca-certificates.crt", true),
+	}}
+
+	tests := map[string]struct {
+		after  []certificate.Found
+		expAdd bool
+	}{
+		"no changes": {
+			after: before.Found,
+		},
+		"removed root": {
+			after: nil,
+		},
+		"added leaf": {
+			after: append([]certificate.Found{found("Leaf", "leaf", "/app/tls.crt", false)}, before.Found...),
+		},
+		"added root": {
+			after:  append([]certificate.Found{found("Root", "root", "/app/ca.crt", true)}, before.Found...),
+			expAdd: true,
+		},
+		"changed root": {
+			after:  []certificate.Found{found("Removed", "renewed", "/etc/ssl/certs/ca-certificates.crt", true)},
+			expAdd: true,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			after := &certificate.ParsedCertificates{Found: test.after}
+			assert.Equal(t, test.expAdd, AddsTrustAnchor(Diff(before, after)))
+		})
+	}
+}
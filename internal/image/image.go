@@ -10,15 +10,46 @@ import (
 	"strings"
 
 	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/name"
 	crapi "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/pkg/errors"
 
 	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/osrelease"
+	"github.com/jetstack/paranoia/internal/packages"
+	"github.com/jetstack/paranoia/internal/truststore"
 )
 
+// Result is the result of scanning a container image.
+type Result struct {
+	// Name is the image reference, as given.
+	Name string
+	// Digest is the digest of the image manifest, such as "sha256:...".
+	Digest string
+	// Parsed are the certificates found in the image.
+	Parsed *certificate.ParsedCertificates
+	// Err is the error scanning the image, if any, when scanning many images.
+	Err error
+	// Warnings are problems which didn't stop the image being scanned, such as
+	// a package database which couldn't be read.
+	Warnings []string
+}
+
 // FindImageCertificates will pull or load the image with the given name, scan
-// for X.509 certificates, and return the result.
+// for X.509 certificates, determine whether the operating system trusts each
+// and which are configured through environment variables in the image config,
+// and return the result.
 func FindImageCertificates(ctx context.Context, name string, opts ...Option) (*certificate.ParsedCertificates, error) {
+	res, err := ScanImage(ctx, name, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return res.Parsed, nil
+}
+
+// ScanImage is like FindImageCertificates, but also returns the digest of the
+// image scanned.
+func ScanImage(ctx context.Context, name string, opts ...Option) (*Result, error) {
 	o := makeOptions(opts...)
 
 	name = strings.TrimSpace(name)
@@ -54,6 +85,50 @@ func FindImageCertificates(ctx context.Context, name string, opts ...Option) (*c
 		return nil, fmt.Errorf("failed to load image: %w", err)
 	}
 
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compute image digest")
+	}
+
+	var parsedCertificates *certificate.ParsedCertificates
+	layers := o.layers
+	if layers == nil && o.results != nil {
+		layers = newLayerScanner(o.results)
+	}
+	if layers != nil {
+		parsedCertificates, err = layers.scanImage(ctx, img)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		parsedCertificates, err = exportCertificates(img)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	truststore.Apply(parsedCertificates)
+	osrelease.Detect(parsedCertificates)
+
+	cfg, err := img.ConfigFile()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read image config")
+	}
+	truststore.ApplyEnvironment(parsedCertificates, cfg.Config.Env, cfg.Config.WorkingDir)
+
+	warnings := packages.Attribute(parsedCertificates)
+
+	return &Result{
+		Name:     name,
+		Digest:   digest.String(),
+		Parsed:   parsedCertificates,
+		Warnings: warnings,
+	}, nil
+}
+
+// exportCertificates finds the certificates in the image by scanning its
+// flattened filesystem.
+func exportCertificates(img crapi.Image) (*certificate.ParsedCertificates, error) {
 	var exportErr error
 	exportDone := make(chan struct{})
 	r, w := io.Pipe()
@@ -74,8 +149,64 @@ func FindImageCertificates(ctx context.Context, name string, opts ...Option) (*c
 
 	<-exportDone
 	if exportErr != nil {
-		return nil, errors.Wrap(err, "error when exporting image")
+		return nil, errors.Wrap(exportErr, "error when exporting image")
 	}
 
 	return parsedCertificates, nil
 }
+
+// Digest resolves the digest of the image manifest with the given name,
+// without pulling the image. Tarballs named with file:// are read.
+func Digest(ctx context.Context, name string, opts ...Option) (string, error) {
+	o := makeOptions(opts...)
+	name = strings.TrimSpace(name)
+
+	if path, ok := strings.CutPrefix(name, "file://"); ok {
+		img, err := crane.Load(path, o.craneOpts...)
+		if err != nil {
+			return "", fmt.Errorf("failed to load image: %w", err)
+		}
+		digest, err := img.Digest()
+		if err != nil {
+			return "", errors.Wrap(err, "failed to compute image digest")
+		}
+		return digest.String(), nil
+	}
+
+	craneOpts := append([]crane.Option{crane.WithContext(ctx)}, o.craneOpts...)
+	digest, err := crane.Digest(name, craneOpts...)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to resolve image digest")
+	}
+	return digest, nil
+}
+
+// CheckReference returns an error unless the image is a registry reference,
+// rather than a tarball on local disk or stdin. Services scanning images named
+// by their clients check them first, so clients can't read the service's own
+// files.
+func CheckReference(image string) error {
+	image = strings.TrimSpace(image)
+	if image == "-" || strings.HasPrefix(image, "file://") {
+		return errors.New("image must be a registry reference, not a local tarball")
+	}
+	if _, err := name.ParseReference(image); err != nil {
+		return errors.Wrap(err, "invalid image reference")
+	}
+	return nil
+}
+
+// Pin returns the reference of the named image at the digest it was resolved
+// to by Digest, so that scanning it reads the same image even if its tag has
+// since moved. Tarballs are returned unchanged.
+func Pin(image, digest string) (string, error) {
+	image = strings.TrimSpace(image)
+	if image == "-" || strings.HasPrefix(image, "file://") {
+		return image, nil
+	}
+	ref, err := name.ParseReference(image)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to parse image reference")
+	}
+	return ref.Context().Digest(digest).String(), nil
+}
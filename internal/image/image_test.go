@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"net/http/httptest"
 	"net/url"
+	"path/filepath"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
@@ -83,10 +84,11 @@ func TestFindImageCertificates_Platform(t *testing.T) {
 					{
 						Location: "/linux-amd64.crt",
 						Parser:   "pem",
+						Trust:    certificate.TrustUntrustedFile,
 					},
 				},
 			}
-			if diff := cmp.Diff(wantCerts, gotCerts, cmpopts.IgnoreFields(certificate.Found{}, "Certificate", "FingerprintSha1", "FingerprintSha256")); diff != "" {
+			if diff := cmp.Diff(wantCerts, gotCerts, cmpopts.IgnoreFields(certificate.Found{}, "Certificate", "FingerprintSha1", "FingerprintSha256"), cmpopts.IgnoreFields(certificate.ParsedCertificates{}, "Files")); diff != "" {
 				t.Fatalf("unexpected certificates:\n%s", diff)
 			}
 		},
@@ -105,10 +107,11 @@ func TestFindImageCertificates_Platform(t *testing.T) {
 					{
 						Location: "/linux-arm64.crt",
 						Parser:   "pem",
+						Trust:    certificate.TrustUntrustedFile,
 					},
 				},
 			}
-			if diff := cmp.Diff(wantCerts, gotCerts, cmpopts.IgnoreFields(certificate.Found{}, "Certificate", "FingerprintSha1", "FingerprintSha256")); diff != "" {
+			if diff := cmp.Diff(wantCerts, gotCerts, cmpopts.IgnoreFields(certificate.Found{}, "Certificate", "FingerprintSha1", "FingerprintSha256"), cmpopts.IgnoreFields(certificate.ParsedCertificates{}, "Files")); diff != "" {
 				t.Fatalf("unexpected certificates:\n%s", diff)
 			}
 
@@ -137,10 +140,11 @@ func TestFindImageCertificates_Platform(t *testing.T) {
 					{
 						Location: "/image.crt",
 						Parser:   "pem",
+						Trust:    certificate.TrustUntrustedFile,
 					},
 				},
 			}
-			if diff := cmp.Diff(wantCerts, gotCerts, cmpopts.IgnoreFields(certificate.Found{}, "Certificate", "FingerprintSha1", "FingerprintSha256")); diff != "" {
+			if diff := cmp.Diff(wantCerts, gotCerts, cmpopts.IgnoreFields(certificate.Found{}, "Certificate", "FingerprintSha1", "FingerprintSha256"), cmpopts.IgnoreFields(certificate.ParsedCertificates{}, "Files")); diff != "" {
 				t.Fatalf("unexpected certificates:\n%s", diff)
 			}
 		},
@@ -151,6 +155,75 @@ func TestFindImageCertificates_Platform(t *testing.T) {
 	}
 }
 
+func TestDigest(t *testing.T) {
+	host := setupRegistry(t)
+
+	img := makeTestImage(t, map[string]string{"image.crt": "testdata/image"})
+	want, err := img.Digest()
+	if err != nil {
+		t.Fatalf("unexpected error computing digest: %s", err)
+	}
+
+	tag := fmt.Sprintf("%s/%s:%s", host, "repo", "digest")
+	if err := crane.Push(img, tag); err != nil {
+		t.Fatalf("unexpected error pushing image: %s", err)
+	}
+	tarball := filepath.Join(t.TempDir(), "image.tar")
+	if err := crane.Save(img, tag, tarball); err != nil {
+		t.Fatalf("unexpected error saving image: %s", err)
+	}
+
+	for _, name := range []string{tag, "file://" + tarball} {
+		got, err := Digest(context.Background(), name)
+		if err != nil {
+			t.Fatalf("unexpected error resolving digest of %s: %s", name, err)
+		}
+		if got != want.String() {
+			t.Errorf("unexpected digest of %s: got %s, want %s", name, got, want)
+		}
+	}
+}
+
+func TestPin(t *testing.T) {
+	const digest = "sha256:bd40be0eccfce513ab318882f03962e4e2ec3799b51392e82805d9249e426d28"
+	for image, want := range map[string]string{
+		"example.com/repo:v1":                 "example.com/repo@" + digest,
+		"example.com/repo:v1@sha256:" + zeros: "example.com/repo@" + digest,
+		"alpine":                              "index.docker.io/library/alpine@" + digest,
+		"file:///tmp/image.tar":               "file:///tmp/image.tar",
+		"-":                                   "-",
+	} {
+		got, err := Pin(image, digest)
+		if err != nil {
+			t.Fatalf("unexpected error pinning %s: %s", image, err)
+		}
+		if got != want {
+			t.Errorf("unexpected reference for %s: got %s, want %s", image, got, want)
+		}
+	}
+}
+
+func TestCheckReference(t *testing.T) {
+	for image, valid := range map[string]bool{
+		"example.com/repo:v1":          true,
+		"alpine@sha256:" + zeros:       true,
+		"file:///etc/ssl/certs/ca.pem": false,
+		" file:///etc/passwd":          false,
+		"-":                            false,
+		"Not A Reference":              false,
+	} {
+		err := CheckReference(image)
+		if valid && err != nil {
+			t.Errorf("unexpected error checking %q: %s", image, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("expected %q to be rejected", image)
+		}
+	}
+}
+
+const zeros = "0000000000000000000000000000000000000000000000000000000000000000"
+
 func makeTestImage(t *testing.T, fileMap map[string]string) v1.Image {
 	m := map[string][]byte{}
 	for path, f := range fileMap {
@@ -0,0 +1,44 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"context"
+	"sync"
+)
+
+// ScanImages scans each of the named images, with at most parallelism images
+// scanned at once. Layers shared between the images, such as those of a common
+// base image, are only downloaded and scanned once. Results are returned in the
+// order of the names given, with the error of any image which couldn't be
+// scanned recorded in its result.
+func ScanImages(ctx context.Context, names []string, parallelism int, opts ...Option) ([]Result, error) {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+
+	layers := newLayerScanner(makeOptions(opts...).results)
+	opts = append(opts, func(o *options) { o.layers = layers })
+
+	results := make([]Result, len(names))
+	sem := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			res, err := ScanImage(ctx, name, opts...)
+			if err != nil {
+				results[i] = Result{Name: name, Err: err}
+				return
+			}
+			results[i] = *res
+		}()
+	}
+	wg.Wait()
+
+	return results, nil
+}
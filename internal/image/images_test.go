@@ -0,0 +1,126 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanImages(t *testing.T) {
+	base := makeTestImage(t, map[string]string{"base.crt": "testdata/linux-amd64"})
+	layer, err := crane.Layer(map[string][]byte{"app.txt": []byte("no certificates here")})
+	require.NoError(t, err)
+	app, err := mutate.AppendLayers(base, layer)
+	require.NoError(t, err)
+
+	baseLayers, err := base.Layers()
+	require.NoError(t, err)
+	baseLayer, err := baseLayers[0].Digest()
+	require.NoError(t, err)
+
+	// Count the downloads of the base layer, shared by both images.
+	var downloads atomic.Int32
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/blobs/"+baseLayer.String()) {
+			downloads.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	baseRef, appRef := u.Host+"/repo:base", u.Host+"/repo:app"
+	require.NoError(t, crane.Push(base, baseRef))
+	require.NoError(t, crane.Push(app, appRef))
+
+	baseDigest, err := base.Digest()
+	require.NoError(t, err)
+	appDigest, err := app.Digest()
+	require.NoError(t, err)
+
+	results, err := ScanImages(context.Background(), []string{baseRef, appRef, u.Host + "/repo:missing"}, 2)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+
+	assert.Equal(t, baseRef, results[0].Name)
+	assert.Equal(t, baseDigest.String(), results[0].Digest)
+	assert.NoError(t, results[0].Err)
+	assert.Len(t, results[0].Parsed.Found, 1)
+
+	assert.Equal(t, appRef, results[1].Name)
+	assert.Equal(t, appDigest.String(), results[1].Digest)
+	assert.NoError(t, results[1].Err)
+	assert.Len(t, results[1].Parsed.Found, 1)
+
+	assert.Equal(t, u.Host+"/repo:missing", results[2].Name)
+	assert.Error(t, results[2].Err)
+
+	assert.Equal(t, int32(1), downloads.Load())
+}
+
+func TestScanImageCache(t *testing.T) {
+	base := makeTestImage(t, map[string]string{"base.crt": "testdata/linux-amd64"})
+	layer, err := crane.Layer(map[string][]byte{"app.crt": mustReadFile(t, "testdata/image")})
+	require.NoError(t, err)
+	app, err := mutate.AppendLayers(base, layer)
+	require.NoError(t, err)
+
+	// Count the downloads of any layer.
+	var downloads atomic.Int32
+	reg := registry.New()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet && strings.Contains(r.URL.Path, "/blobs/") {
+			downloads.Add(1)
+		}
+		reg.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+
+	ref := u.Host + "/repo:app"
+	require.NoError(t, crane.Push(app, ref))
+
+	uncached, err := ScanImage(context.Background(), ref)
+	require.NoError(t, err)
+	require.Len(t, uncached.Parsed.Found, 2)
+
+	dir := t.TempDir()
+	first, err := ScanImage(context.Background(), ref, WithCacheDir(dir))
+	require.NoError(t, err)
+	assert.Equal(t, uncached.Parsed.Found, first.Parsed.Found)
+	assert.Equal(t, uncached.Parsed.Files, first.Parsed.Files)
+
+	// Only the config is downloaded when every layer's results are cached.
+	downloads.Store(0)
+	second, err := ScanImage(context.Background(), ref, WithCacheDir(dir))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), downloads.Load())
+	assert.Equal(t, len(first.Parsed.Found), len(second.Parsed.Found))
+	for i, found := range first.Parsed.Found {
+		assert.Equal(t, found.Location, second.Parsed.Found[i].Location)
+		assert.Equal(t, found.FingerprintSha256, second.Parsed.Found[i].FingerprintSha256)
+	}
+	assert.Equal(t, first.Parsed.Files, second.Parsed.Files)
+}
+
+func mustReadFile(t *testing.T, name string) []byte {
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	return data
+}
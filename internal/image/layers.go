@@ -0,0 +1,101 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"context"
+	"sync"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/pkg/errors"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// layerScanner finds the certificates in an image by scanning each of its
+// layers, and merging the results. The result of scanning a layer is shared
+// between the images scanned in one invocation, so a base layer common to many
+// images is only downloaded and scanned once. Only results are kept, never the
+// layers themselves. Results are also read from and stored in the result
+// cache, if there is one.
+type layerScanner struct {
+	results *resultCache
+
+	mu     sync.Mutex
+	layers map[v1.Hash]*scannedLayer
+}
+
+type scannedLayer struct {
+	once   sync.Once
+	result *certificate.Layer
+	err    error
+}
+
+func newLayerScanner(results *resultCache) *layerScanner {
+	return &layerScanner{results: results, layers: make(map[v1.Hash]*scannedLayer)}
+}
+
+// scanImage finds the certificates in the image, scanning each layer which
+// hasn't been scanned before.
+func (s *layerScanner) scanImage(ctx context.Context, img v1.Image) (*certificate.ParsedCertificates, error) {
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read image layers")
+	}
+
+	results := make([]*certificate.Layer, len(layers))
+	for i, layer := range layers {
+		results[i], err = s.scanLayer(ctx, layer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return certificate.MergeLayers(results), nil
+}
+
+// scanLayer returns the result of scanning the layer, scanning it the first
+// time it is seen. Concurrent scans of the same layer wait for the first to
+// complete.
+func (s *layerScanner) scanLayer(ctx context.Context, layer v1.Layer) (*certificate.Layer, error) {
+	diffID, err := layer.DiffID()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to compute layer diff ID")
+	}
+
+	s.mu.Lock()
+	sl, ok := s.layers[diffID]
+	if !ok {
+		sl = &scannedLayer{}
+		s.layers[diffID] = sl
+	}
+	s.mu.Unlock()
+
+	sl.once.Do(func() {
+		if s.results != nil {
+			if l, ok := s.results.get(diffID); ok {
+				sl.result = l
+				return
+			}
+		}
+
+		rc, err := layer.Uncompressed()
+		if err != nil {
+			sl.err = errors.Wrapf(err, "failed to read layer %s", diffID)
+			return
+		}
+		defer rc.Close()
+		sl.result, sl.err = certificate.FindLayerCertificates(ctx, rc)
+		if sl.err != nil {
+			sl.err = errors.Wrapf(sl.err, "failed to search for certificates in layer %s", diffID)
+			return
+		}
+
+		// The cache only saves work, so a scan succeeds even if its results
+		// can't be stored.
+		if s.results != nil {
+			_ = s.results.put(diffID, sl.result)
+		}
+	})
+	return sl.result, sl.err
+}
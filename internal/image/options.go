@@ -10,6 +10,13 @@ type Option func(*options)
 
 type options struct {
 	craneOpts []crane.Option
+
+	// layers shares the result of scanning each layer between images, when
+	// scanning many.
+	layers *layerScanner
+
+	// results stores the result of scanning each layer on disk, if set.
+	results *resultCache
 }
 
 func makeOptions(opts ...Option) *options {
@@ -30,3 +37,14 @@ func WithPlatform(platform *v1.Platform) Option {
 		}
 	}
 }
+
+// WithCacheDir is a functional option that stores the result of scanning each
+// layer in the given directory, so a later scan only reads layers it hasn't
+// seen before.
+func WithCacheDir(dir string) Option {
+	return func(o *options) {
+		if dir != "" {
+			o.results = &resultCache{dir: dir}
+		}
+	}
+}
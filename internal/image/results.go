@@ -0,0 +1,72 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// resultsVersion is the version of the layer results stored on disk. It must
+// be incremented whenever what is found in a layer changes, such as when a
+// parser is added or more metadata files are captured, so stale results are
+// not used.
+const resultsVersion = "v2"
+
+// resultCache stores the result of scanning each layer on disk, keyed by the
+// layer's diff ID, so layers shared between images, or scanned before, are
+// only read once.
+type resultCache struct {
+	dir string
+}
+
+func (c *resultCache) path(diffID v1.Hash) string {
+	return filepath.Join(c.dir, resultsVersion, diffID.Algorithm+"-"+diffID.Hex)
+}
+
+// get returns the stored result for the layer. Results which can't be read
+// are treated as missing, and scanned again.
+func (c *resultCache) get(diffID v1.Hash) (*certificate.Layer, bool) {
+	f, err := os.Open(c.path(diffID))
+	if err != nil {
+		return nil, false
+	}
+	defer f.Close()
+	l, err := certificate.DecodeLayer(bufio.NewReader(f))
+	if err != nil {
+		return nil, false
+	}
+	return l, true
+}
+
+// put atomically stores the result for the layer.
+func (c *resultCache) put(diffID v1.Hash, l *certificate.Layer) error {
+	path := c.path(diffID)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	w := bufio.NewWriter(tmp)
+	if err := l.Encode(w); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,30 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package image
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func TestResultCacheVersion(t *testing.T) {
+	dir := t.TempDir()
+	c := &resultCache{dir: dir}
+	diffID := v1.Hash{Algorithm: "sha256", Hex: zeros}
+	assert.Equal(t, filepath.Join(dir, resultsVersion, "sha256-"+zeros), c.path(diffID))
+
+	// Results stored by another version of paranoia are scanned again.
+	require.NoError(t, c.put(diffID, &certificate.Layer{Parsed: &certificate.ParsedCertificates{}}))
+	stale := filepath.Join(dir, "v1", "sha256-"+zeros)
+	require.NoError(t, os.MkdirAll(filepath.Dir(stale), 0o755))
+	require.NoError(t, os.Rename(c.path(diffID), stale))
+	_, ok := c.get(diffID)
+	assert.False(t, ok)
+}
@@ -0,0 +1,259 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package manifests extracts the container images used by a deployment from
+// its Kubernetes manifests, rendered Helm charts, and Docker Compose files.
+package manifests
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ContainerType is the kind of container in a workload.
+type ContainerType string
+
+const (
+	ContainerTypeContainer          ContainerType = "container"
+	ContainerTypeInitContainer      ContainerType = "initContainer"
+	ContainerTypeEphemeralContainer ContainerType = "ephemeralContainer"
+	// ContainerTypeService is a Docker Compose service.
+	ContainerTypeService ContainerType = "service"
+)
+
+// Container is a container in a workload, and the image it runs.
+type Container struct {
+	// Source is the file the workload was found in.
+	Source string
+	// Kind is the kind of the Kubernetes workload, such as Deployment, or
+	// "Compose" for a Docker Compose file.
+	Kind string
+	// Namespace and Name identify the workload. Namespace is empty if not set
+	// in the manifest, and for Compose files.
+	Namespace string
+	Name      string
+	// Container is the name of the container, or of the Compose service.
+	Container string
+	Type      ContainerType
+	Image     string
+}
+
+// Workload describes the workload, such as "Deployment default/web".
+func (c Container) Workload() string {
+	name := c.Name
+	if c.Namespace != "" {
+		name = c.Namespace + "/" + name
+	}
+	return c.Kind + " " + name
+}
+
+func (c Container) String() string {
+	return fmt.Sprintf("%s, %s %s", c.Workload(), c.Type, c.Container)
+}
+
+// podSpecPaths are the paths to the pod spec in each kind of workload.
+var podSpecPaths = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// containerFields are the fields of a pod spec listing containers.
+var containerFields = []struct {
+	field string
+	typ   ContainerType
+}{
+	{"initContainers", ContainerTypeInitContainer},
+	{"containers", ContainerTypeContainer},
+	{"ephemeralContainers", ContainerTypeEphemeralContainer},
+}
+
+// Load finds the containers in every YAML or JSON file in the given directory
+// and its subdirectories, or in the given file. Files which can't be parsed,
+// such as Helm values files containing templates, are skipped, and a warning
+// is returned for each.
+func Load(root string) (containers []Container, warnings []string, err error) {
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+		default:
+			if path != root {
+				return nil
+			}
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		found, err := Parse(path, data)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("skipping %s, which can't be parsed: %s", path, err))
+			return nil
+		}
+		containers = append(containers, found...)
+		return nil
+	})
+	return containers, warnings, err
+}
+
+// Parse finds the containers in a file of one or more YAML documents. Each
+// document may be a Kubernetes object, a List of objects, or a Compose file.
+// Documents which are neither, including those which aren't mappings, such as
+// top-level JSON arrays, are ignored.
+func Parse(source string, data []byte) ([]Container, error) {
+	var containers []Container
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var node yaml.Node
+		if err := dec.Decode(&node); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(node.Content) != 1 || node.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		var doc map[string]any
+		if err := node.Decode(&doc); err != nil {
+			return nil, err
+		}
+		containers = append(containers, parseDocument(source, doc)...)
+	}
+	return containers, nil
+}
+
+func parseDocument(source string, doc map[string]any) []Container {
+	if doc == nil {
+		return nil
+	}
+	if kind, ok := doc["kind"].(string); ok {
+		return parseObject(source, kind, doc)
+	}
+	if services, ok := doc["services"].(map[string]any); ok {
+		return parseCompose(source, services)
+	}
+	return nil
+}
+
+func parseObject(source, kind string, obj map[string]any) []Container {
+	if kind == "List" || strings.HasSuffix(kind, "List") {
+		var containers []Container
+		items, _ := obj["items"].([]any)
+		for _, item := range items {
+			if m, ok := item.(map[string]any); ok {
+				containers = append(containers, parseDocument(source, m)...)
+			}
+		}
+		return containers
+	}
+
+	path, ok := podSpecPaths[kind]
+	if !ok {
+		return nil
+	}
+	spec, ok := lookup(obj, path...).(map[string]any)
+	if !ok {
+		return nil
+	}
+	namespace, _ := lookup(obj, "metadata", "namespace").(string)
+	name, _ := lookup(obj, "metadata", "name").(string)
+
+	var containers []Container
+	for _, f := range containerFields {
+		list, _ := spec[f.field].([]any)
+		for _, item := range list {
+			c, ok := item.(map[string]any)
+			if !ok {
+				continue
+			}
+			image, _ := c["image"].(string)
+			if image == "" {
+				continue
+			}
+			containerName, _ := c["name"].(string)
+			containers = append(containers, Container{
+				Source:    source,
+				Kind:      kind,
+				Namespace: namespace,
+				Name:      name,
+				Container: containerName,
+				Type:      f.typ,
+				Image:     image,
+			})
+		}
+	}
+	return containers
+}
+
+// parseCompose finds the services of a Compose file which run an image, rather
+// than only building one.
+func parseCompose(source string, services map[string]any) []Container {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var containers []Container
+	for _, name := range names {
+		svc, _ := services[name].(map[string]any)
+		image, _ := svc["image"].(string)
+		if image == "" {
+			continue
+		}
+		containers = append(containers, Container{
+			Source:    source,
+			Kind:      "Compose",
+			Name:      filepath.Base(source),
+			Container: name,
+			Type:      ContainerTypeService,
+			Image:     image,
+		})
+	}
+	return containers
+}
+
+// lookup returns the value at the path of keys in nested maps, or nil.
+func lookup(v any, path ...string) any {
+	for _, key := range path {
+		m, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// Images returns the distinct images run by the containers, in the order they
+// were first found.
+func Images(containers []Container) []string {
+	var images []string
+	seen := make(map[string]bool)
+	for _, c := range containers {
+		if !seen[c.Image] {
+			seen[c.Image] = true
+			images = append(images, c.Image)
+		}
+	}
+	return images
+}
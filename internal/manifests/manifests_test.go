@@ -0,0 +1,186 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package manifests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		data          string
+		expContainers []Container
+	}{
+		"rendered helm chart": {
+			data: `---
+# Source: web/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: shop
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        image: migrate:1.0
+      containers:
+      - name: app
+        image: web:2.1
+      - name: sidecar
+        image: proxy:3
+---
+# Source: web/templates/cronjob.yaml
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: cleanup
+spec:
+  schedule: "@daily"
+  jobTemplate:
+    spec:
+      template:
+        spec:
+          containers:
+          - name: cleanup
+            image: web:2.1
+`,
+			expContainers: []Container{
+				{Source: "f", Kind: "Deployment", Namespace: "shop", Name: "web", Container: "migrate", Type: ContainerTypeInitContainer, Image: "migrate:1.0"},
+				{Source: "f", Kind: "Deployment", Namespace: "shop", Name: "web", Container: "app", Type: ContainerTypeContainer, Image: "web:2.1"},
+				{Source: "f", Kind: "Deployment", Namespace: "shop", Name: "web", Container: "sidecar", Type: ContainerTypeContainer, Image: "proxy:3"},
+				{Source: "f", Kind: "CronJob", Name: "cleanup", Container: "cleanup", Type: ContainerTypeContainer, Image: "web:2.1"},
+			},
+		},
+		"pod with ephemeral container": {
+			data: `apiVersion: v1
+kind: Pod
+metadata:
+  name: debug
+spec:
+  containers:
+  - name: app
+    image: app:1
+  ephemeralContainers:
+  - name: debugger
+    image: busybox
+`,
+			expContainers: []Container{
+				{Source: "f", Kind: "Pod", Name: "debug", Container: "app", Type: ContainerTypeContainer, Image: "app:1"},
+				{Source: "f", Kind: "Pod", Name: "debug", Container: "debugger", Type: ContainerTypeEphemeralContainer, Image: "busybox"},
+			},
+		},
+		"list": {
+			data: `apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: StatefulSet
+  metadata:
+    name: db
+  spec:
+    template:
+      spec:
+        containers:
+        - name: postgres
+          image: postgres:16
+- apiVersion: apps/v1
+  kind: DaemonSet
+  metadata:
+    name: agent
+    namespace: kube-system
+  spec:
+    template:
+      spec:
+        containers:
+        - name: agent
+          image: agent:1
+`,
+			expContainers: []Container{
+				{Source: "f", Kind: "StatefulSet", Name: "db", Container: "postgres", Type: ContainerTypeContainer, Image: "postgres:16"},
+				{Source: "f", Kind: "DaemonSet", Namespace: "kube-system", Name: "agent", Container: "agent", Type: ContainerTypeContainer, Image: "agent:1"},
+			},
+		},
+		"compose": {
+			data: `services:
+  web:
+    image: web:2.1
+    ports: ["80:80"]
+  built:
+    build: .
+  db:
+    image: postgres:16
+`,
+			expContainers: []Container{
+				{Source: "f", Kind: "Compose", Name: "f", Container: "db", Type: ContainerTypeService, Image: "postgres:16"},
+				{Source: "f", Kind: "Compose", Name: "f", Container: "web", Type: ContainerTypeService, Image: "web:2.1"},
+			},
+		},
+		"unrelated documents": {
+			data: `apiVersion: v1
+kind: ConfigMap
+data:
+  image: not-an-image
+---
+---
+just: some yaml
+`,
+		},
+		"documents which aren't mappings": {
+			data: `["web:2.1", "postgres:16"]
+---
+just a string
+---
+- kind: Pod
+`,
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			containers, err := Parse("f", []byte(test.data))
+			require.NoError(t, err)
+			assert.Equal(t, test.expContainers, containers)
+		})
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	_, err := Parse("f", []byte("kind: [Pod"))
+	assert.Error(t, err)
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "charts"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "docker-compose.yml"), []byte("services:\n  web:\n    image: web:2.1\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "charts", "pod.yaml"), []byte("kind: Pod\nmetadata:\n  name: p\nspec:\n  containers:\n  - name: c\n    image: web:2.1\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("kind: [not yaml"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "charts", "values.yaml"), []byte("image: {{ .Values.image }}\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "charts", "broken.yaml"), []byte("kind: [Pod"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "images.json"), []byte(`["web:2.1"]`), 0o644))
+
+	containers, warnings, err := Load(dir)
+	require.NoError(t, err)
+	require.Len(t, containers, 2)
+	require.Len(t, warnings, 2)
+	assert.Contains(t, warnings[0], filepath.Join(dir, "charts", "broken.yaml"))
+	assert.Contains(t, warnings[1], filepath.Join(dir, "charts", "values.yaml"))
+	assert.Equal(t, "Pod p, container c", containers[0].String())
+	assert.Equal(t, "Compose docker-compose.yml, service web", containers[1].String())
+	assert.Equal(t, []string{"web:2.1"}, Images(containers))
+}
@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package osrelease detects the distribution of a container image from its
+// os-release file, as described in os-release(5).
+package osrelease
+
+import (
+	"bufio"
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// paths are the locations of the os-release file, in order of precedence.
+// /etc/os-release is usually a symbolic link to /usr/lib/os-release.
+var paths = []string{
+	"/etc/os-release",
+	"/usr/lib/os-release",
+}
+
+// Detect sets the operating system of the parsed image from its os-release
+// file. It is left nil if the image has none, such as for scratch images.
+func Detect(parsed *certificate.ParsedCertificates) {
+	for _, p := range paths {
+		resolved, ok := parsed.Resolve(p)
+		if !ok {
+			continue
+		}
+		f := parsed.Files[resolved]
+		if f.Contents == nil {
+			continue
+		}
+
+		fields := Parse(f.Contents)
+		if fields["ID"] == "" {
+			continue
+		}
+		system := &certificate.OperatingSystem{
+			ID:         fields["ID"],
+			VersionID:  fields["VERSION_ID"],
+			PrettyName: fields["PRETTY_NAME"],
+		}
+		if system.PrettyName == "" {
+			system.PrettyName = strings.TrimSpace(fields["NAME"] + " " + system.VersionID)
+		}
+		parsed.OperatingSystem = system
+		return
+	}
+}
+
+// Parse parses the environment-like KEY=value assignments of an os-release
+// file. Values may be quoted as in a shell, and comments are ignored.
+func Parse(data []byte) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		fields[key] = unquote(value)
+	}
+	return fields
+}
+
+func unquote(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	switch value[0] {
+	case '"':
+		if s, err := strconv.Unquote(value); err == nil {
+			return s
+		}
+		return strings.Trim(value, `"`)
+	case '\'':
+		return strings.Trim(value, "'")
+	}
+	return value
+}
@@ -0,0 +1,77 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package osrelease
+
+import (
+	"archive/tar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func TestDetect(t *testing.T) {
+	debian := `PRETTY_NAME="Debian GNU/Linux 12 (bookworm)"
+NAME="Debian GNU/Linux"
+VERSION_ID="12"
+VERSION="12 (bookworm)"
+VERSION_CODENAME=bookworm
+ID=debian
+`
+	alpine := `NAME="Alpine Linux"
+ID=alpine
+VERSION_ID=3.19.1
+# no PRETTY_NAME
+`
+
+	tests := map[string]struct {
+		files map[string]certificate.File
+		expOS *certificate.OperatingSystem
+	}{
+		"symlinked to /usr/lib": {
+			files: map[string]certificate.File{
+				"/etc/os-release":     {Type: tar.TypeSymlink, Linkname: "../usr/lib/os-release"},
+				"/usr/lib/os-release": {Type: tar.TypeReg, Contents: []byte(debian)},
+			},
+			expOS: &certificate.OperatingSystem{ID: "debian", VersionID: "12", PrettyName: "Debian GNU/Linux 12 (bookworm)"},
+		},
+		"falls back to name and version": {
+			files: map[string]certificate.File{
+				"/etc/os-release": {Type: tar.TypeReg, Contents: []byte(alpine)},
+			},
+			expOS: &certificate.OperatingSystem{ID: "alpine", VersionID: "3.19.1", PrettyName: "Alpine Linux 3.19.1"},
+		},
+		"scratch image": {
+			files: map[string]certificate.File{
+				"/app": {Type: tar.TypeReg},
+			},
+			expOS: nil,
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			parsed := &certificate.ParsedCertificates{Files: test.files}
+			Detect(parsed)
+			assert.Equal(t, test.expOS, parsed.OperatingSystem)
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	assert.Equal(t, map[string]string{
+		"NAME":   "Example Linux",
+		"ID":     "example",
+		"QUOTED": `say "hi"`,
+		"SINGLE": "single quoted",
+		"EMPTY":  "",
+	}, Parse([]byte(`# comment
+NAME="Example Linux"
+ID=example
+QUOTED="say \"hi\""
+SINGLE='single quoted'
+EMPTY=
+invalid line
+`)))
+}
@@ -2,24 +2,701 @@
 
 package output
 
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/jetstack/paranoia/internal/analyse"
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/manifests"
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+// JSONSchemaVersion is the version of the JSON output schema. It is
+// incremented whenever fields are changed or removed, but not when fields are
+// added.
+const JSONSchemaVersion = "2"
+
 type JSONOutput struct {
+	SchemaVersion       string                   `json:"schemaVersion,omitempty"`
 	Certificates        []JSONCertificate        `json:"certificates"`
 	PartialCertificates []JSONPartialCertificate `json:"partials,omitempty"`
+	PrivateKeys         []JSONPrivateKey         `json:"privateKeys,omitempty"`
+	CRLs                []JSONCRL                `json:"crls,omitempty"`
+	CertificateRequests []JSONCertificateRequest `json:"certificateRequests,omitempty"`
+	TrustVariables      []JSONTrustVariable      `json:"trustVariables,omitempty"`
+	OperatingSystem     *JSONOperatingSystem     `json:"operatingSystem,omitempty"`
 }
 
-type JSONCertificate struct {
-	FileLocation      string `json:"fileLocation"`
-	Owner             string `json:"owner"`
-	Parser            string `json:"parser"`
-	Signature         string `json:"signature"`
-	NotBefore         string `json:"notBefore"`
-	NotAfter          string `json:"notAfter"`
-	FingerprintSHA1   string `json:"fingerprintSHA1"`
+type JSONOperatingSystem struct {
+	ID         string `json:"id"`
+	VersionID  string `json:"versionID,omitempty"`
+	PrettyName string `json:"prettyName,omitempty"`
+}
+
+// NewJSONOperatingSystem converts the detected operating system to its JSON
+// output, or nil if it wasn't detected.
+func NewJSONOperatingSystem(system *certificate.OperatingSystem) *JSONOperatingSystem {
+	if system == nil {
+		return nil
+	}
+	return &JSONOperatingSystem{
+		ID:         system.ID,
+		VersionID:  system.VersionID,
+		PrettyName: system.PrettyName,
+	}
+}
+
+// JSONBaselineComparison is the output of comparing an image's trust store
+// against a baseline.
+type JSONBaselineComparison struct {
+	SchemaVersion           string                    `json:"schemaVersion"`
+	OperatingSystem         *JSONOperatingSystem      `json:"operatingSystem,omitempty"`
+	Package                 *JSONPackage              `json:"package,omitempty"`
+	BaselineSource          string                    `json:"baselineSource,omitempty"`
+	BaselineOperatingSystem *JSONOperatingSystem      `json:"baselineOperatingSystem,omitempty"`
+	BaselinePackage         *JSONPackage              `json:"baselinePackage,omitempty"`
+	Added                   []JSONCertificate         `json:"added"`
+	Removed                 []JSONBaselineCertificate `json:"removed"`
+}
+
+// JSONDiff is the output of comparing the certificates in two images.
+type JSONDiff struct {
+	SchemaVersion string           `json:"schemaVersion"`
+	Before        string           `json:"before"`
+	After         string           `json:"after"`
+	Changes       []JSONDiffChange `json:"changes"`
+}
+
+type JSONDiffChange struct {
+	Kind            string        `json:"kind"`
+	Subject         string        `json:"subject"`
+	AddsTrustAnchor bool          `json:"addsTrustAnchor,omitempty"`
+	Before          *JSONDiffSide `json:"before,omitempty"`
+	After           *JSONDiffSide `json:"after,omitempty"`
+}
+
+type JSONDiffSide struct {
+	Locations   []string        `json:"locations"`
+	Certificate JSONCertificate `json:"certificate"`
+}
+
+type JSONBaselineCertificate struct {
+	Subject           string `json:"subject,omitempty"`
 	FingerprintSHA256 string `json:"fingerprintSHA256"`
 }
 
+// JSONImagesOutput is the output when many images are scanned at once, with
+// the output for each image keyed by its name and digest.
+type JSONImagesOutput struct {
+	SchemaVersion string            `json:"schemaVersion"`
+	Images        []JSONImageOutput `json:"images"`
+}
+
+type JSONImageOutput struct {
+	Image  string `json:"image"`
+	Digest string `json:"digest,omitempty"`
+	// Error is set if the image couldn't be scanned.
+	Error string `json:"error,omitempty"`
+	// Workloads are the containers which run the image, when scanning the
+	// images in manifests.
+	Workloads []JSONWorkload `json:"workloads,omitempty"`
+	*JSONOutput
+}
+
+// JSONWorkload is a container in a workload, found in a manifest.
+type JSONWorkload struct {
+	Source        string `json:"source"`
+	Kind          string `json:"kind"`
+	Namespace     string `json:"namespace,omitempty"`
+	Name          string `json:"name"`
+	Container     string `json:"container"`
+	ContainerType string `json:"containerType"`
+}
+
+func NewJSONWorkloads(containers []manifests.Container) []JSONWorkload {
+	var workloads []JSONWorkload
+	for _, c := range containers {
+		workloads = append(workloads, JSONWorkload{
+			Source:        c.Source,
+			Kind:          c.Kind,
+			Namespace:     c.Namespace,
+			Name:          c.Name,
+			Container:     c.Container,
+			ContainerType: string(c.Type),
+		})
+	}
+	return workloads
+}
+
+type JSONTrustVariable struct {
+	Name     string `json:"name"`
+	Path     string `json:"path"`
+	Resolved string `json:"resolved"`
+	Exists   bool   `json:"exists"`
+}
+
+type JSONCertificate struct {
+	FileLocation       string                `json:"fileLocation"`
+	Owner              string                `json:"owner"`
+	Issuer             string                `json:"issuer"`
+	SerialNumber       string                `json:"serialNumber"`
+	Parser             string                `json:"parser"`
+	Classification     string                `json:"classification"`
+	Trust              string                `json:"trust,omitempty"`
+	TrustVariables     []string              `json:"trustVariables,omitempty"`
+	Signature          string                `json:"signature"`
+	SignatureAlgorithm string                `json:"signatureAlgorithm"`
+	KeyAlgorithm       string                `json:"keyAlgorithm"`
+	KeySize            int                   `json:"keySize,omitempty"`
+	NotBefore          string                `json:"notBefore"`
+	NotAfter           string                `json:"notAfter"`
+	SubjectAltNames    *JSONSubjectAltNames  `json:"subjectAltNames,omitempty"`
+	BasicConstraints   *JSONBasicConstraints `json:"basicConstraints,omitempty"`
+	KeyUsages          []string              `json:"keyUsages,omitempty"`
+	ExtKeyUsages       []string              `json:"extKeyUsages,omitempty"`
+	SubjectKeyID       string                `json:"subjectKeyID,omitempty"`
+	AuthorityKeyID     string                `json:"authorityKeyID,omitempty"`
+	SPKISHA256         string                `json:"spkiSHA256"`
+	FingerprintSHA1    string                `json:"fingerprintSHA1"`
+	FingerprintSHA256  string                `json:"fingerprintSHA256"`
+	PEM                string                `json:"pem,omitempty"`
+	RootProgrammes     []JSONRootProgramme   `json:"rootProgrammes,omitempty"`
+	TrustSettings      *JSONTrustSettings    `json:"trustSettings,omitempty"`
+	Package            *JSONPackage          `json:"package,omitempty"`
+}
+
+type JSONPackage struct {
+	Manager  string `json:"manager"`
+	Name     string `json:"name"`
+	Version  string `json:"version"`
+	Modified bool   `json:"modified"`
+}
+
+type JSONTrustSettings struct {
+	Trusted  []string `json:"trusted,omitempty"`
+	Rejected []string `json:"rejected,omitempty"`
+	Alias    string   `json:"alias,omitempty"`
+}
+
+type JSONRootProgramme struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+type JSONSubjectAltNames struct {
+	DNSNames       []string `json:"dnsNames,omitempty"`
+	EmailAddresses []string `json:"emailAddresses,omitempty"`
+	IPAddresses    []string `json:"ipAddresses,omitempty"`
+	URIs           []string `json:"uris,omitempty"`
+}
+
+type JSONBasicConstraints struct {
+	IsCA       bool `json:"isCA"`
+	MaxPathLen *int `json:"maxPathLen,omitempty"`
+}
+
 type JSONPartialCertificate struct {
 	FileLocation string `json:"fileLocation"`
 	Reason       string `json:"reason"`
 	Parser       string `json:"parser"`
 }
+
+type JSONPrivateKey struct {
+	FileLocation         string   `json:"fileLocation"`
+	Parser               string   `json:"parser"`
+	Format               string   `json:"format"`
+	KeyAlgorithm         string   `json:"keyAlgorithm,omitempty"`
+	KeySize              int      `json:"keySize,omitempty"`
+	Encrypted            bool     `json:"encrypted"`
+	SPKISHA256           string   `json:"spkiSHA256,omitempty"`
+	MatchingCertificates []string `json:"matchingCertificates,omitempty"`
+}
+
+// NewJSONPrivateKey builds the JSON representation of a found private key.
+// Matching certificates are given by their SHA-256 fingerprint.
+func NewJSONPrivateKey(key certificate.PrivateKey) JSONPrivateKey {
+	out := JSONPrivateKey{
+		FileLocation: key.Location,
+		Parser:       key.Parser,
+		Format:       key.Format,
+		KeyAlgorithm: key.Type,
+		KeySize:      key.Size,
+		Encrypted:    key.Encrypted,
+	}
+	if key.SPKISHA256 != ([32]byte{}) {
+		out.SPKISHA256 = hex.EncodeToString(key.SPKISHA256[:])
+	}
+	for _, fingerprint := range key.MatchingCertificates {
+		out.MatchingCertificates = append(out.MatchingCertificates, hex.EncodeToString(fingerprint[:]))
+	}
+	return out
+}
+
+// NewJSONCertificate builds the JSON representation of a found certificate.
+// If includePEM is true, the PEM encoding of the certificate is embedded.
+func NewJSONCertificate(found certificate.Found, includePEM bool) JSONCertificate {
+	cert := found.Certificate
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	out := JSONCertificate{
+		FileLocation:       found.Location,
+		Owner:              cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		Parser:             found.Parser,
+		Classification:     string(certificate.Classify(cert)),
+		Trust:              string(found.Trust),
+		TrustVariables:     found.TrustVariables,
+		Signature:          fmt.Sprintf("%X", cert.Signature),
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		KeyAlgorithm:       cert.PublicKeyAlgorithm.String(),
+		KeySize:            certificate.PublicKeySize(cert.PublicKey),
+		NotBefore:          cert.NotBefore.Format(time.RFC3339),
+		NotAfter:           cert.NotAfter.Format(time.RFC3339),
+		KeyUsages:          keyUsages(cert.KeyUsage),
+		ExtKeyUsages:       extKeyUsages(cert.ExtKeyUsage, cert.UnknownExtKeyUsage),
+		SubjectKeyID:       hex.EncodeToString(cert.SubjectKeyId),
+		AuthorityKeyID:     hex.EncodeToString(cert.AuthorityKeyId),
+		SPKISHA256:         hex.EncodeToString(spki[:]),
+		FingerprintSHA1:    hex.EncodeToString(found.FingerprintSha1[:]),
+		FingerprintSHA256:  hex.EncodeToString(found.FingerprintSha256[:]),
+	}
+
+	if cert.SerialNumber != nil {
+		out.SerialNumber = cert.SerialNumber.Text(16)
+	}
+
+	out.SubjectAltNames = subjectAltNames(cert.DNSNames, cert.EmailAddresses, cert.IPAddresses, cert.URIs)
+
+	if cert.BasicConstraintsValid {
+		bc := &JSONBasicConstraints{IsCA: cert.IsCA}
+		if cert.MaxPathLen > 0 || cert.MaxPathLenZero {
+			maxPathLen := cert.MaxPathLen
+			bc.MaxPathLen = &maxPathLen
+		}
+		out.BasicConstraints = bc
+	}
+
+	if ts := found.TrustSettings; ts != nil {
+		out.TrustSettings = &JSONTrustSettings{
+			Trusted:  ts.Trusted,
+			Rejected: ts.Rejected,
+			Alias:    ts.Alias,
+		}
+	}
+
+	if pkg := found.Package; pkg != nil {
+		out.Package = &JSONPackage{
+			Manager:  pkg.Manager,
+			Name:     pkg.Name,
+			Version:  pkg.Version,
+			Modified: pkg.Modified,
+		}
+	}
+
+	if includePEM {
+		out.PEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+	}
+
+	return out
+}
+
+type JSONCRL struct {
+	FileLocation        string `json:"fileLocation"`
+	Parser              string `json:"parser"`
+	Issuer              string `json:"issuer"`
+	Number              string `json:"number,omitempty"`
+	SignatureAlgorithm  string `json:"signatureAlgorithm"`
+	ThisUpdate          string `json:"thisUpdate"`
+	NextUpdate          string `json:"nextUpdate,omitempty"`
+	RevokedCertificates int    `json:"revokedCertificates"`
+	AuthorityKeyID      string `json:"authorityKeyID,omitempty"`
+	FingerprintSHA256   string `json:"fingerprintSHA256"`
+}
+
+// NewJSONCRL builds the JSON representation of a found certificate
+// revocation list.
+func NewJSONCRL(crl certificate.CRL) JSONCRL {
+	rl := crl.RevocationList
+	out := JSONCRL{
+		FileLocation:        crl.Location,
+		Parser:              crl.Parser,
+		Issuer:              rl.Issuer.String(),
+		SignatureAlgorithm:  rl.SignatureAlgorithm.String(),
+		ThisUpdate:          rl.ThisUpdate.Format(time.RFC3339),
+		RevokedCertificates: len(rl.RevokedCertificateEntries),
+		AuthorityKeyID:      hex.EncodeToString(rl.AuthorityKeyId),
+		FingerprintSHA256:   hex.EncodeToString(crl.FingerprintSha256[:]),
+	}
+	if rl.Number != nil {
+		out.Number = rl.Number.Text(16)
+	}
+	if !rl.NextUpdate.IsZero() {
+		out.NextUpdate = rl.NextUpdate.Format(time.RFC3339)
+	}
+	return out
+}
+
+type JSONCertificateRequest struct {
+	FileLocation       string               `json:"fileLocation"`
+	Parser             string               `json:"parser"`
+	Subject            string               `json:"subject"`
+	SignatureAlgorithm string               `json:"signatureAlgorithm"`
+	KeyAlgorithm       string               `json:"keyAlgorithm"`
+	KeySize            int                  `json:"keySize,omitempty"`
+	SubjectAltNames    *JSONSubjectAltNames `json:"subjectAltNames,omitempty"`
+	SPKISHA256         string               `json:"spkiSHA256"`
+	FingerprintSHA256  string               `json:"fingerprintSHA256"`
+}
+
+// NewJSONCertificateRequest builds the JSON representation of a found
+// certificate signing request.
+func NewJSONCertificateRequest(req certificate.CertificateRequest) JSONCertificateRequest {
+	csr := req.Request
+	spki := sha256.Sum256(csr.RawSubjectPublicKeyInfo)
+	return JSONCertificateRequest{
+		FileLocation:       req.Location,
+		Parser:             req.Parser,
+		Subject:            csr.Subject.String(),
+		SignatureAlgorithm: csr.SignatureAlgorithm.String(),
+		KeyAlgorithm:       csr.PublicKeyAlgorithm.String(),
+		KeySize:            certificate.PublicKeySize(csr.PublicKey),
+		SubjectAltNames:    subjectAltNames(csr.DNSNames, csr.EmailAddresses, csr.IPAddresses, csr.URIs),
+		SPKISHA256:         hex.EncodeToString(spki[:]),
+		FingerprintSHA256:  hex.EncodeToString(req.FingerprintSha256[:]),
+	}
+}
+
+// subjectAltNames returns the JSON representation of the given subject
+// alternative names, or nil if there are none.
+func subjectAltNames(dnsNames, emailAddresses []string, ipAddresses []net.IP, uris []*url.URL) *JSONSubjectAltNames {
+	if len(dnsNames)+len(emailAddresses)+len(ipAddresses)+len(uris) == 0 {
+		return nil
+	}
+	sans := &JSONSubjectAltNames{
+		DNSNames:       dnsNames,
+		EmailAddresses: emailAddresses,
+	}
+	for _, ip := range ipAddresses {
+		sans.IPAddresses = append(sans.IPAddresses, ip.String())
+	}
+	for _, uri := range uris {
+		sans.URIs = append(sans.URIs, uri.String())
+	}
+	return sans
+}
+
+var keyUsageNames = []struct {
+	usage x509.KeyUsage
+	name  string
+}{
+	{x509.KeyUsageDigitalSignature, "digitalSignature"},
+	{x509.KeyUsageContentCommitment, "contentCommitment"},
+	{x509.KeyUsageKeyEncipherment, "keyEncipherment"},
+	{x509.KeyUsageDataEncipherment, "dataEncipherment"},
+	{x509.KeyUsageKeyAgreement, "keyAgreement"},
+	{x509.KeyUsageCertSign, "keyCertSign"},
+	{x509.KeyUsageCRLSign, "cRLSign"},
+	{x509.KeyUsageEncipherOnly, "encipherOnly"},
+	{x509.KeyUsageDecipherOnly, "decipherOnly"},
+}
+
+func keyUsages(ku x509.KeyUsage) []string {
+	var usages []string
+	for _, n := range keyUsageNames {
+		if ku&n.usage != 0 {
+			usages = append(usages, n.name)
+		}
+	}
+	return usages
+}
+
+// extKeyUsages names the extended key usages, followed by the OIDs of those
+// unknown to the x509 package in dotted form.
+func extKeyUsages(ekus []x509.ExtKeyUsage, unknown []asn1.ObjectIdentifier) []string {
+	var usages []string
+	for _, eku := range ekus {
+		if name, ok := certificate.ExtKeyUsageNames[eku]; ok {
+			usages = append(usages, name)
+		} else {
+			usages = append(usages, fmt.Sprintf("unknown(%d)", eku))
+		}
+	}
+	for _, oid := range unknown {
+		usages = append(usages, oid.String())
+	}
+	return usages
+}
+
+// NewJSONOutput builds the JSON output for the certificates found in an
+// image, including their membership of each root programme.
+func NewJSONOutput(parsedCertificates *certificate.ParsedCertificates, programmes []analyse.RootProgramme, includePEM bool) JSONOutput {
+	out := JSONOutput{SchemaVersion: JSONSchemaVersion}
+
+	for _, cert := range parsedCertificates.Found {
+		jsonCert := NewJSONCertificate(cert, includePEM)
+		for _, m := range analyse.Memberships(programmes, cert.Certificate) {
+			jsonCert.RootProgrammes = append(jsonCert.RootProgrammes, JSONRootProgramme{
+				Name:   m.Programme,
+				Status: string(m.Status),
+			})
+		}
+		out.Certificates = append(out.Certificates, jsonCert)
+	}
+
+	for _, p := range parsedCertificates.Partials {
+		out.PartialCertificates = append(out.PartialCertificates, JSONPartialCertificate{
+			FileLocation: p.Location,
+			Parser:       p.Parser,
+			Reason:       p.Reason,
+		})
+	}
+
+	for _, key := range parsedCertificates.PrivateKeys {
+		out.PrivateKeys = append(out.PrivateKeys, NewJSONPrivateKey(key))
+	}
+
+	for _, v := range parsedCertificates.TrustVariables {
+		out.TrustVariables = append(out.TrustVariables, JSONTrustVariable{
+			Name:     v.Name,
+			Path:     v.Path,
+			Resolved: v.Resolved,
+			Exists:   v.Exists,
+		})
+	}
+
+	for _, crl := range parsedCertificates.CRLs {
+		out.CRLs = append(out.CRLs, NewJSONCRL(crl))
+	}
+
+	for _, req := range parsedCertificates.CertificateRequests {
+		out.CertificateRequests = append(out.CertificateRequests, NewJSONCertificateRequest(req))
+	}
+
+	out.OperatingSystem = NewJSONOperatingSystem(parsedCertificates.OperatingSystem)
+
+	return out
+}
+
+// JSONInspection is the output of inspecting the certificates found in an
+// image, listing those with issues.
+type JSONInspection struct {
+	Certificates []JSONInspectedCertificate `json:"certificates"`
+	PrivateKeys  []JSONInspectedPrivateKey  `json:"privateKeys,omitempty"`
+	// Total is the number of certificates found, and Issues the number of
+	// those with issues, including partial certificates.
+	Total  int `json:"total"`
+	Issues int `json:"issues"`
+}
+
+type JSONInspectedCertificate struct {
+	FileLocation      string     `json:"fileLocation"`
+	Subject           string     `json:"subject"`
+	FingerprintSHA256 string     `json:"fingerprintSHA256"`
+	PublicTrust       string     `json:"publicTrust,omitempty"`
+	Notes             []JSONNote `json:"notes"`
+}
+
+type JSONInspectedPrivateKey struct {
+	FileLocation string     `json:"fileLocation"`
+	Notes        []JSONNote `json:"notes"`
+}
+
+type JSONNote struct {
+	Level  string `json:"level"`
+	Rule   string `json:"rule"`
+	Reason string `json:"reason"`
+}
+
+// NewJSONInspection analyses the certificates and private keys found in an
+// image.
+func NewJSONInspection(analyser *analyse.Analyser, parsedCertificates *certificate.ParsedCertificates) JSONInspection {
+	out := JSONInspection{
+		Certificates: []JSONInspectedCertificate{},
+		Total:        len(parsedCertificates.Found),
+		Issues:       len(parsedCertificates.Partials),
+	}
+	for _, cert := range parsedCertificates.Found {
+		if cert.Certificate == nil {
+			out.Issues++
+			continue
+		}
+		notes := analyser.AnalyseFound(cert)
+		if len(notes) == 0 {
+			continue
+		}
+		out.Issues++
+		var publicTrust string
+		if len(analyser.IncludedCertificates) > 0 {
+			publicTrust = string(analyser.PublicTrust(cert.Certificate))
+		}
+		out.Certificates = append(out.Certificates, JSONInspectedCertificate{
+			FileLocation:      cert.Location,
+			Subject:           cert.Certificate.Subject.String(),
+			FingerprintSHA256: hex.EncodeToString(cert.FingerprintSha256[:]),
+			PublicTrust:       publicTrust,
+			Notes:             newJSONNotes(notes),
+		})
+	}
+	for _, key := range parsedCertificates.PrivateKeys {
+		if notes := analyser.AnalysePrivateKey(key); len(notes) > 0 {
+			out.PrivateKeys = append(out.PrivateKeys, JSONInspectedPrivateKey{
+				FileLocation: key.Location,
+				Notes:        newJSONNotes(notes),
+			})
+		}
+	}
+	return out
+}
+
+func newJSONNotes(notes []analyse.Note) []JSONNote {
+	out := make([]JSONNote, 0, len(notes))
+	for _, n := range notes {
+		out = append(out, JSONNote{Level: string(n.Level), Rule: n.Rule, Reason: n.Reason})
+	}
+	return out
+}
+
+// JSONValidation is the output of validating the certificates found in an
+// image against a config.
+type JSONValidation struct {
+	Pass                   bool                      `json:"pass"`
+	ForbiddenCertificates  []JSONValidationViolation `json:"forbiddenCertificates,omitempty"`
+	NotAllowedCertificates []JSONValidationViolation `json:"notAllowedCertificates,omitempty"`
+	MisplacedCertificates  []JSONValidationMisplaced `json:"misplacedCertificates,omitempty"`
+	RuleViolations         []JSONRuleViolation       `json:"ruleViolations,omitempty"`
+	Waived                 []JSONWaivedIssue         `json:"waived,omitempty"`
+	RequiredButAbsent      []JSONValidationEntry     `json:"requiredButAbsent,omitempty"`
+	ForbiddenPrivateKeys   []string                  `json:"forbiddenPrivateKeys,omitempty"`
+}
+
+type JSONValidationViolation struct {
+	FileLocation      string   `json:"fileLocation"`
+	FingerprintSHA256 string   `json:"fingerprintSHA256"`
+	Comment           string   `json:"comment,omitempty"`
+	Matchers          []string `json:"matchers,omitempty"`
+}
+
+// JSONValidationMisplaced is a certificate found outside of the paths its
+// allow or require entry permits, or under a path rule which doesn't allow it.
+type JSONValidationMisplaced struct {
+	FileLocation      string   `json:"fileLocation"`
+	FingerprintSHA256 string   `json:"fingerprintSHA256"`
+	Comment           string   `json:"comment,omitempty"`
+	AllowedPaths      []string `json:"allowedPaths,omitempty"`
+	PathRule          string   `json:"pathRule,omitempty"`
+}
+
+// JSONRuleViolation is a certificate, or partial certificate without a
+// fingerprint, which fails one of the config's rules.
+type JSONRuleViolation struct {
+	Rule              string `json:"rule"`
+	FileLocation      string `json:"fileLocation"`
+	FingerprintSHA256 string `json:"fingerprintSHA256,omitempty"`
+	Reason            string `json:"reason"`
+}
+
+// JSONWaivedIssue is an issue which an exception in the config waived.
+type JSONWaivedIssue struct {
+	Issue             string        `json:"issue"`
+	FileLocation      string        `json:"fileLocation"`
+	FingerprintSHA256 string        `json:"fingerprintSHA256,omitempty"`
+	Exception         JSONException `json:"exception"`
+}
+
+type JSONException struct {
+	Owner   string    `json:"owner"`
+	Ticket  string    `json:"ticket,omitempty"`
+	Comment string    `json:"comment,omitempty"`
+	Expires time.Time `json:"expires"`
+}
+
+type JSONValidationEntry struct {
+	SHA1     string   `json:"sha1,omitempty"`
+	SHA256   string   `json:"sha256,omitempty"`
+	Comment  string   `json:"comment,omitempty"`
+	Matchers []string `json:"matchers,omitempty"`
+}
+
+func NewJSONValidation(result validate.Result) JSONValidation {
+	out := JSONValidation{Pass: result.IsPass()}
+	for _, f := range result.ForbiddenCertificates {
+		out.ForbiddenCertificates = append(out.ForbiddenCertificates, JSONValidationViolation{
+			FileLocation:      f.Certificate.Location,
+			FingerprintSHA256: hex.EncodeToString(f.Certificate.FingerprintSha256[:]),
+			Comment:           f.Entry.Comment,
+			Matchers:          entryMatchers(f.Entry),
+		})
+	}
+	for _, na := range result.NotAllowedCertificates {
+		out.NotAllowedCertificates = append(out.NotAllowedCertificates, JSONValidationViolation{
+			FileLocation:      na.Location,
+			FingerprintSHA256: hex.EncodeToString(na.FingerprintSha256[:]),
+		})
+	}
+	for _, m := range result.MisplacedCertificates {
+		misplaced := JSONValidationMisplaced{
+			FileLocation:      m.Certificate.Location,
+			FingerprintSHA256: hex.EncodeToString(m.Certificate.FingerprintSha256[:]),
+		}
+		if m.Entry != nil {
+			misplaced.Comment = m.Entry.Comment
+			misplaced.AllowedPaths = m.Entry.Paths
+		} else {
+			misplaced.Comment = m.Rule.Comment
+			misplaced.PathRule = m.Rule.Path
+		}
+		out.MisplacedCertificates = append(out.MisplacedCertificates, misplaced)
+	}
+	for _, req := range result.RequiredButAbsent {
+		out.RequiredButAbsent = append(out.RequiredButAbsent, JSONValidationEntry{
+			SHA1:     req.Fingerprints.Sha1,
+			SHA256:   req.Fingerprints.Sha256,
+			Comment:  req.Comment,
+			Matchers: entryMatchers(req),
+		})
+	}
+	for _, key := range result.ForbiddenPrivateKeys {
+		out.ForbiddenPrivateKeys = append(out.ForbiddenPrivateKeys, key.Location)
+	}
+	for _, rv := range result.RuleViolations {
+		violation := JSONRuleViolation{Rule: rv.Rule, FileLocation: rv.Location, Reason: rv.Reason}
+		if rv.Certificate != nil {
+			violation.FingerprintSHA256 = hex.EncodeToString(rv.Certificate.FingerprintSha256[:])
+		}
+		out.RuleViolations = append(out.RuleViolations, violation)
+	}
+	for _, w := range result.Waived {
+		waived := JSONWaivedIssue{
+			Issue:        w.Issue,
+			FileLocation: w.Location,
+			Exception: JSONException{
+				Owner:   w.Exception.Owner,
+				Ticket:  w.Exception.Ticket,
+				Comment: w.Exception.Comment,
+				Expires: w.Exception.Expires,
+			},
+		}
+		if w.Certificate != nil {
+			waived.FingerprintSHA256 = hex.EncodeToString(w.Certificate.FingerprintSha256[:])
+		}
+		out.Waived = append(out.Waived, waived)
+	}
+	return out
+}
+
+// entryMatchers describes the matchers of a config entry, unless it only
+// matches by the fingerprints which are already in the output.
+func entryMatchers(entry validate.CertificateEntry) []string {
+	if entry.IsFingerprintOnly() {
+		return nil
+	}
+	return entry.Matchers()
+}
@@ -0,0 +1,200 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func TestNewJSONCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(0xbeef),
+		Subject:               pkix.Name{CommonName: "Paranoia Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		MaxPathLenZero:        true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		UnknownExtKeyUsage:    []asn1.ObjectIdentifier{{1, 3, 6, 1, 4, 1, 99999, 1}},
+		DNSNames:              []string{"example.com"},
+		IPAddresses:           []net.IP{net.ParseIP("10.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	found := certificate.Found{
+		Location:          "/etc/ssl/certs/test.pem",
+		Parser:            "pem",
+		Certificate:       cert,
+		FingerprintSha1:   sha1.Sum(der),
+		FingerprintSha256: sha256.Sum256(der),
+	}
+
+	t.Run("all fields are populated", func(t *testing.T) {
+		out := NewJSONCertificate(found, false)
+		assert.Equal(t, "CN=Paranoia Test CA", out.Owner)
+		assert.Equal(t, "CN=Paranoia Test CA", out.Issuer)
+		assert.Equal(t, "beef", out.SerialNumber)
+		assert.Equal(t, "ECDSA", out.KeyAlgorithm)
+		assert.Equal(t, 256, out.KeySize)
+		assert.Equal(t, "ECDSA-SHA256", out.SignatureAlgorithm)
+		assert.Equal(t, []string{"keyCertSign", "cRLSign"}, out.KeyUsages)
+		assert.Equal(t, []string{"serverAuth", "1.3.6.1.4.1.99999.1"}, out.ExtKeyUsages)
+		require.NotNil(t, out.SubjectAltNames)
+		assert.Equal(t, []string{"example.com"}, out.SubjectAltNames.DNSNames)
+		assert.Equal(t, []string{"10.0.0.1"}, out.SubjectAltNames.IPAddresses)
+		require.NotNil(t, out.BasicConstraints)
+		assert.True(t, out.BasicConstraints.IsCA)
+		require.NotNil(t, out.BasicConstraints.MaxPathLen)
+		assert.Equal(t, 0, *out.BasicConstraints.MaxPathLen)
+		assert.NotEmpty(t, out.SubjectKeyID)
+		assert.Len(t, out.SPKISHA256, 64)
+		assert.Empty(t, out.PEM)
+	})
+
+	t.Run("trust settings are included for trusted certificates", func(t *testing.T) {
+		assert.Nil(t, NewJSONCertificate(found, false).TrustSettings)
+
+		trusted := found
+		trusted.TrustSettings = &certificate.TrustSettings{Trusted: []string{"serverAuth"}, Rejected: []string{"emailProtection"}}
+		assert.Equal(t, &JSONTrustSettings{Trusted: []string{"serverAuth"}, Rejected: []string{"emailProtection"}},
+			NewJSONCertificate(trusted, false).TrustSettings)
+	})
+
+	t.Run("PEM is embedded when requested", func(t *testing.T) {
+		out := NewJSONCertificate(found, true)
+		block, _ := pem.Decode([]byte(out.PEM))
+		require.NotNil(t, block)
+		assert.Equal(t, der, block.Bytes)
+	})
+}
+
+func TestNewJSONPrivateKey(t *testing.T) {
+	assert.Equal(t, JSONPrivateKey{
+		FileLocation:         "/app/server.key",
+		Parser:               "pem",
+		Format:               "SEC 1",
+		KeyAlgorithm:         "ECDSA",
+		KeySize:              256,
+		SPKISHA256:           "0100000000000000000000000000000000000000000000000000000000000000",
+		MatchingCertificates: []string{"0200000000000000000000000000000000000000000000000000000000000000"},
+	}, NewJSONPrivateKey(certificate.PrivateKey{
+		Location:             "/app/server.key",
+		Parser:               "pem",
+		Format:               "SEC 1",
+		Type:                 "ECDSA",
+		Size:                 256,
+		SPKISHA256:           [32]byte{1},
+		MatchingCertificates: [][32]byte{{2}},
+	}))
+
+	assert.Equal(t, JSONPrivateKey{
+		FileLocation: "/app/server.key",
+		Parser:       "pem",
+		Format:       "PKCS#8",
+		Encrypted:    true,
+	}, NewJSONPrivateKey(certificate.PrivateKey{
+		Location:  "/app/server.key",
+		Parser:    "pem",
+		Format:    "PKCS#8",
+		Encrypted: true,
+	}))
+}
+
+func TestNewJSONCRL(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Paranoia Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		SubjectKeyId:          []byte{1, 2, 3},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	issuer, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	thisUpdate := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	crlDER, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(0x10),
+		ThisUpdate: thisUpdate,
+		NextUpdate: thisUpdate.Add(24 * time.Hour),
+		RevokedCertificateEntries: []x509.RevocationListEntry{
+			{SerialNumber: big.NewInt(2), RevocationTime: thisUpdate},
+		},
+	}, issuer, key)
+	require.NoError(t, err)
+	crl, err := x509.ParseRevocationList(crlDER)
+	require.NoError(t, err)
+
+	out := NewJSONCRL(certificate.CRL{
+		Location:          "/etc/ssl/crl.pem",
+		Parser:            "pem",
+		RevocationList:    crl,
+		FingerprintSha256: sha256.Sum256(crlDER),
+	})
+	assert.Equal(t, "/etc/ssl/crl.pem", out.FileLocation)
+	assert.Equal(t, "CN=Paranoia Test CA", out.Issuer)
+	assert.Equal(t, "10", out.Number)
+	assert.Equal(t, "2024-01-01T00:00:00Z", out.ThisUpdate)
+	assert.Equal(t, "2024-01-02T00:00:00Z", out.NextUpdate)
+	assert.Equal(t, 1, out.RevokedCertificates)
+	assert.Equal(t, "010203", out.AuthorityKeyID)
+	assert.Len(t, out.FingerprintSHA256, 64)
+}
+
+func TestNewJSONCertificateRequest(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: "leaf.example.com"},
+		DNSNames: []string{"leaf.example.com"},
+	}, key)
+	require.NoError(t, err)
+	csr, err := x509.ParseCertificateRequest(der)
+	require.NoError(t, err)
+
+	out := NewJSONCertificateRequest(certificate.CertificateRequest{
+		Location:          "/app/leaf.csr",
+		Parser:            "pem",
+		Request:           csr,
+		FingerprintSha256: sha256.Sum256(der),
+	})
+	assert.Equal(t, "/app/leaf.csr", out.FileLocation)
+	assert.Equal(t, "CN=leaf.example.com", out.Subject)
+	assert.Equal(t, "ECDSA", out.KeyAlgorithm)
+	assert.Equal(t, 256, out.KeySize)
+	assert.Equal(t, "ECDSA-SHA256", out.SignatureAlgorithm)
+	require.NotNil(t, out.SubjectAltNames)
+	assert.Equal(t, []string{"leaf.example.com"}, out.SubjectAltNames.DNSNames)
+	assert.Len(t, out.SPKISHA256, 64)
+	assert.Len(t, out.FingerprintSHA256, 64)
+}
@@ -0,0 +1,82 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// JUnitTestSuites is the root element of a JUnit XML report.
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr,omitempty"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Skipped  int              `xml:"skipped,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr,omitempty"`
+	Failures  []JUnitResult `xml:"failure,omitempty"`
+	Skipped   *JUnitResult  `xml:"skipped,omitempty"`
+}
+
+// JUnitResult is the body of either a failure or a skipped element.
+type JUnitResult struct {
+	Message string `xml:"message,attr,omitempty"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// AddSuite appends the given suite to the report, updating the suite and
+// report totals from its test cases.
+func (j *JUnitTestSuites) AddSuite(suite JUnitTestSuite) {
+	suite.Tests, suite.Failures, suite.Skipped = 0, 0, 0
+	for _, tc := range suite.TestCases {
+		suite.Tests++
+		if len(tc.Failures) > 0 {
+			suite.Failures++
+		}
+		if tc.Skipped != nil {
+			suite.Skipped++
+		}
+	}
+	j.Tests += suite.Tests
+	j.Failures += suite.Failures
+	j.Skipped += suite.Skipped
+	j.Suites = append(j.Suites, suite)
+}
+
+// AddReport appends the suites of another report, such as that of one of many
+// images, prefixing each suite name.
+func (j *JUnitTestSuites) AddReport(prefix string, other *JUnitTestSuites) {
+	for _, suite := range other.Suites {
+		suite.Name = prefix + ": " + suite.Name
+		j.AddSuite(suite)
+	}
+}
+
+// Write encodes the report as indented XML, including the XML header.
+func (j *JUnitTestSuites) Write(w io.Writer) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(j); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
@@ -0,0 +1,69 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package output
+
+import (
+	"bytes"
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJUnitTestSuites(t *testing.T) {
+	report := JUnitTestSuites{Name: "paranoia validate example"}
+	report.AddSuite(JUnitTestSuite{
+		Name: "require",
+		TestCases: []JUnitTestCase{
+			{Name: "present"},
+			{Name: "absent", Failures: []JUnitResult{{Message: "was required, but was not found"}}},
+		},
+	})
+	report.AddSuite(JUnitTestSuite{
+		Name: "allow",
+		TestCases: []JUnitTestCase{
+			{Name: "only allowed certificates are present", Skipped: &JUnitResult{Message: "permissive mode"}},
+		},
+	})
+
+	assert.Equal(t, 3, report.Tests)
+	assert.Equal(t, 1, report.Failures)
+	assert.Equal(t, 1, report.Skipped)
+	assert.Equal(t, 2, report.Suites[0].Tests)
+	assert.Equal(t, 1, report.Suites[0].Failures)
+
+	var buf bytes.Buffer
+	require.NoError(t, report.Write(&buf))
+	assert.Contains(t, buf.String(), xml.Header)
+
+	var decoded JUnitTestSuites
+	require.NoError(t, xml.Unmarshal(buf.Bytes(), &decoded))
+	assert.Equal(t, report.Tests, decoded.Tests)
+	require.Len(t, decoded.Suites, 2)
+	require.Len(t, decoded.Suites[0].TestCases, 2)
+	assert.Equal(t, "was required, but was not found", decoded.Suites[0].TestCases[1].Failures[0].Message)
+	assert.Equal(t, "permissive mode", decoded.Suites[1].TestCases[0].Skipped.Message)
+}
+
+func TestJUnitTestSuites_AddReport(t *testing.T) {
+	image := &JUnitTestSuites{Name: "paranoia inspect example"}
+	image.AddSuite(JUnitTestSuite{
+		Name: "certificates",
+		TestCases: []JUnitTestCase{
+			{Name: "ok"},
+			{Name: "expired", Failures: []JUnitResult{{Message: "expired"}}},
+		},
+	})
+
+	report := JUnitTestSuites{Name: "paranoia inspect"}
+	report.AddReport("example@sha256:abc", image)
+	report.AddReport("other@sha256:def", image)
+
+	assert.Equal(t, 4, report.Tests)
+	assert.Equal(t, 2, report.Failures)
+	require.Len(t, report.Suites, 2)
+	assert.Equal(t, "example@sha256:abc: certificates", report.Suites[0].Name)
+	assert.Equal(t, "other@sha256:def: certificates", report.Suites[1].Name)
+	assert.Equal(t, "certificates", image.Suites[0].Name)
+}
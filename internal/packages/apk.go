@@ -0,0 +1,86 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package packages
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// apkInstalled is the database of packages installed by apk on Alpine.
+const apkInstalled = "/lib/apk/db/installed"
+
+// maxLineLength is the longest line read from a package database.
+const maxLineLength = 1 << 20
+
+// apk reads the files owned by packages installed by apk. The database is a
+// stanza per package of single letter keys, where F is a directory and R is a
+// file within it, followed by its checksum in Z.
+func apk(parsed *certificate.ParsedCertificates) (map[string]owner, error) {
+	owners := make(map[string]owner)
+
+	var (
+		pkg  = certificate.Package{Manager: "apk"}
+		dir  string
+		file string
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(parsed.Files[apkInstalled].Contents))
+	scanner.Buffer(nil, maxLineLength)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			pkg, dir, file = certificate.Package{Manager: "apk"}, "", ""
+			continue
+		}
+
+		switch key {
+		case "P":
+			pkg.Name = value
+		case "V":
+			pkg.Version = value
+		case "F":
+			dir, file = path.Join("/", value), ""
+		case "R":
+			file = path.Join(dir, value)
+			owners[file] = owner{pkg: pkg}
+		case "Z":
+			if file == "" {
+				continue
+			}
+			o := owners[file]
+			o.algorithm, o.checksum = parseAPKChecksum(value)
+			owners[file] = o
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.Wrap(err, apkInstalled)
+	}
+
+	return owners, nil
+}
+
+// parseAPKChecksum parses an apk checksum, which is base64 encoded with a
+// prefix of Q1 for SHA-1 or Q2 for SHA-256.
+func parseAPKChecksum(value string) (algorithm, []byte) {
+	var a algorithm
+	switch {
+	case strings.HasPrefix(value, "Q1"):
+		a = algorithmSHA1
+	case strings.HasPrefix(value, "Q2"):
+		a = algorithmSHA256
+	default:
+		return algorithmUnknown, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(value[2:])
+	if err != nil {
+		return algorithmUnknown, nil
+	}
+	return a, b
+}
@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package packages
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"path"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+const (
+	// dpkgStatus lists the packages known to dpkg, and their state.
+	dpkgStatus = "/var/lib/dpkg/status"
+	// dpkgStatusDir contains a status file per package in distroless images,
+	// which don't ship dpkg itself, alongside the package's md5sums.
+	dpkgStatusDir = "/var/lib/dpkg/status.d/"
+	// dpkgInfoDir contains the files installed by each package, in
+	// <package>.list, and their checksums, in <package>.md5sums. The package
+	// may be qualified by its architecture, as in <package>:<arch>.
+	dpkgInfoDir = "/var/lib/dpkg/info/"
+)
+
+// dpkg reads the files owned by packages installed by dpkg.
+func dpkg(parsed *certificate.ParsedCertificates) (map[string]owner, error) {
+	owners := make(map[string]owner)
+
+	stanzas, err := parseControl(parsed.Files[dpkgStatus].Contents)
+	if err != nil {
+		return nil, errors.Wrap(err, dpkgStatus)
+	}
+	for _, stanza := range stanzas {
+		if !isInstalled(stanza["Status"]) {
+			continue
+		}
+		pkg := dpkgPackage(stanza)
+
+		var (
+			files []string
+			sums  map[string][]byte
+		)
+		for _, name := range []string{stanza["Package"], stanza["Package"] + ":" + stanza["Architecture"]} {
+			files = append(files, parseList(parsed.Files[dpkgInfoDir+name+".list"].Contents)...)
+			if s := parseMD5Sums(parsed.Files[dpkgInfoDir+name+".md5sums"].Contents); s != nil {
+				sums = s
+			}
+		}
+		// Configuration files aren't in md5sums, but their checksums are
+		// recorded in the status file instead.
+		conffiles := parseConffiles(stanza["Conffiles"])
+
+		for _, file := range files {
+			o := owner{pkg: pkg, algorithm: algorithmMD5, checksum: sums[file]}
+			if sum, ok := conffiles[file]; ok {
+				o.checksum = sum
+			}
+			owners[file] = o
+		}
+	}
+
+	// Distroless images have no list files, so the files owned by each package
+	// are only those with checksums.
+	for name, f := range parsed.Files {
+		if !strings.HasPrefix(name, dpkgStatusDir) || strings.HasSuffix(name, ".md5sums") {
+			continue
+		}
+		sums := parseMD5Sums(parsed.Files[name+".md5sums"].Contents)
+		stanzas, err := parseControl(f.Contents)
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		for _, stanza := range stanzas {
+			if status, ok := stanza["Status"]; ok && !isInstalled(status) {
+				continue
+			}
+			pkg := dpkgPackage(stanza)
+			for file, sum := range sums {
+				owners[file] = owner{pkg: pkg, algorithm: algorithmMD5, checksum: sum}
+			}
+		}
+	}
+
+	return owners, nil
+}
+
+func dpkgPackage(stanza map[string]string) certificate.Package {
+	return certificate.Package{
+		Manager: "dpkg",
+		Name:    stanza["Package"],
+		Version: stanza["Version"],
+	}
+}
+
+// isInstalled returns true if the dpkg Status field, such as "install ok
+// installed", is of an installed package.
+func isInstalled(status string) bool {
+	fields := strings.Fields(status)
+	return len(fields) == 3 && fields[2] == "installed"
+}
+
+// parseControl parses the stanzas of a Debian control file, such as the dpkg
+// status file. Continuation lines of a field are joined with newlines.
+func parseControl(data []byte) ([]map[string]string, error) {
+	var (
+		stanzas []map[string]string
+		stanza  map[string]string
+		field   string
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxLineLength)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.TrimSpace(line) == "":
+			stanza = nil
+		case line[0] == ' ' || line[0] == '\t':
+			if stanza != nil && field != "" {
+				stanza[field] += "\n" + strings.TrimSpace(line)
+			}
+		default:
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			if stanza == nil {
+				stanza = make(map[string]string)
+				stanzas = append(stanzas, stanza)
+			}
+			field = key
+			stanza[field] = strings.TrimSpace(value)
+		}
+	}
+	return stanzas, scanner.Err()
+}
+
+// parseList parses a dpkg list file, of one path per line.
+func parseList(data []byte) []string {
+	var files []string
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); line != "" && line != "/." {
+			files = append(files, line)
+		}
+	}
+	return files
+}
+
+// parseMD5Sums parses a dpkg md5sums file, of a checksum and a path relative
+// to the root on each line.
+func parseMD5Sums(data []byte) map[string][]byte {
+	if data == nil {
+		return nil
+	}
+	sums := make(map[string][]byte)
+	for _, line := range strings.Split(string(data), "\n") {
+		sum, file, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		b, err := hex.DecodeString(sum)
+		if err != nil {
+			continue
+		}
+		sums[path.Join("/", strings.TrimSpace(file))] = b
+	}
+	return sums
+}
+
+// parseConffiles parses the Conffiles field of a dpkg status stanza, of a path
+// and checksum on each line, optionally followed by flags such as "obsolete".
+func parseConffiles(value string) map[string][]byte {
+	sums := make(map[string][]byte)
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		if b, err := hex.DecodeString(fields[1]); err == nil {
+			sums[fields[0]] = b
+		}
+	}
+	return sums
+}
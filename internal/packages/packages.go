@@ -0,0 +1,98 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package packages attributes the files certificates are found in to the
+// operating system packages which installed them, using the package databases
+// of dpkg, apk and rpm captured while scanning. Files whose contents no longer
+// match the checksum recorded by the package manager are flagged as modified.
+package packages
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// algorithm is a digest algorithm used by a package manager to record the
+// checksums of installed files.
+type algorithm int
+
+const (
+	algorithmUnknown algorithm = iota
+	algorithmMD5
+	algorithmSHA1
+	algorithmSHA256
+)
+
+// sum returns the digest of the file in this algorithm, or nil if it isn't
+// one which is computed while scanning.
+func (a algorithm) sum(d *certificate.Digests) []byte {
+	switch a {
+	case algorithmMD5:
+		return d.MD5[:]
+	case algorithmSHA1:
+		return d.SHA1[:]
+	case algorithmSHA256:
+		return d.SHA256[:]
+	default:
+		return nil
+	}
+}
+
+// owner is the package which installed a file, and the checksum it recorded
+// for the file's contents. The checksum is nil if none was recorded.
+type owner struct {
+	pkg       certificate.Package
+	algorithm algorithm
+	checksum  []byte
+}
+
+// database reads the files owned by each installed package, keyed by absolute
+// path, from a package database within the parsed image.
+type database func(parsed *certificate.ParsedCertificates) (map[string]owner, error)
+
+// databases are the package databases read, in order. Where more than one
+// claims a file, the last wins.
+var databases = []struct {
+	name string
+	read database
+}{
+	{"dpkg", dpkg},
+	{"apk", apk},
+	{"rpm", rpm},
+}
+
+// Attribute sets the package of each certificate found in a file installed by
+// an operating system package, and whether the file has been modified since.
+// A package database which can't be read is skipped, so the certificates in
+// the files it owns are left without a package, and a warning is returned for
+// it.
+func Attribute(parsed *certificate.ParsedCertificates) (warnings []string) {
+	owners := make(map[string]owner)
+	for _, db := range databases {
+		o, err := db.read(parsed)
+		if err != nil {
+			warnings = append(warnings, fmt.Sprintf("failed to read %s package database, certificates won't be attributed to its packages: %s", db.name, err))
+			continue
+		}
+		for file, owner := range o {
+			owners[file] = owner
+		}
+	}
+
+	for i, found := range parsed.Found {
+		o, ok := owners[found.Location]
+		if !ok {
+			continue
+		}
+
+		pkg := o.pkg
+		if f := parsed.Files[found.Location]; f.Digests != nil && o.checksum != nil {
+			if sum := o.algorithm.sum(f.Digests); sum != nil {
+				pkg.Modified = !bytes.Equal(sum, o.checksum)
+			}
+		}
+		parsed.Found[i].Package = &pkg
+	}
+	return warnings
+}
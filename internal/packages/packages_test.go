@@ -0,0 +1,187 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package packages
+
+import (
+	"archive/tar"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func digests(contents string) *certificate.Digests {
+	return &certificate.Digests{
+		MD5:    md5.Sum([]byte(contents)),
+		SHA1:   sha1.Sum([]byte(contents)),
+		SHA256: sha256.Sum256([]byte(contents)),
+	}
+}
+
+func md5Hex(contents string) string {
+	sum := md5.Sum([]byte(contents))
+	return hex.EncodeToString(sum[:])
+}
+
+func packagesOf(parsed *certificate.ParsedCertificates) map[string]*certificate.Package {
+	pkgs := make(map[string]*certificate.Package)
+	for _, found := range parsed.Found {
+		pkgs[found.Location] = found.Package
+	}
+	return pkgs
+}
+
+func TestAttribute_dpkg(t *testing.T) {
+	status := `Package: ca-certificates
+Status: install ok installed
+Architecture: all
+Version: 20230311
+Conffiles:
+ /etc/ssl/certs/local.pem ` + md5Hex("local") + `
+Description: Common CA certificates
+ Contains the certificate authorities shipped with Mozilla's browser.
+
+Package: removed
+Status: deinstall ok config-files
+Version: 1.0
+`
+	parsed := &certificate.ParsedCertificates{
+		Files: map[string]certificate.File{
+			"/var/lib/dpkg/status": {Type: tar.TypeReg, Contents: []byte(status)},
+			"/var/lib/dpkg/info/ca-certificates.list": {Type: tar.TypeReg, Contents: []byte(
+				"/.\n/usr/share/ca-certificates/mozilla/A.crt\n/usr/share/ca-certificates/mozilla/B.crt\n/etc/ssl/certs/local.pem\n")},
+			"/var/lib/dpkg/info/ca-certificates.md5sums": {Type: tar.TypeReg, Contents: []byte(
+				md5Hex("a") + "  usr/share/ca-certificates/mozilla/A.crt\n" +
+					md5Hex("b") + "  usr/share/ca-certificates/mozilla/B.crt\n")},
+			"/usr/share/ca-certificates/mozilla/A.crt": {Type: tar.TypeReg, Digests: digests("a")},
+			"/usr/share/ca-certificates/mozilla/B.crt": {Type: tar.TypeReg, Digests: digests("changed")},
+			"/etc/ssl/certs/local.pem":                 {Type: tar.TypeReg, Digests: digests("local")},
+			"/app/ca.pem":                              {Type: tar.TypeReg, Digests: digests("app")},
+		},
+		Found: []certificate.Found{
+			{Location: "/usr/share/ca-certificates/mozilla/A.crt"},
+			{Location: "/usr/share/ca-certificates/mozilla/B.crt"},
+			{Location: "/etc/ssl/certs/local.pem"},
+			{Location: "/app/ca.pem"},
+		},
+	}
+
+	Attribute(parsed)
+	assert.Equal(t, map[string]*certificate.Package{
+		"/usr/share/ca-certificates/mozilla/A.crt": {Manager: "dpkg", Name: "ca-certificates", Version: "20230311"},
+		"/usr/share/ca-certificates/mozilla/B.crt": {Manager: "dpkg", Name: "ca-certificates", Version: "20230311", Modified: true},
+		"/etc/ssl/certs/local.pem":                 {Manager: "dpkg", Name: "ca-certificates", Version: "20230311"},
+		"/app/ca.pem":                              nil,
+	}, packagesOf(parsed))
+}
+
+func TestAttribute_distroless(t *testing.T) {
+	parsed := &certificate.ParsedCertificates{
+		Files: map[string]certificate.File{
+			"/var/lib/dpkg/status.d/ca-certificates": {Type: tar.TypeReg, Contents: []byte(
+				"Package: ca-certificates\nVersion: 20210119\nArchitecture: all\n")},
+			"/var/lib/dpkg/status.d/ca-certificates.md5sums": {Type: tar.TypeReg, Contents: []byte(
+				md5Hex("bundle") + "  etc/ssl/certs/ca-certificates.crt\n")},
+			"/etc/ssl/certs/ca-certificates.crt": {Type: tar.TypeReg, Digests: digests("bundle")},
+		},
+		Found: []certificate.Found{
+			{Location: "/etc/ssl/certs/ca-certificates.crt"},
+		},
+	}
+
+	Attribute(parsed)
+	assert.Equal(t, map[string]*certificate.Package{
+		"/etc/ssl/certs/ca-certificates.crt": {Manager: "dpkg", Name: "ca-certificates", Version: "20210119"},
+	}, packagesOf(parsed))
+}
+
+func TestAttribute_apk(t *testing.T) {
+	sum := sha1.Sum([]byte("bundle"))
+	installed := `C:Q1abc=
+P:ca-certificates-bundle
+V:20230506-r0
+F:etc
+F:etc/ssl/certs
+R:ca-certificates.crt
+a:0:0:644
+Z:Q1` + base64.StdEncoding.EncodeToString(sum[:]) + `
+R:other.pem
+Z:Q1` + base64.StdEncoding.EncodeToString(sum[:]) + `
+
+P:musl
+V:1.2.4-r2
+F:lib
+R:ld-musl-x86_64.so.1
+`
+	parsed := &certificate.ParsedCertificates{
+		Files: map[string]certificate.File{
+			"/lib/apk/db/installed":              {Type: tar.TypeReg, Contents: []byte(installed)},
+			"/etc/ssl/certs/ca-certificates.crt": {Type: tar.TypeReg, Digests: digests("bundle")},
+			"/etc/ssl/certs/other.pem":           {Type: tar.TypeReg, Digests: digests("edited")},
+		},
+		Found: []certificate.Found{
+			{Location: "/etc/ssl/certs/ca-certificates.crt"},
+			{Location: "/etc/ssl/certs/other.pem"},
+		},
+	}
+
+	Attribute(parsed)
+	assert.Equal(t, map[string]*certificate.Package{
+		"/etc/ssl/certs/ca-certificates.crt": {Manager: "apk", Name: "ca-certificates-bundle", Version: "20230506-r0"},
+		"/etc/ssl/certs/other.pem":           {Manager: "apk", Name: "ca-certificates-bundle", Version: "20230506-r0", Modified: true},
+	}, packagesOf(parsed))
+}
+
+func TestAttribute_unreadableDatabase(t *testing.T) {
+	parsed := &certificate.ParsedCertificates{
+		Files: map[string]certificate.File{
+			"/var/lib/dpkg/status.d/ca-certificates": {Type: tar.TypeReg, Contents: []byte(
+				"Package: ca-certificates\nVersion: 20210119\nArchitecture: all\n")},
+			"/var/lib/dpkg/status.d/ca-certificates.md5sums": {Type: tar.TypeReg, Contents: []byte(
+				md5Hex("bundle") + "  etc/ssl/certs/ca-certificates.crt\n")},
+			"/var/lib/rpm/rpmdb.sqlite":          {Type: tar.TypeReg, Contents: []byte("not a database")},
+			"/etc/ssl/certs/ca-certificates.crt": {Type: tar.TypeReg, Digests: digests("bundle")},
+			"/etc/pki/tls/certs/ca-bundle.crt":   {Type: tar.TypeReg, Digests: digests("rpm")},
+		},
+		Found: []certificate.Found{
+			{Location: "/etc/ssl/certs/ca-certificates.crt"},
+			{Location: "/etc/pki/tls/certs/ca-bundle.crt"},
+		},
+	}
+
+	warnings := Attribute(parsed)
+	assert.Equal(t, map[string]*certificate.Package{
+		"/etc/ssl/certs/ca-certificates.crt": {Manager: "dpkg", Name: "ca-certificates", Version: "20210119"},
+		"/etc/pki/tls/certs/ca-bundle.crt":   nil,
+	}, packagesOf(parsed))
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "rpm package database")
+}
+
+func TestAttribute_overlongLine(t *testing.T) {
+	long := "Description: " + strings.Repeat("x", maxLineLength) + "\n"
+	for name, file := range map[string]string{
+		"apk":  "/lib/apk/db/installed",
+		"dpkg": "/var/lib/dpkg/status",
+	} {
+		t.Run(name, func(t *testing.T) {
+			parsed := &certificate.ParsedCertificates{
+				Files: map[string]certificate.File{
+					file: {Type: tar.TypeReg, Contents: []byte("Package: ca-certificates\n" + long)},
+				},
+			}
+			warnings := Attribute(parsed)
+			require.Len(t, warnings, 1)
+			assert.Contains(t, warnings[0], name+" package database")
+			assert.Contains(t, warnings[0], "token too long")
+		})
+	}
+}
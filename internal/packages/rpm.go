@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package packages
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	// Registers the pure Go SQLite driver used to open rpmdb.sqlite.
+	_ "github.com/glebarez/go-sqlite"
+	rpmdb "github.com/knqyf263/go-rpmdb/pkg"
+	"github.com/pkg/errors"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// rpmDatabases are the locations of the rpm database, in the SQLite format
+// used by recent Red Hat derived distributions, the Berkeley DB format used by
+// older ones, and the NDB format used by SUSE.
+var rpmDatabases = []string{
+	"/var/lib/rpm/rpmdb.sqlite",
+	"/usr/lib/sysimage/rpm/rpmdb.sqlite",
+	"/var/lib/rpm/Packages",
+	"/usr/lib/sysimage/rpm/Packages.db",
+}
+
+// rpm reads the files owned by packages installed by rpm. The database can
+// only be opened from disk, so is written to a temporary file first.
+func rpm(parsed *certificate.ParsedCertificates) (map[string]owner, error) {
+	for _, name := range rpmDatabases {
+		f, ok := parsed.Files[name]
+		if !ok || len(f.Contents) == 0 {
+			continue
+		}
+		owners, err := readRPMDatabase(f.Contents)
+		if err != nil {
+			return nil, errors.Wrap(err, name)
+		}
+		return owners, nil
+	}
+	return nil, nil
+}
+
+func readRPMDatabase(contents []byte) (map[string]owner, error) {
+	tmp, err := os.CreateTemp("", "paranoia-rpmdb-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(contents); err != nil {
+		tmp.Close()
+		return nil, err
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	db, err := rpmdb.Open(tmp.Name())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	pkgs, err := db.ListPackages()
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(map[string]owner)
+	for _, p := range pkgs {
+		files, err := p.InstalledFiles()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list files of %s", p.Name)
+		}
+
+		pkg := certificate.Package{
+			Manager: "rpm",
+			Name:    p.Name,
+			Version: p.Version + "-" + p.Release,
+		}
+		if p.Epoch != nil && *p.Epoch != 0 {
+			pkg.Version = fmt.Sprintf("%d:%s", *p.Epoch, pkg.Version)
+		}
+		a := rpmAlgorithm(p.DigestAlgorithm)
+
+		for _, file := range files {
+			o := owner{pkg: pkg}
+			if sum, err := hex.DecodeString(file.Digest); err == nil && len(sum) > 0 {
+				o.algorithm, o.checksum = a, sum
+			}
+			owners[file.Path] = o
+		}
+	}
+
+	return owners, nil
+}
+
+// rpmAlgorithm maps the digest algorithm of a package's files. Packages which
+// don't record one use MD5.
+func rpmAlgorithm(a rpmdb.DigestAlgorithm) algorithm {
+	switch a {
+	case 0, rpmdb.PGPHASHALGO_MD5:
+		return algorithmMD5
+	case rpmdb.PGPHASHALGO_SHA1:
+		return algorithmSHA1
+	case rpmdb.PGPHASHALGO_SHA256:
+		return algorithmSHA256
+	default:
+		return algorithmUnknown
+	}
+}
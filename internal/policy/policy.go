@@ -0,0 +1,275 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package policy writes and maintains validate configs from the certificates
+// found in an image. Configs are edited as YAML nodes, so that the comments,
+// ordering, and formatting of hand-edited configs are preserved.
+package policy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+// Policy is a validate config, as a YAML document.
+type Policy struct {
+	doc yaml.Node
+}
+
+// Changes are the entries added to and pruned from a policy.
+type Changes struct {
+	// Allowed and Required are the entries added to the allow and require
+	// lists.
+	Allowed  []validate.CertificateEntry
+	Required []validate.CertificateEntry
+	// Pruned are the allow list entries removed because their certificates
+	// were no longer found.
+	Pruned []validate.CertificateEntry
+}
+
+// IsEmpty returns true if the policy was not changed.
+func (c Changes) IsEmpty() bool {
+	return len(c.Allowed) == 0 && len(c.Required) == 0 && len(c.Pruned) == 0
+}
+
+// New builds a policy allowing every certificate found, once per fingerprint.
+// Certificates whose subject is one of the given subjects, either the full
+// distinguished name or the common name, are required instead. It is an error
+// for a subject to match no certificate.
+func New(found []certificate.Found, require []string, headComment string) (*Policy, Changes, error) {
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	p := &Policy{doc: yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{root}}}
+	root.HeadComment = headComment
+	root.Content = append(root.Content, scalar("version"), quoted(validate.ConfigVersion2))
+	sequence(root, "require")
+	sequence(root, "allow")
+
+	changes, err := p.add(unique(found), require)
+	if err != nil {
+		return nil, Changes{}, err
+	}
+	removeEmpty(root, "require")
+	return p, changes, nil
+}
+
+// Load reads a policy from the given file.
+func Load(fileName string) (*Policy, error) {
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+	var p Policy
+	if err := yaml.Unmarshal(data, &p.doc); err != nil {
+		return nil, errors.Wrap(err, "failed to parse config")
+	}
+	if p.doc.Kind != yaml.DocumentNode || len(p.doc.Content) != 1 || p.doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("config must be a YAML mapping")
+	}
+	return &p, nil
+}
+
+// Update brings the policy up to date with the certificates found in an image.
+// Certificates which the validator doesn't allow, and doesn't forbid or
+// consider misplaced, are added to the allow list. Allow list entries which
+// identify a certificate only by its fingerprint are pruned if the certificate
+// was not found. Other entries, and the require and forbid lists, are left
+// untouched.
+func (p *Policy) Update(validator *validate.Validator, found []certificate.Found) (Changes, error) {
+	result, err := validator.Validate(found)
+	if err != nil {
+		return Changes{}, err
+	}
+	var add []certificate.Found
+	for _, f := range result.NotAllowedCertificates {
+		if forbidden, _ := validator.IsForbidden(f); !forbidden {
+			add = append(add, f)
+		}
+	}
+	changes, err := p.add(unique(add), nil)
+	if err != nil {
+		return Changes{}, err
+	}
+	changes.Pruned, err = p.prune(found)
+	return changes, err
+}
+
+// Write writes the policy as YAML.
+func (p *Policy) Write(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&p.doc); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// WriteFile writes the policy to the named file.
+func (p *Policy) WriteFile(fileName string) error {
+	var buf bytes.Buffer
+	if err := p.Write(&buf); err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, buf.Bytes(), 0o644)
+}
+
+// add appends an entry for each certificate to the allow list, or the require
+// list if its subject is one of the required subjects.
+func (p *Policy) add(found []certificate.Found, require []string) (Changes, error) {
+	var changes Changes
+	matched := make(map[string]bool)
+	root := p.doc.Content[0]
+	for _, f := range found {
+		entry := entryFor(f)
+		list, added := "allow", &changes.Allowed
+		for _, subject := range require {
+			if f.Certificate != nil && (f.Certificate.Subject.String() == subject || f.Certificate.Subject.CommonName == subject) {
+				matched[subject] = true
+				list, added = "require", &changes.Required
+			}
+		}
+		seq := sequence(root, list)
+		seq.Content = append(seq.Content, entryNode(entry))
+		*added = append(*added, entry)
+	}
+	for _, subject := range require {
+		if !matched[subject] {
+			return Changes{}, errors.Errorf("no certificate found with subject %q", subject)
+		}
+	}
+	return changes, nil
+}
+
+// prune removes the fingerprint-only allow list entries whose certificates
+// were not found.
+func (p *Policy) prune(found []certificate.Found) ([]validate.CertificateEntry, error) {
+	seq := lookup(p.doc.Content[0], "allow")
+	if seq == nil || seq.Kind != yaml.SequenceNode {
+		return nil, nil
+	}
+	fingerprints := make(map[string]bool)
+	for _, f := range found {
+		fingerprints[hex.EncodeToString(f.FingerprintSha256[:])] = true
+		fingerprints[hex.EncodeToString(f.FingerprintSha1[:])] = true
+	}
+
+	var (
+		pruned []validate.CertificateEntry
+		kept   []*yaml.Node
+	)
+	for i, node := range seq.Content {
+		var entry validate.CertificateEntry
+		if err := node.Decode(&entry); err != nil {
+			return nil, errors.Wrapf(err, "allow list entry at position %d", i)
+		}
+		fingerprint := entry.Fingerprints.Sha256
+		if fingerprint == "" {
+			fingerprint = entry.Fingerprints.Sha1
+		}
+		if entry.IsFingerprintOnly() && len(entry.Paths) == 0 && fingerprint != "" &&
+			!fingerprints[strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))] {
+			pruned = append(pruned, entry)
+			continue
+		}
+		kept = append(kept, node)
+	}
+	seq.Content = kept
+	return pruned, nil
+}
+
+// unique returns the certificates once per fingerprint, sorted by subject so
+// that the entries are easy to review.
+func unique(found []certificate.Found) []certificate.Found {
+	var out []certificate.Found
+	seen := make(map[[32]byte]bool)
+	for _, f := range found {
+		if seen[f.FingerprintSha256] {
+			continue
+		}
+		seen[f.FingerprintSha256] = true
+		out = append(out, f)
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		return entryFor(out[i]).Comment < entryFor(out[j]).Comment
+	})
+	return out
+}
+
+// entryFor returns an entry for the certificate, identified by its SHA256
+// fingerprint with its subject as the comment.
+func entryFor(found certificate.Found) validate.CertificateEntry {
+	entry := validate.CertificateEntry{
+		Fingerprints: validate.CertificateFingerprints{Sha256: hex.EncodeToString(found.FingerprintSha256[:])},
+	}
+	if found.Certificate != nil {
+		entry.Comment = found.Certificate.Subject.String()
+	}
+	return entry
+}
+
+func entryNode(entry validate.CertificateEntry) *yaml.Node {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	if entry.Comment != "" {
+		node.Content = append(node.Content, scalar("comment"), quoted(entry.Comment))
+	}
+	fingerprints := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Content: []*yaml.Node{
+		scalar("sha256"), scalar(entry.Fingerprints.Sha256),
+	}}
+	node.Content = append(node.Content, scalar("fingerprints"), fingerprints)
+	return node
+}
+
+// lookup returns the value of the key in the mapping, or nil if it isn't set.
+func lookup(mapping *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// sequence returns the block sequence for the key in the mapping, adding it
+// if it isn't set or is empty.
+func sequence(mapping *yaml.Node, key string) *yaml.Node {
+	seq := lookup(mapping, key)
+	if seq == nil {
+		seq = &yaml.Node{}
+		mapping.Content = append(mapping.Content, scalar(key), seq)
+	}
+	if seq.Kind != yaml.SequenceNode {
+		// An empty key, such as "allow:", is null.
+		*seq = yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", HeadComment: seq.HeadComment, LineComment: seq.LineComment}
+	}
+	if len(seq.Content) == 0 {
+		// Entries are written in block style, rather than "allow: [{...}]".
+		seq.Style = 0
+	}
+	return seq
+}
+
+// removeEmpty removes the key from the mapping if its sequence is empty.
+func removeEmpty(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key && len(mapping.Content[i+1].Content) == 0 {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+func scalar(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
+
+func quoted(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value, Style: yaml.DoubleQuotedStyle}
+}
@@ -0,0 +1,140 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/certificate/certificatetest"
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+func write(t *testing.T, p *Policy) string {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, p.Write(&buf))
+	return buf.String()
+}
+
+func TestNew(t *testing.T) {
+	certs := []certificate.Found{
+		certificatetest.Root("B", "/etc/ssl/certs/ca-certificates.crt"),
+		certificatetest.Root("A", "/etc/ssl/certs/ca-certificates.crt"),
+		certificatetest.Root("B", "/usr/share/ca-certificates/B.crt"),
+	}
+
+	p, changes, err := New(certs, []string{"B"}, "Generated from example")
+	require.NoError(t, err)
+	assert.Len(t, changes.Allowed, 1)
+	assert.Len(t, changes.Required, 1)
+	assert.Equal(t, `# Generated from example
+version: "2"
+require:
+  - comment: "CN=B"
+    fingerprints:
+      sha256: `+certificatetest.Fingerprint("B")+`
+allow:
+  - comment: "CN=A"
+    fingerprints:
+      sha256: `+certificatetest.Fingerprint("A")+`
+`, write(t, p))
+
+	_, _, err = New(certs, []string{"CN=C"}, "")
+	assert.ErrorContains(t, err, `no certificate found with subject "CN=C"`)
+
+	p, _, err = New(nil, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, "version: \"2\"\nallow: []\n", write(t, p))
+}
+
+func TestUpdate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".paranoia.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`# Our policy.
+version: "2"
+require:
+  - comment: "Gone, but still required"
+    fingerprints:
+      sha256: `+certificatetest.Fingerprint("R")+`
+allow:
+  # Needed by the payments service.
+  - comment: "CN=A"
+    fingerprints:
+      sha256: `+certificatetest.Fingerprint("A")+`
+  - comment: "Removed from the image"
+    fingerprints:
+      sha256: `+certificatetest.Fingerprint("Old")+`
+  - comment: "Anything internal"
+    subjectRegex: "^CN=Internal"
+forbid:
+  - fingerprints:
+      sha256: `+certificatetest.Fingerprint("Bad")+` # Compromised
+`), 0o600))
+
+	p, err := Load(path)
+	require.NoError(t, err)
+	config, err := validate.LoadConfig(context.Background(), path)
+	require.NoError(t, err)
+	validator, err := validate.NewValidator(*config, false)
+	require.NoError(t, err)
+
+	changes, err := p.Update(validator, []certificate.Found{
+		certificatetest.Root("A", "/etc/ssl/certs/a.pem"),
+		certificatetest.Root("Internal CA", "/etc/ssl/certs/internal.pem"),
+		certificatetest.Root("Bad", "/etc/ssl/certs/bad.pem"),
+		certificatetest.Root("New", "/etc/ssl/certs/new.pem"),
+		certificatetest.Root("New", "/app/new.pem"),
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []validate.CertificateEntry{{
+		Comment:      "CN=New",
+		Fingerprints: validate.CertificateFingerprints{Sha256: certificatetest.Fingerprint("New")},
+	}}, changes.Allowed)
+	require.Len(t, changes.Pruned, 1)
+	assert.Equal(t, "Removed from the image", changes.Pruned[0].Comment)
+
+	require.NoError(t, p.WriteFile(path))
+	updated, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, `# Our policy.
+version: "2"
+require:
+  - comment: "Gone, but still required"
+    fingerprints:
+      sha256: `+certificatetest.Fingerprint("R")+`
+allow:
+  # Needed by the payments service.
+  - comment: "CN=A"
+    fingerprints:
+      sha256: `+certificatetest.Fingerprint("A")+`
+  - comment: "Anything internal"
+    subjectRegex: "^CN=Internal"
+  - comment: "CN=New"
+    fingerprints:
+      sha256: `+certificatetest.Fingerprint("New")+`
+forbid:
+  - fingerprints:
+      sha256: `+certificatetest.Fingerprint("Bad")+` # Compromised
+`, string(updated))
+
+	t.Run("Adds an allow list if there isn't one", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".paranoia.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("version: \"2\"\nallow:\n"), 0o600))
+		p, err := Load(path)
+		require.NoError(t, err)
+		validator, err := validate.NewValidator(validate.Config{Version: validate.ConfigVersion2}, false)
+		require.NoError(t, err)
+
+		changes, err := p.Update(validator, []certificate.Found{certificatetest.Root("A", "/a.pem")})
+		require.NoError(t, err)
+		assert.Len(t, changes.Allowed, 1)
+		assert.Equal(t, "version: \"2\"\nallow:\n  - comment: \"CN=A\"\n    fingerprints:\n      sha256: "+certificatetest.Fingerprint("A")+"\n", write(t, p))
+	})
+}
@@ -0,0 +1,71 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package scancache holds the certificates found in recently scanned images,
+// keyed by digest, for the scan server and admission webhook.
+package scancache
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// Cache holds the certificates found in the most recently used image digests.
+// Scans are cached rather than results, since validation depends on the time,
+// as exceptions and certificates expire.
+type Cache struct {
+	size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	digest string
+	parsed *certificate.ParsedCertificates
+}
+
+// New returns a cache of at most size digests. A size less than one disables
+// caching.
+func New(size int) *Cache {
+	return &Cache{
+		size:    size,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the certificates found in the digest, if cached.
+func (c *Cache) Get(digest string) (*certificate.ParsedCertificates, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[digest]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*cacheEntry).parsed, true
+}
+
+// Add caches the certificates found in the digest, evicting the least
+// recently used digest if the cache is full.
+func (c *Cache) Add(digest string, parsed *certificate.ParsedCertificates) {
+	if c.size < 1 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[digest]; ok {
+		e.Value.(*cacheEntry).parsed = parsed
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[digest] = c.order.PushFront(&cacheEntry{digest: digest, parsed: parsed})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).digest)
+	}
+}
@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package scancache
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func TestCache(t *testing.T) {
+	c := New(2)
+	a, b, d := &certificate.ParsedCertificates{}, &certificate.ParsedCertificates{}, &certificate.ParsedCertificates{}
+	c.Add("a", a)
+	c.Add("b", b)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	c.Add("d", d)
+
+	got, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Same(t, a, got)
+	_, ok = c.Get("b")
+	assert.False(t, ok, "least recently used entry should be evicted")
+	_, ok = c.Get("d")
+	assert.True(t, ok)
+}
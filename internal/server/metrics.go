@@ -0,0 +1,55 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+)
+
+type metrics struct {
+	registry *prometheus.Registry
+
+	scanDuration      *prometheus.HistogramVec
+	certificatesFound prometheus.Counter
+	cacheHits         prometheus.Counter
+	queueLength       prometheus.Gauge
+	rejected          prometheus.Counter
+}
+
+func newMetrics() *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		scanDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "paranoia_scan_duration_seconds",
+			Help:    "Time taken to scan an image, by whether the scan succeeded.",
+			Buckets: prometheus.ExponentialBuckets(0.5, 2, 10),
+		}, []string{"result"}),
+		certificatesFound: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "paranoia_certificates_found_total",
+			Help: "Number of certificates found in scanned images.",
+		}),
+		cacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "paranoia_scan_cache_hits_total",
+			Help: "Number of scans answered from the result cache.",
+		}),
+		queueLength: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "paranoia_scan_queue_length",
+			Help: "Number of scans waiting in the queue.",
+		}),
+		rejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "paranoia_scans_rejected_total",
+			Help: "Number of scans rejected because the queue was full.",
+		}),
+	}
+	m.registry.MustRegister(
+		m.scanDuration,
+		m.certificatesFound,
+		m.cacheHits,
+		m.queueLength,
+		m.rejected,
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+	)
+	return m
+}
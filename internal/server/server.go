@@ -0,0 +1,426 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package server implements an HTTP API for scanning images, queueing each
+// scan as an asynchronous job.
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/jetstack/paranoia/internal/analyse"
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/output"
+	"github.com/jetstack/paranoia/internal/scancache"
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+// Config configures the server.
+type Config struct {
+	// Analyser inspects the certificates in each image, and checks their
+	// membership of its root programmes when exporting.
+	Analyser *analyse.Analyser
+	// Validator validates the certificates in each image. If nil, results
+	// don't include validation.
+	Validator *validate.Validator
+	// Filter, if not nil, selects the certificates validated.
+	Filter func(*certificate.ParsedCertificates) *certificate.ParsedCertificates
+	// ImageOptions are used when pulling and loading images.
+	ImageOptions []image.Option
+
+	// Workers is the number of images scanned at once.
+	Workers int
+	// QueueSize is the number of scans which may wait for a worker, beyond
+	// which submissions are rejected.
+	QueueSize int
+	// CacheSize is the number of image digests whose scans are cached.
+	CacheSize int
+	// JobRetention is how long finished jobs can be polled for.
+	JobRetention time.Duration
+	// MaxUploadSize is the largest image tarball accepted, in bytes.
+	MaxUploadSize int64
+}
+
+// JobStatus is the state of a scan job.
+type JobStatus string
+
+const (
+	JobStatusQueued    JobStatus = "queued"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+)
+
+// Result is the result of scanning an image.
+type Result struct {
+	Export   output.JSONOutput      `json:"export"`
+	Inspect  output.JSONInspection  `json:"inspect"`
+	Validate *output.JSONValidation `json:"validate,omitempty"`
+}
+
+// Job is a scan of an image, as returned by the API.
+type Job struct {
+	ID       string     `json:"id"`
+	Status   JobStatus  `json:"status"`
+	Image    string     `json:"image"`
+	Digest   string     `json:"digest,omitempty"`
+	Cached   bool       `json:"cached,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+	Result   *Result    `json:"result,omitempty"`
+
+	// name is the reference scanned, which is a temporary file for uploaded
+	// tarballs, removed once the job finishes.
+	name    string
+	tarball bool
+}
+
+// Server is an HTTP API for scanning images.
+type Server struct {
+	config  Config
+	queue   chan *Job
+	cache   *scancache.Cache
+	metrics *metrics
+
+	// resolve and scan are image.Digest and image.ScanImage, replaced in tests.
+	resolve func(ctx context.Context, name string) (string, error)
+	scan    func(ctx context.Context, name string) (*image.Result, error)
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+func New(config Config) *Server {
+	return &Server{
+		config:  config,
+		queue:   make(chan *Job, config.QueueSize),
+		cache:   scancache.New(config.CacheSize),
+		metrics: newMetrics(),
+		resolve: func(ctx context.Context, name string) (string, error) {
+			return image.Digest(ctx, name, config.ImageOptions...)
+		},
+		scan: func(ctx context.Context, name string) (*image.Result, error) {
+			return image.ScanImage(ctx, name, config.ImageOptions...)
+		},
+		jobs: make(map[string]*Job),
+	}
+}
+
+// Run scans queued images until the context is cancelled. Jobs still queued
+// then are abandoned.
+func (s *Server) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < s.config.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-s.queue:
+					s.metrics.queueLength.Dec()
+					s.run(ctx, job)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for {
+		select {
+		case job := <-s.queue:
+			s.finish(job)
+		default:
+			return
+		}
+	}
+}
+
+// Handler serves the API:
+//
+//	POST /v1/scans                  submit an image reference as {"image": "..."}, or upload an image tarball
+//	GET  /v1/scans/{id}             poll a job, including its results once finished
+//	GET  /v1/scans/{id}/{operation} get the export, inspect, or validate result of a finished job
+//	GET  /metrics                   Prometheus metrics
+//	GET  /healthz                   health check
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v1/scans", s.submit)
+	mux.HandleFunc("GET /v1/scans/{id}", s.poll)
+	mux.HandleFunc("GET /v1/scans/{id}/{operation}", s.operation)
+	mux.Handle("GET /metrics", promhttp.HandlerFor(s.metrics.registry, promhttp.HandlerOpts{}))
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+type submitRequest struct {
+	Image string `json:"image"`
+}
+
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+
+	job := &Job{Status: JobStatusQueued, Created: time.Now().UTC()}
+	switch mediaType {
+	case "application/json":
+		var req submitRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, errors.Wrap(err, "invalid request"))
+			return
+		}
+		if req.Image == "" {
+			writeError(w, http.StatusBadRequest, errors.New("image is required"))
+			return
+		}
+		if err := image.CheckReference(req.Image); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		job.Image, job.name = req.Image, req.Image
+	case "application/x-tar", "application/octet-stream":
+		name, err := s.saveUpload(w, r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, errors.Wrap(err, "failed to read image tarball"))
+			return
+		}
+		job.Image, job.name, job.tarball = "upload", "file://"+name, true
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, errors.New("expected application/json, or an image tarball as application/x-tar"))
+		return
+	}
+
+	id, err := newID()
+	if err != nil {
+		s.finish(job)
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	job.ID = id
+
+	s.mu.Lock()
+	s.expire()
+	s.jobs[id] = job
+	s.mu.Unlock()
+
+	select {
+	case s.queue <- job:
+		s.metrics.queueLength.Inc()
+	default:
+		s.mu.Lock()
+		delete(s.jobs, id)
+		s.mu.Unlock()
+		s.finish(job)
+		s.metrics.rejected.Inc()
+		w.Header().Set("Retry-After", "10")
+		writeError(w, http.StatusServiceUnavailable, errors.New("scan queue is full"))
+		return
+	}
+
+	w.Header().Set("Location", "/v1/scans/"+id)
+	s.writeJob(w, http.StatusAccepted, job, false)
+}
+
+// saveUpload writes the uploaded tarball to a temporary file.
+func (s *Server) saveUpload(w http.ResponseWriter, r *http.Request) (string, error) {
+	f, err := os.CreateTemp("", "paranoia-upload-")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	body := http.MaxBytesReader(w, r.Body, s.config.MaxUploadSize)
+	if _, err := io.Copy(f, body); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
+
+func (s *Server) poll(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("scan not found"))
+		return
+	}
+	s.writeJob(w, http.StatusOK, job, true)
+}
+
+func (s *Server) operation(w http.ResponseWriter, r *http.Request) {
+	job, ok := s.job(r.PathValue("id"))
+	if !ok {
+		writeError(w, http.StatusNotFound, errors.New("scan not found"))
+		return
+	}
+
+	s.mu.Lock()
+	status, result := job.Status, job.Result
+	s.mu.Unlock()
+	if status != JobStatusSucceeded {
+		writeError(w, http.StatusConflict, errors.Errorf("scan is %s", status))
+		return
+	}
+
+	var out any
+	switch r.PathValue("operation") {
+	case "export":
+		out = result.Export
+	case "inspect":
+		out = result.Inspect
+	case "validate":
+		if result.Validate == nil {
+			writeError(w, http.StatusNotFound, errors.New("the server has no validation config"))
+			return
+		}
+		out = result.Validate
+	default:
+		writeError(w, http.StatusNotFound, errors.New("unknown operation, expected export, inspect, or validate"))
+		return
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) job(id string) (*Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[id]
+	return job, ok
+}
+
+// run scans the image of a job, unless the result for its digest is cached.
+func (s *Server) run(ctx context.Context, job *Job) {
+	defer s.finish(job)
+
+	s.mu.Lock()
+	job.Status = JobStatusRunning
+	s.mu.Unlock()
+
+	result, digest, cached, err := s.result(ctx, job.name)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	job.Finished = &now
+	job.Digest = digest
+	job.Cached = cached
+	if err != nil {
+		job.Status = JobStatusFailed
+		job.Error = err.Error()
+		return
+	}
+	job.Status = JobStatusSucceeded
+	job.Result = result
+}
+
+// result returns the result of scanning the image, the digest it resolved to,
+// and whether the scan was cached. Cached scans are validated again, since
+// the verdict may have changed.
+func (s *Server) result(ctx context.Context, name string) (*Result, string, bool, error) {
+	digest, err := s.resolve(ctx, name)
+	if err != nil {
+		return nil, "", false, err
+	}
+
+	parsed, cached := s.cache.Get(digest)
+	if cached {
+		s.metrics.cacheHits.Inc()
+	} else {
+		// Scan the digest resolved, in case the tag has moved since.
+		pinned, err := image.Pin(name, digest)
+		if err != nil {
+			return nil, digest, false, err
+		}
+		start := time.Now()
+		res, err := s.scan(ctx, pinned)
+		if err != nil {
+			s.metrics.scanDuration.WithLabelValues("failure").Observe(time.Since(start).Seconds())
+			return nil, digest, false, err
+		}
+		s.metrics.scanDuration.WithLabelValues("success").Observe(time.Since(start).Seconds())
+		s.metrics.certificatesFound.Add(float64(len(res.Parsed.Found)))
+		parsed = res.Parsed
+		s.cache.Add(digest, parsed)
+	}
+
+	result := &Result{
+		Export:  output.NewJSONOutput(parsed, s.config.Analyser.RootProgrammes, false),
+		Inspect: output.NewJSONInspection(s.config.Analyser, parsed),
+	}
+	if s.config.Validator != nil {
+		if s.config.Filter != nil {
+			parsed = s.config.Filter(parsed)
+		}
+		validation, err := s.config.Validator.ValidateParsed(parsed)
+		if err != nil {
+			return nil, digest, cached, errors.Wrap(err, "failed to validate certificates")
+		}
+		out := output.NewJSONValidation(validation)
+		result.Validate = &out
+	}
+	return result, digest, cached, nil
+}
+
+// finish removes the uploaded tarball of a job, if any.
+func (s *Server) finish(job *Job) {
+	if job.tarball {
+		os.Remove(strings.TrimPrefix(job.name, "file://"))
+	}
+}
+
+// expire forgets jobs which finished longer ago than the retention period. It
+// must be called with the lock held.
+func (s *Server) expire() {
+	cutoff := time.Now().Add(-s.config.JobRetention)
+	for id, job := range s.jobs {
+		if job.Finished != nil && job.Finished.Before(cutoff) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+func (s *Server) writeJob(w http.ResponseWriter, status int, job *Job, includeResult bool) {
+	s.mu.Lock()
+	out := *job
+	s.mu.Unlock()
+	if !includeResult {
+		out.Result = nil
+	}
+	writeJSON(w, status, out)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, struct {
+		Error string `json:"error"`
+	}{err.Error()})
+}
+
+func newID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate job ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,257 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/analyse"
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/certificate/certificatetest"
+	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+// fakeImages are the certificates of images by digest, and the digest of each
+// image name.
+type fakeImages struct {
+	digests map[string]string
+	found   map[string][]certificate.Found
+
+	mu      sync.Mutex
+	scans   int
+	uploads map[string]string
+}
+
+func newTestServer(t *testing.T, images *fakeImages, config Config) *Server {
+	forbidden := hex.EncodeToString(images.found["sha256:bad"][0].FingerprintSha256[:])
+	validator, err := validate.NewValidator(validate.Config{
+		Version: "1",
+		Forbid: []validate.CertificateEntry{
+			{Fingerprints: validate.CertificateFingerprints{Sha256: forbidden}, Comment: "internal"},
+		},
+	}, true)
+	require.NoError(t, err)
+
+	images.uploads = make(map[string]string)
+	config.Analyser = &analyse.Analyser{}
+	config.Validator = validator
+	if config.JobRetention == 0 {
+		config.JobRetention = time.Hour
+	}
+	config.MaxUploadSize = 1024
+
+	s := New(config)
+	s.resolve = func(_ context.Context, name string) (string, error) {
+		if path, ok := strings.CutPrefix(name, "file://"); ok {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return "", err
+			}
+			images.mu.Lock()
+			images.uploads[path] = string(data)
+			images.mu.Unlock()
+			return "sha256:" + string(data), nil
+		}
+		digest, ok := images.digests[name]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return digest, nil
+	}
+	s.scan = func(_ context.Context, name string) (*image.Result, error) {
+		// References are scanned by the digest they were resolved to.
+		_, digest, ok := strings.Cut(name, "@")
+		if !ok {
+			if !strings.HasPrefix(name, "file://") {
+				return nil, errors.New("image is not pinned to a digest")
+			}
+			digest, _ = s.resolve(context.Background(), name)
+		}
+		images.mu.Lock()
+		images.scans++
+		images.mu.Unlock()
+		return &image.Result{
+			Name:   name,
+			Digest: digest,
+			Parsed: &certificate.ParsedCertificates{Found: images.found[digest]},
+		}, nil
+	}
+	return s
+}
+
+func submit(t *testing.T, url, contentType, body string) (*http.Response, Job) {
+	resp, err := http.Post(url+"/v1/scans", contentType, strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	var job Job
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+	return resp, job
+}
+
+// wait polls the job until it has finished.
+func wait(t *testing.T, url, id string) Job {
+	var job Job
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(url + "/v1/scans/" + id)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		job = Job{}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&job))
+		return job.Status == JobStatusSucceeded || job.Status == JobStatusFailed
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+func get(t *testing.T, url string) (int, string) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp.StatusCode, string(body)
+}
+
+func TestServer(t *testing.T) {
+	images := &fakeImages{
+		digests: map[string]string{
+			"good:1":     "sha256:good",
+			"good:alias": "sha256:good",
+			"bad:1":      "sha256:bad",
+		},
+		found: map[string][]certificate.Found{
+			"sha256:good": {certificatetest.Root("Good Root", "/etc/ssl/certs/good.pem")},
+			"sha256:bad":  {certificatetest.Root("Internal Root", "/etc/ssl/certs/internal.pem")},
+		},
+	}
+	s := newTestServer(t, images, Config{Workers: 2, QueueSize: 10, CacheSize: 10})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Run(ctx)
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	t.Run("scans an image reference", func(t *testing.T) {
+		resp, job := submit(t, srv.URL, "application/json", `{"image": "good:1"}`)
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		assert.Equal(t, "/v1/scans/"+job.ID, resp.Header.Get("Location"))
+		assert.Equal(t, "good:1", job.Image)
+
+		job = wait(t, srv.URL, job.ID)
+		assert.Equal(t, JobStatusSucceeded, job.Status)
+		assert.Equal(t, "sha256:good", job.Digest)
+		require.NotNil(t, job.Result)
+		require.Len(t, job.Result.Export.Certificates, 1)
+		assert.Equal(t, "/etc/ssl/certs/good.pem", job.Result.Export.Certificates[0].FileLocation)
+		require.NotNil(t, job.Result.Validate)
+		assert.True(t, job.Result.Validate.Pass)
+	})
+
+	t.Run("caches scans by digest", func(t *testing.T) {
+		_, job := submit(t, srv.URL, "application/json", `{"image": "good:alias"}`)
+		job = wait(t, srv.URL, job.ID)
+		assert.Equal(t, JobStatusSucceeded, job.Status)
+		assert.True(t, job.Cached)
+		assert.Equal(t, 1, images.scans)
+	})
+
+	t.Run("returns each operation's result", func(t *testing.T) {
+		_, job := submit(t, srv.URL, "application/json", `{"image": "bad:1"}`)
+		wait(t, srv.URL, job.ID)
+
+		status, body := get(t, srv.URL+"/v1/scans/"+job.ID+"/validate")
+		assert.Equal(t, http.StatusOK, status)
+		assert.JSONEq(t, `{"pass": false, "forbiddenCertificates": [{
+			"fileLocation": "/etc/ssl/certs/internal.pem",
+			"fingerprintSHA256": "`+hex.EncodeToString(images.found["sha256:bad"][0].FingerprintSha256[:])+`",
+			"comment": "internal"
+		}]}`, body)
+
+		status, _ = get(t, srv.URL+"/v1/scans/"+job.ID+"/export")
+		assert.Equal(t, http.StatusOK, status)
+		status, _ = get(t, srv.URL+"/v1/scans/"+job.ID+"/inspect")
+		assert.Equal(t, http.StatusOK, status)
+		status, _ = get(t, srv.URL+"/v1/scans/"+job.ID+"/unknown")
+		assert.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("scans an uploaded tarball and removes it", func(t *testing.T) {
+		resp, job := submit(t, srv.URL, "application/x-tar", "tarball")
+		assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+		job = wait(t, srv.URL, job.ID)
+		assert.Equal(t, JobStatusSucceeded, job.Status)
+		assert.Equal(t, "sha256:tarball", job.Digest)
+		require.Len(t, images.uploads, 1)
+		for path, data := range images.uploads {
+			assert.Equal(t, "tarball", data)
+			assert.NoFileExists(t, path)
+		}
+	})
+
+	t.Run("reports failed scans", func(t *testing.T) {
+		_, job := submit(t, srv.URL, "application/json", `{"image": "missing:1"}`)
+		job = wait(t, srv.URL, job.ID)
+		assert.Equal(t, JobStatusFailed, job.Status)
+		assert.Equal(t, "not found", job.Error)
+
+		status, _ := get(t, srv.URL+"/v1/scans/"+job.ID+"/export")
+		assert.Equal(t, http.StatusConflict, status)
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		resp, _ := submit(t, srv.URL, "application/json", `{}`)
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		for _, image := range []string{"file:///tmp/paranoia-upload-123", "-", "Not A Reference"} {
+			resp, _ = submit(t, srv.URL, "application/json", `{"image": "`+image+`"}`)
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode, image)
+		}
+		resp, _ = submit(t, srv.URL, "text/plain", `good:1`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+		resp, _ = submit(t, srv.URL, "application/x-tar", strings.Repeat("x", 2048))
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		status, _ := get(t, srv.URL+"/v1/scans/unknown")
+		assert.Equal(t, http.StatusNotFound, status)
+	})
+
+	t.Run("exposes metrics", func(t *testing.T) {
+		status, body := get(t, srv.URL+"/metrics")
+		assert.Equal(t, http.StatusOK, status)
+		assert.Contains(t, body, "paranoia_certificates_found_total 2")
+		assert.Contains(t, body, "paranoia_scan_cache_hits_total 1")
+		assert.Contains(t, body, `paranoia_scan_duration_seconds_count{result="success"} 3`)
+	})
+}
+
+func TestServerQueueFull(t *testing.T) {
+	images := &fakeImages{
+		digests: map[string]string{"bad:1": "sha256:bad"},
+		found:   map[string][]certificate.Found{"sha256:bad": {certificatetest.Root("Internal Root", "/internal.pem")}},
+	}
+	// Without running the server, nothing is taken from the queue.
+	s := newTestServer(t, images, Config{Workers: 1, QueueSize: 1})
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	resp, _ := submit(t, srv.URL, "application/json", `{"image": "bad:1"}`)
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+
+	resp, _ = submit(t, srv.URL, "application/x-tar", "tarball")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	assert.Equal(t, "10", resp.Header.Get("Retry-After"))
+}
@@ -0,0 +1,111 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package truststore
+
+import (
+	"archive/tar"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// trustVariables are the environment variables which configure the
+// certificates trusted by common TLS libraries and runtimes. Each maps to a
+// function which extracts the paths from the variable's value.
+var trustVariables = map[string]func(string) []string{
+	// OpenSSL, and Go's crypto/x509.
+	"SSL_CERT_FILE": single,
+	// OpenSSL and Go both accept a colon separated list of directories.
+	"SSL_CERT_DIR": func(v string) []string { return strings.Split(v, ":") },
+	// Node.js.
+	"NODE_EXTRA_CA_CERTS": single,
+	// Python requests.
+	"REQUESTS_CA_BUNDLE": single,
+	// curl.
+	"CURL_CA_BUNDLE": single,
+	// Java, though trust stores are usually in a keystore format which isn't
+	// scanned for certificates.
+	"JAVA_TOOL_OPTIONS": javaTrustStores,
+}
+
+func single(v string) []string {
+	return []string{v}
+}
+
+var javaTrustStoreRegexp = regexp.MustCompile(`-Djavax\.net\.ssl\.trustStore=("[^"]*"|'[^']*'|\S+)`)
+
+func javaTrustStores(v string) []string {
+	var paths []string
+	for _, m := range javaTrustStoreRegexp.FindAllStringSubmatch(v, -1) {
+		paths = append(paths, strings.Trim(m[1], `"'`))
+	}
+	return paths
+}
+
+// ApplyEnvironment resolves the trust related variables in the given image
+// environment, of the form KEY=value, to paths within the image. Certificates
+// reachable through each variable are marked, and the variables are recorded
+// on the parsed certificates. Relative paths are resolved against the working
+// directory.
+func ApplyEnvironment(parsed *certificate.ParsedCertificates, env []string, workingDir string) {
+	if workingDir == "" {
+		workingDir = "/"
+	}
+
+	for _, kv := range env {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+		paths, ok := trustVariables[name]
+		if !ok {
+			continue
+		}
+
+		for _, p := range paths(value) {
+			if p == "" {
+				continue
+			}
+			v := certificate.TrustVariable{Name: name, Path: p}
+			if !path.IsAbs(p) {
+				p = path.Join(workingDir, p)
+			}
+			v.Resolved, v.Exists = parsed.Resolve(p)
+			parsed.TrustVariables = append(parsed.TrustVariables, v)
+
+			if v.Exists {
+				markReachable(parsed, name, v.Resolved)
+			}
+		}
+	}
+}
+
+// markReachable marks the certificates in the given file, or in the given
+// directory, as reachable through the variable. Certificates in a directory
+// are also reachable through symbolic links within it, as used by OpenSSL's
+// hashed certificate directories.
+func markReachable(parsed *certificate.ParsedCertificates, name, resolved string) {
+	reachable := map[string]bool{resolved: true}
+	if f, ok := parsed.Files[resolved]; !ok || f.Type != tar.TypeReg {
+		for file, f := range parsed.Files {
+			if path.Dir(file) != resolved {
+				continue
+			}
+			reachable[file] = true
+			if f.Type == tar.TypeSymlink {
+				if target, ok := parsed.Resolve(file); ok {
+					reachable[target] = true
+				}
+			}
+		}
+	}
+
+	for i, found := range parsed.Found {
+		if reachable[found.Location] && !slices.Contains(found.TrustVariables, name) {
+			parsed.Found[i].TrustVariables = append(parsed.Found[i].TrustVariables, name)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package truststore
+
+import (
+	"archive/tar"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func TestApplyEnvironment(t *testing.T) {
+	parsed := &certificate.ParsedCertificates{
+		Files: map[string]certificate.File{
+			"/app/ca.pem":                  {Type: tar.TypeReg},
+			"/app/certs/extra.pem":         {Type: tar.TypeReg},
+			"/app/certs/1234abcd.0":        {Type: tar.TypeSymlink, Linkname: "/usr/share/custom/ca.crt"},
+			"/usr/share/custom/ca.crt":     {Type: tar.TypeReg},
+			"/opt/java/cacerts":            {Type: tar.TypeReg},
+			"/etc/ssl/certs/bundle.crt":    {Type: tar.TypeReg},
+			"/etc/ssl/certs/not-reached.0": {Type: tar.TypeReg},
+		},
+		Found: []certificate.Found{
+			{Location: "/app/ca.pem"},
+			{Location: "/app/certs/extra.pem"},
+			{Location: "/usr/share/custom/ca.crt"},
+			{Location: "/etc/ssl/certs/bundle.crt"},
+		},
+	}
+
+	ApplyEnvironment(parsed, []string{
+		"PATH=/usr/bin",
+		"SSL_CERT_FILE=ca.pem",
+		"NODE_EXTRA_CA_CERTS=/app/ca.pem",
+		"SSL_CERT_DIR=/app/certs:/missing/dir",
+		"CURL_CA_BUNDLE=/etc/ssl/certs/bundle.crt",
+		`JAVA_TOOL_OPTIONS=-Xmx1g -Djavax.net.ssl.trustStore="/opt/java/cacerts" -Djavax.net.ssl.trustStorePassword=changeit`,
+		"REQUESTS_CA_BUNDLE=",
+	}, "/app")
+
+	assert.Equal(t, []certificate.TrustVariable{
+		{Name: "SSL_CERT_FILE", Path: "ca.pem", Resolved: "/app/ca.pem", Exists: true},
+		{Name: "NODE_EXTRA_CA_CERTS", Path: "/app/ca.pem", Resolved: "/app/ca.pem", Exists: true},
+		{Name: "SSL_CERT_DIR", Path: "/app/certs", Resolved: "/app/certs", Exists: true},
+		{Name: "SSL_CERT_DIR", Path: "/missing/dir", Resolved: "/missing/dir", Exists: false},
+		{Name: "CURL_CA_BUNDLE", Path: "/etc/ssl/certs/bundle.crt", Resolved: "/etc/ssl/certs/bundle.crt", Exists: true},
+		{Name: "JAVA_TOOL_OPTIONS", Path: "/opt/java/cacerts", Resolved: "/opt/java/cacerts", Exists: true},
+	}, parsed.TrustVariables)
+
+	var reachable [][]string
+	for _, found := range parsed.Found {
+		reachable = append(reachable, found.TrustVariables)
+	}
+	assert.Equal(t, [][]string{
+		{"SSL_CERT_FILE", "NODE_EXTRA_CA_CERTS"},
+		{"SSL_CERT_DIR"},
+		{"SSL_CERT_DIR"},
+		{"CURL_CA_BUNDLE"},
+	}, reachable)
+}
@@ -0,0 +1,270 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package truststore models the trust store of the operating system in a
+// container image, to determine which of the certificates found are actually
+// trusted. It understands the layout used by update-ca-certificates on Debian,
+// Ubuntu and Alpine, and by p11-kit on Red Hat derived and SUSE distributions.
+package truststore
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/pem"
+	"path"
+	"strings"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+const (
+	// caCertificatesConf lists the certificates in caCertificatesDir used by
+	// update-ca-certificates. Entries prefixed with "!" are disabled.
+	caCertificatesConf = "/etc/ca-certificates.conf"
+	caCertificatesDir  = "/usr/share/ca-certificates/"
+	// localCertificatesDir contains local certificates, which
+	// update-ca-certificates always adds if they have a .crt extension.
+	localCertificatesDir = "/usr/local/share/ca-certificates/"
+	// certsDir is the directory OpenSSL searches for certificates by hash.
+	certsDir = "/etc/ssl/certs/"
+)
+
+// bundles are the files generated from the trust store configuration, which
+// are read by applications. Certificates in these are trusted as-is, since the
+// bundle is what applications actually use.
+var bundles = []string{
+	"/etc/ssl/certs/ca-certificates.crt",
+	"/etc/ssl/cert.pem",
+	"/etc/ssl/ca-bundle.pem",
+	"/etc/pki/tls/certs/ca-bundle.crt",
+	"/etc/pki/tls/cert.pem",
+	"/var/lib/ca-certificates/ca-bundle.pem",
+}
+
+// generatedDirs are directories containing files generated from the trust
+// store configuration.
+var generatedDirs = []string{
+	certsDir,
+	"/etc/pki/ca-trust/extracted/",
+	"/var/lib/ca-certificates/pem/",
+	"/var/lib/ca-certificates/openssl/",
+}
+
+// anchorDirs are p11-kit source directories of trusted certificate
+// authorities.
+var anchorDirs = []string{
+	"/etc/pki/ca-trust/source/anchors/",
+	"/usr/share/pki/ca-trust-source/anchors/",
+	"/etc/pki/trust/anchors/",
+	"/usr/share/pki/trust/anchors/",
+}
+
+// blocklistDirs are p11-kit source directories of distrusted certificates.
+var blocklistDirs = []string{
+	"/etc/pki/ca-trust/source/blocklist/",
+	"/etc/pki/ca-trust/source/blacklist/",
+	"/usr/share/pki/ca-trust-source/blocklist/",
+	"/usr/share/pki/ca-trust-source/blacklist/",
+	"/etc/pki/trust/blacklist/",
+	"/usr/share/pki/trust/blacklist/",
+}
+
+// p11KitDirs are p11-kit source directories containing .p11-kit files, which
+// carry explicit trust attributes for each certificate.
+var p11KitDirs = []string{
+	"/etc/pki/ca-trust/source/",
+	"/usr/share/pki/ca-trust-source/",
+}
+
+// store is the trust store configuration of an image.
+type store struct {
+	parsed *certificate.ParsedCertificates
+	// enabled and disabled are the entries of ca-certificates.conf, as paths
+	// relative to caCertificatesDir.
+	enabled, disabled map[string]bool
+	// linked is the set of files which are the targets of symbolic links in
+	// certsDir.
+	linked map[string]bool
+	// p11Kit is the trust of certificates in .p11-kit files, keyed by file and
+	// then by SHA-256 fingerprint.
+	p11Kit map[string]map[[32]byte]certificate.Trust
+	// blocklisted is the set of SHA-256 fingerprints of certificates which
+	// p11-kit distrusts.
+	blocklisted map[[32]byte]bool
+}
+
+// Apply sets the effective trust of each certificate found in the image.
+func Apply(parsed *certificate.ParsedCertificates) {
+	s := newStore(parsed)
+	for i := range parsed.Found {
+		parsed.Found[i].Trust = s.trust(parsed.Found[i])
+	}
+}
+
+func newStore(parsed *certificate.ParsedCertificates) *store {
+	s := &store{
+		parsed:      parsed,
+		enabled:     make(map[string]bool),
+		disabled:    make(map[string]bool),
+		linked:      make(map[string]bool),
+		p11Kit:      make(map[string]map[[32]byte]certificate.Trust),
+		blocklisted: make(map[[32]byte]bool),
+	}
+
+	if f, ok := parsed.Files[caCertificatesConf]; ok {
+		s.enabled, s.disabled = parseCACertificatesConf(f.Contents)
+	}
+
+	for name, f := range parsed.Files {
+		switch {
+		case f.Type == tar.TypeSymlink && strings.HasPrefix(name, certsDir):
+			if target, ok := parsed.Resolve(name); ok {
+				s.linked[target] = true
+			}
+		case f.Contents != nil && strings.HasSuffix(name, ".p11-kit") && hasPrefix(name, p11KitDirs):
+			s.p11Kit[name] = parseP11Kit(f.Contents)
+			for fingerprint, trust := range s.p11Kit[name] {
+				if trust == certificate.TrustDistrusted {
+					s.blocklisted[fingerprint] = true
+				}
+			}
+		}
+	}
+
+	for _, found := range parsed.Found {
+		if hasPrefix(found.Location, blocklistDirs) {
+			s.blocklisted[found.FingerprintSha256] = true
+		}
+	}
+
+	return s
+}
+
+// trust returns the effective trust of the found certificate.
+func (s *store) trust(found certificate.Found) certificate.Trust {
+	location := found.Location
+	for _, bundle := range bundles {
+		if location == bundle {
+			return certificate.TrustTrusted
+		}
+	}
+	if hasPrefix(location, generatedDirs) {
+		return certificate.TrustTrusted
+	}
+
+	var trust certificate.Trust
+	switch {
+	case hasPrefix(location, blocklistDirs):
+		trust = certificate.TrustDistrusted
+	case hasPrefix(location, anchorDirs):
+		trust = certificate.TrustTrusted
+	case s.p11Kit[location] != nil:
+		trust = s.p11Kit[location][found.FingerprintSha256]
+	case strings.HasPrefix(location, caCertificatesDir):
+		entry := strings.TrimPrefix(location, caCertificatesDir)
+		if s.disabled[entry] {
+			return certificate.TrustDistrusted
+		}
+		if s.enabled[entry] {
+			trust = certificate.TrustTrusted
+		}
+	case strings.HasPrefix(location, localCertificatesDir) && strings.HasSuffix(location, ".crt"):
+		trust = certificate.TrustTrusted
+	}
+
+	// Certificates linked into the hashed certificate directory are found by
+	// OpenSSL, whether or not the configuration lists them.
+	if trust == "" && s.linked[location] {
+		trust = certificate.TrustTrusted
+	}
+
+	// In p11-kit, blocklists take precedence over anchors.
+	if trust == certificate.TrustTrusted && s.blocklisted[found.FingerprintSha256] {
+		return certificate.TrustDistrusted
+	}
+
+	if trust == "" {
+		return certificate.TrustUntrustedFile
+	}
+	return trust
+}
+
+// parseCACertificatesConf parses the ca-certificates.conf file used by
+// update-ca-certificates, returning the enabled and disabled entries.
+func parseCACertificatesConf(b []byte) (enabled, disabled map[string]bool) {
+	enabled, disabled = make(map[string]bool), make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "", strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "!"):
+			disabled[path.Clean(strings.TrimPrefix(line, "!"))] = true
+		default:
+			enabled[path.Clean(line)] = true
+		}
+	}
+	return enabled, disabled
+}
+
+// parseP11Kit parses a p11-kit persistent object file, returning the trust of
+// each certificate object, keyed by SHA-256 fingerprint. Certificates which are
+// neither trusted nor distrusted are untrusted files.
+func parseP11Kit(b []byte) map[[32]byte]certificate.Trust {
+	certs := make(map[[32]byte]certificate.Trust)
+
+	var (
+		attrs = make(map[string]string)
+		body  bytes.Buffer
+	)
+	flush := func() {
+		rest := body.Bytes()
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			trust := certificate.TrustUntrustedFile
+			switch {
+			case attrs["x-distrusted"] == "true":
+				trust = certificate.TrustDistrusted
+			case attrs["trusted"] == "true":
+				trust = certificate.TrustTrusted
+			}
+			certs[sha256.Sum256(block.Bytes)] = trust
+		}
+		attrs = make(map[string]string)
+		body.Reset()
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(strings.TrimSpace(line), "]") {
+			flush()
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok && !strings.HasPrefix(line, "-----") {
+			attrs[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+		body.WriteString(line)
+		body.WriteByte('\n')
+	}
+	flush()
+
+	return certs
+}
+
+func hasPrefix(s string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(s, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package truststore
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/pem"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func TestApply(t *testing.T) {
+	fingerprint := func(s string) [32]byte { return sha256.Sum256([]byte(s)) }
+	p11Kit := "# comment\n" +
+		"[p11-kit-object-v1]\nclass: certificate\ntrusted: true\n" +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("p11-trusted")})) +
+		"\n[p11-kit-object-v1]\nclass: certificate\nx-distrusted: true\n" +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("p11-distrusted")})) +
+		"\n[p11-kit-object-v1]\nclass: certificate\n" +
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("p11-other")}))
+
+	tests := map[string]struct {
+		files    map[string]certificate.File
+		found    []certificate.Found
+		expTrust []certificate.Trust
+	}{
+		"bundles and hashed directory are trusted": {
+			found: []certificate.Found{
+				{Location: "/etc/ssl/certs/ca-certificates.crt"},
+				{Location: "/etc/ssl/certs/custom.pem"},
+				{Location: "/etc/pki/ca-trust/extracted/pem/tls-ca-bundle.pem"},
+				{Location: "/etc/ssl/cert.pem"},
+			},
+			expTrust: []certificate.Trust{
+				certificate.TrustTrusted,
+				certificate.TrustTrusted,
+				certificate.TrustTrusted,
+				certificate.TrustTrusted,
+			},
+		},
+		"stray certificate files are untrusted": {
+			found: []certificate.Found{
+				{Location: "/app/ca.pem"},
+				{Location: "/usr/local/share/ca-certificates/not-a-crt.pem"},
+			},
+			expTrust: []certificate.Trust{
+				certificate.TrustUntrustedFile,
+				certificate.TrustUntrustedFile,
+			},
+		},
+		"ca-certificates.conf enables and disables certificates": {
+			files: map[string]certificate.File{
+				"/etc/ca-certificates.conf": {Type: tar.TypeReg, Contents: []byte("# comment\nmozilla/A.crt\n!mozilla/B.crt\n")},
+			},
+			found: []certificate.Found{
+				{Location: "/usr/share/ca-certificates/mozilla/A.crt"},
+				{Location: "/usr/share/ca-certificates/mozilla/B.crt"},
+				{Location: "/usr/share/ca-certificates/mozilla/C.crt"},
+				{Location: "/usr/local/share/ca-certificates/local.crt"},
+			},
+			expTrust: []certificate.Trust{
+				certificate.TrustTrusted,
+				certificate.TrustDistrusted,
+				certificate.TrustUntrustedFile,
+				certificate.TrustTrusted,
+			},
+		},
+		"certificates linked from the hashed directory are trusted": {
+			files: map[string]certificate.File{
+				"/etc/ssl/certs/C.pem":                     {Type: tar.TypeSymlink, Linkname: "/usr/share/ca-certificates/mozilla/C.crt"},
+				"/etc/ssl/certs/1234abcd.0":                {Type: tar.TypeSymlink, Linkname: "C.pem"},
+				"/usr/share/ca-certificates/mozilla/C.crt": {Type: tar.TypeReg},
+			},
+			found: []certificate.Found{
+				{Location: "/usr/share/ca-certificates/mozilla/C.crt"},
+			},
+			expTrust: []certificate.Trust{
+				certificate.TrustTrusted,
+			},
+		},
+		"p11-kit anchors, blocklists and trust attributes": {
+			files: map[string]certificate.File{
+				"/usr/share/pki/ca-trust-source/ca-bundle.trust.p11-kit": {Type: tar.TypeReg, Contents: []byte(p11Kit)},
+			},
+			found: []certificate.Found{
+				{Location: "/etc/pki/ca-trust/source/anchors/a.pem", FingerprintSha256: fingerprint("anchor")},
+				{Location: "/etc/pki/ca-trust/source/anchors/b.pem", FingerprintSha256: fingerprint("blocked")},
+				{Location: "/etc/pki/ca-trust/source/blocklist/b.pem", FingerprintSha256: fingerprint("blocked")},
+				{Location: "/usr/share/pki/ca-trust-source/ca-bundle.trust.p11-kit", FingerprintSha256: fingerprint("p11-trusted")},
+				{Location: "/usr/share/pki/ca-trust-source/ca-bundle.trust.p11-kit", FingerprintSha256: fingerprint("p11-distrusted")},
+				{Location: "/usr/share/pki/ca-trust-source/ca-bundle.trust.p11-kit", FingerprintSha256: fingerprint("p11-other")},
+				{Location: "/etc/pki/ca-trust/source/anchors/c.pem", FingerprintSha256: fingerprint("p11-distrusted")},
+			},
+			expTrust: []certificate.Trust{
+				certificate.TrustTrusted,
+				certificate.TrustDistrusted,
+				certificate.TrustDistrusted,
+				certificate.TrustTrusted,
+				certificate.TrustDistrusted,
+				certificate.TrustUntrustedFile,
+				certificate.TrustDistrusted,
+			},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			parsed := &certificate.ParsedCertificates{Files: test.files, Found: test.found}
+			Apply(parsed)
+
+			var trust []certificate.Trust
+			for _, found := range parsed.Found {
+				trust = append(trust, found.Trust)
+			}
+			assert.Equal(t, test.expTrust, trust)
+		})
+	}
+}
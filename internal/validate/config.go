@@ -3,26 +3,91 @@
 package validate
 
 import (
-	"errors"
+	"context"
 	"fmt"
-	"io/ioutil"
 	"os"
+	"path/filepath"
+)
 
-	"gopkg.in/yaml.v3"
+const (
+	// ConfigVersion1 configs match certificates only by their fingerprint.
+	ConfigVersion1 = "1"
+	// ConfigVersion2 configs may also match certificates by their public key,
+	// subject, issuer, serial number, and key ID.
+	ConfigVersion2 = "2"
 )
 
-var ExpectedVersion = "1"
+// SupportedVersions are the config versions which can be loaded.
+var SupportedVersions = []string{ConfigVersion1, ConfigVersion2}
 
 type Config struct {
 	Version string             `json:"version"`
 	Allow   []CertificateEntry `json:"allow,omitempty"`
 	Forbid  []CertificateEntry `json:"forbid,omitempty"`
 	Require []CertificateEntry `json:"require,omitempty"`
+	// ForbidPrivateKeys fails validation if any private key is found in the
+	// image.
+	ForbidPrivateKeys bool `json:"forbidPrivateKeys,omitempty" yaml:"forbidPrivateKeys,omitempty"`
+	// PathRules restrict which certificates may be found under a path. Only
+	// supported from config version 2.
+	PathRules []PathRule `json:"pathRules,omitempty" yaml:"pathRules,omitempty"`
+	// Rules check the properties of every certificate found. Only supported
+	// from config version 2.
+	Rules Rules `json:"rules,omitempty" yaml:"rules,omitempty"`
+	// Exceptions waive issues until they expire. Only supported from config
+	// version 2.
+	Exceptions []Exception `json:"exceptions,omitempty" yaml:"exceptions,omitempty"`
+	// Include are other configs to merge into this one, each of which this
+	// config takes precedence over. Only supported from config version 2.
+	Include []Include `json:"include,omitempty" yaml:"include,omitempty"`
+
+	// Sources are the configs which were loaded to make up this one, in order
+	// of increasing precedence.
+	Sources []PolicySource `json:"-" yaml:"-"`
+}
+
+// PathRule permits only the certificates matching its allow list to be found
+// in locations matching its path, regardless of permissive mode.
+type PathRule struct {
+	// Path is an absolute glob, as understood by path.Match, where "**"
+	// also matches any number of directories.
+	Path    string             `json:"path"`
+	Comment string             `json:"comment,omitempty"`
+	Allow   []CertificateEntry `json:"allow,omitempty"`
 }
 
+// CertificateEntry identifies certificates in a config list. A certificate
+// matches the entry if it matches every matcher the entry sets.
 type CertificateEntry struct {
 	Fingerprints CertificateFingerprints `json:"fingerprints"`
 	Comment      string                  `json:"comment,omitempty"`
+
+	// The following matchers are only supported from config version 2.
+
+	// SPKISHA256 is the hex encoded SHA-256 hash of the certificate's DER
+	// encoded public key, which stays the same when a certificate authority
+	// re-issues its certificate with the same key.
+	SPKISHA256 string `json:"spkiSHA256,omitempty" yaml:"spkiSHA256,omitempty"`
+	// Subject and Issuer are distinguished names, in the form
+	// "CN=Example Root,O=Example,C=GB", matched exactly.
+	Subject string `json:"subject,omitempty" yaml:"subject,omitempty"`
+	Issuer  string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	// SubjectRegex and IssuerRegex are regular expressions matched against
+	// the distinguished names.
+	SubjectRegex string `json:"subjectRegex,omitempty" yaml:"subjectRegex,omitempty"`
+	IssuerRegex  string `json:"issuerRegex,omitempty" yaml:"issuerRegex,omitempty"`
+	// Serial is the hex encoded serial number of the certificate, optionally
+	// separated by colons.
+	Serial string `json:"serial,omitempty" yaml:"serial,omitempty"`
+	// KeyID is the hex encoded subject key identifier of the certificate,
+	// optionally separated by colons.
+	KeyID string `json:"keyID,omitempty" yaml:"keyID,omitempty"`
+
+	// Paths restricts the entry to certificates found in locations matching
+	// one of these globs, in the same form as PathRule.Path. Certificates
+	// matching an allow or require entry elsewhere are misplaced, and
+	// forbid entries only forbid certificates in these locations.
+	Paths []string `json:"paths,omitempty" yaml:"paths,omitempty"`
 }
 
 type CertificateFingerprints struct {
@@ -30,23 +95,22 @@ type CertificateFingerprints struct {
 	Sha256 string `json:"sha256,omitempty"`
 }
 
-func LoadConfig(fileName string) (*Config, error) {
-	b, err := ioutil.ReadFile(fileName)
+// LoadConfig loads the config from a file, merging in any configs it
+// includes.
+func LoadConfig(ctx context.Context, fileName string, opts ...LoadOption) (*Config, error) {
+	b, err := os.ReadFile(fileName)
 	if err != nil {
 		return nil, err
 	}
-	var contents map[string]interface{}
-	err = yaml.Unmarshal(b, &contents)
+	l := loader{ctx: ctx}
+	for _, opt := range opts {
+		opt(&l.opts)
+	}
+	key, err := filepath.Abs(fileName)
 	if err != nil {
 		return nil, err
 	}
-	if contents["version"].(string) != ExpectedVersion {
-		return nil, errors.New("Unsupported config version, expected " + ExpectedVersion + ", found" + contents["version"].(string))
-	}
-
-	var c Config
-	err = yaml.Unmarshal(b, &c)
-	return &c, err
+	return l.load(PolicySource{Location: fileName}, key, b, filepath.Dir(fileName), false)
 }
 
 func stderr(s string) {
@@ -58,10 +122,12 @@ func stderr(s string) {
 
 func IsConfigValid(config *Config) bool {
 	isValid := true
-	for _, list := range []struct {
-		list []CertificateEntry
-		name string
-	}{
+	type entryList struct {
+		list     []CertificateEntry
+		name     string
+		pathRule bool
+	}
+	lists := []entryList{
 		{
 			list: config.Allow,
 			name: "allow",
@@ -74,17 +140,88 @@ func IsConfigValid(config *Config) bool {
 			list: config.Require,
 			name: "require",
 		},
-	} {
+	}
+	for i, rule := range config.PathRules {
+		lists = append(lists, entryList{
+			list:     rule.Allow,
+			name:     fmt.Sprintf("path rule %d allow", i),
+			pathRule: true,
+		})
+	}
+	for _, list := range lists {
 		for i, ce := range list.list {
 			f := ce.Fingerprints
-			if f.Sha1 == "" && f.Sha256 == "" {
+			switch {
+			case config.Version != ConfigVersion2 && ce.hasVersion2Matchers():
+				isValid = false
+				stderr(fmt.Sprintf("Entry at position %d in %s list uses matchers other than fingerprints, which require config version 2.", i, list.name))
+			case config.Version == ConfigVersion2 && len(ce.Matchers()) == 0:
+				isValid = false
+				stderr(fmt.Sprintf("Entry at position %d in %s list has no matchers. A fingerprint, public key, subject, issuer, serial, or key ID is required to identify the certificate.", i, list.name))
+			case config.Version != ConfigVersion2 && f.Sha1 == "" && f.Sha256 == "":
 				isValid = false
 				stderr(fmt.Sprintf("Entry at position %d in %s list has no fingerprints. A fingerprint is required to identify the certificate.", i, list.name))
-			} else if f.Sha1 != "" && f.Sha256 != "" {
+			case f.Sha1 != "" && f.Sha256 != "":
 				isValid = false
 				stderr(fmt.Sprintf("Entry at position %d in %s list has both SHA1 and SHA256 fingerprints. Only one type of fingerprint is permitted on a certificate.", i, list.name))
+			case config.Version != ConfigVersion2 && len(ce.Paths) > 0:
+				isValid = false
+				stderr(fmt.Sprintf("Entry at position %d in %s list has paths, which require config version 2.", i, list.name))
+			case list.pathRule && len(ce.Paths) > 0:
+				isValid = false
+				stderr(fmt.Sprintf("Entry at position %d in %s list has paths, which are set by the path rule instead.", i, list.name))
+			}
+			for _, p := range ce.Paths {
+				if !isValidGlob(p) {
+					isValid = false
+					stderr(fmt.Sprintf("Entry at position %d in %s list has invalid path %q. Paths must be absolute globs.", i, list.name, p))
+				}
 			}
 		}
 	}
+	if config.Version != ConfigVersion2 && len(config.PathRules) > 0 {
+		isValid = false
+		stderr("Path rules require config version 2.")
+	}
+	if config.Version != ConfigVersion2 && config.Rules != (Rules{}) {
+		isValid = false
+		stderr("Rules require config version 2.")
+	}
+	if config.Rules.ExpiresWithinDays < 0 || config.Rules.MinRSAKeySize < 0 || config.Rules.MaxValidityYears < 0 {
+		isValid = false
+		stderr("Rules must not be negative.")
+	}
+	if config.Version != ConfigVersion2 && len(config.Exceptions) > 0 {
+		isValid = false
+		stderr("Exceptions require config version 2.")
+	}
+	for i, e := range config.Exceptions {
+		switch {
+		case len(e.Matchers()) == 0 && len(e.Paths) == 0:
+			isValid = false
+			stderr(fmt.Sprintf("Exception at position %d has no matchers or paths. A fingerprint, path, or other matcher is required to identify what it waives.", i))
+		case e.Fingerprints.Sha1 != "" && e.Fingerprints.Sha256 != "":
+			isValid = false
+			stderr(fmt.Sprintf("Exception at position %d has both SHA1 and SHA256 fingerprints. Only one type of fingerprint is permitted on a certificate.", i))
+		case e.Owner == "":
+			isValid = false
+			stderr(fmt.Sprintf("Exception at position %d has no owner.", i))
+		case e.Expires.IsZero():
+			isValid = false
+			stderr(fmt.Sprintf("Exception at position %d has no expiry date. Exceptions must expire.", i))
+		}
+		for _, p := range e.Paths {
+			if !isValidGlob(p) {
+				isValid = false
+				stderr(fmt.Sprintf("Exception at position %d has invalid path %q. Paths must be absolute globs.", i, p))
+			}
+		}
+	}
+	for i, rule := range config.PathRules {
+		if !isValidGlob(rule.Path) {
+			isValid = false
+			stderr(fmt.Sprintf("Path rule at position %d has invalid path %q. Paths must be absolute globs.", i, rule.Path))
+		}
+	}
 	return isValid
 }
@@ -0,0 +1,129 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// Exception waives the issues found with the certificates it matches until it
+// expires, such as while a vendor fixes their image. An exception matches as a
+// certificate entry does; one with paths but no other matchers matches
+// everything found in those paths, including partial certificates and private
+// keys.
+type Exception struct {
+	CertificateEntry `yaml:",inline"`
+	// Owner is who is responsible for the exception.
+	Owner string `json:"owner"`
+	// Ticket is a reference to where the exception is tracked.
+	Ticket string `json:"ticket,omitempty"`
+	// Expires is when the exception stops being honoured.
+	Expires time.Time `json:"expires"`
+}
+
+// String describes the exception, for example
+// `exception SEC-123 for SHA256 ab12... (owner payments, expires 2025-01-31)`.
+func (e Exception) String() string {
+	sb := strings.Builder{}
+	sb.WriteString("exception ")
+	if e.Ticket != "" {
+		sb.WriteString(e.Ticket + " ")
+	}
+	sb.WriteString("for ")
+	targets := e.Matchers()
+	if len(e.Paths) > 0 {
+		targets = append(targets, "paths "+strings.Join(e.Paths, ", "))
+	}
+	sb.WriteString(strings.Join(targets, ", "))
+	sb.WriteString(fmt.Sprintf(" (owner %s, expires %s)", e.Owner, formatExpiry(e.Expires)))
+	return sb.String()
+}
+
+// IsActive returns true if the exception is honoured at the given time.
+func (e Exception) IsActive(now time.Time) bool {
+	return now.Before(e.Expires)
+}
+
+// formatExpiry formats the time as a date, unless it has a time of day.
+func formatExpiry(t time.Time) string {
+	if t.Equal(t.Truncate(24 * time.Hour)) {
+		return t.Format(time.DateOnly)
+	}
+	return t.Format(time.RFC3339)
+}
+
+// The issues which an exception can waive.
+const (
+	IssueNotAllowed = "not allowed"
+	IssueForbidden  = "forbidden"
+	IssueMisplaced  = "misplaced"
+	IssuePrivateKey = "forbidden private key"
+	// IssueRule prefixes the name of the violated rule.
+	IssueRule = "violated rule "
+)
+
+// WaivedIssue is an issue which would have failed validation, but for an
+// exception.
+type WaivedIssue struct {
+	Exception Exception
+	// Issue is the kind of issue which was waived, such as IssueForbidden.
+	Issue    string
+	Location string
+	// Certificate is the certificate the issue was found with, or nil for
+	// private keys and partial certificates.
+	Certificate *certificate.Found
+}
+
+// exception is a compiled exception.
+type exception struct {
+	exception Exception
+	matcher   *matcher
+}
+
+// waives returns true if the exception is active and matches the certificate,
+// or for issues without a certificate, the location.
+func (e *exception) waives(found *certificate.Found, location string, now time.Time) bool {
+	if !e.exception.IsActive(now) {
+		return false
+	}
+	if found != nil {
+		return e.matcher.matchesIn(*found)
+	}
+	return len(e.exception.Matchers()) == 0 && e.matcher.permits(location)
+}
+
+// ExpiringExceptions returns the exceptions which expire within the given
+// period, and those which have already expired.
+func (v *Validator) ExpiringExceptions(within time.Duration) (expiring, expired []Exception) {
+	now := time.Now()
+	for _, e := range v.exceptions {
+		switch {
+		case !e.exception.IsActive(now):
+			expired = append(expired, e.exception)
+		case !e.exception.IsActive(now.Add(within)):
+			expiring = append(expiring, e.exception)
+		}
+	}
+	return expiring, expired
+}
+
+// waive records the issue as waived and returns true if an active exception
+// covers it.
+func (v *Validator) waive(result *Result, now time.Time, issue, location string, found *certificate.Found) bool {
+	for _, e := range v.exceptions {
+		if e.waives(found, location, now) {
+			result.Waived = append(result.Waived, WaivedIssue{
+				Exception:   e.exception,
+				Issue:       issue,
+				Location:    location,
+				Certificate: found,
+			})
+			return true
+		}
+	}
+	return false
+}
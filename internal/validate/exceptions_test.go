@@ -0,0 +1,141 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"context"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func TestExceptions(t *testing.T) {
+	found := testCertificate(t)
+	fingerprint := hex.EncodeToString(found.FingerprintSha256[:])
+	forbid := []CertificateEntry{{Fingerprints: CertificateFingerprints{Sha256: fingerprint}}}
+
+	t.Run("Waives issues until the exception expires", func(t *testing.T) {
+		exception := Exception{
+			CertificateEntry: CertificateEntry{Fingerprints: CertificateFingerprints{Sha256: fingerprint}},
+			Owner:            "payments",
+			Ticket:           "SEC-123",
+			Expires:          time.Now().Add(time.Hour),
+		}
+		validator, err := NewValidator(Config{Version: ConfigVersion2, Forbid: forbid, Exceptions: []Exception{exception}}, true)
+		require.NoError(t, err)
+		r, err := validator.Validate([]certificate.Found{found})
+		require.NoError(t, err)
+		assert.Truef(t, r.IsPass(), "Validation reported as failed, expected pass")
+		require.Len(t, r.Waived, 1)
+		assert.Equal(t, IssueForbidden, r.Waived[0].Issue)
+		assert.Equal(t, exception, r.Waived[0].Exception)
+
+		exception.Expires = time.Now().Add(-time.Hour)
+		validator, err = NewValidator(Config{Version: ConfigVersion2, Forbid: forbid, Exceptions: []Exception{exception}}, true)
+		require.NoError(t, err)
+		r, err = validator.Validate([]certificate.Found{found})
+		require.NoError(t, err)
+		assert.Falsef(t, r.IsPass(), "Validation reported as passed, when we expected it to fail")
+		assert.Len(t, r.ForbiddenCertificates, 1)
+		assert.Empty(t, r.Waived)
+	})
+
+	t.Run("Path exceptions waive everything in their paths", func(t *testing.T) {
+		config := Config{
+			Version:           ConfigVersion2,
+			ForbidPrivateKeys: true,
+			Exceptions: []Exception{{
+				CertificateEntry: CertificateEntry{Paths: []string{"/opt/vendor/**"}},
+				Owner:            "platform",
+				Expires:          time.Now().Add(time.Hour),
+			}},
+		}
+		validator, err := NewValidator(config, false)
+		require.NoError(t, err)
+
+		inVendor := found
+		inVendor.Location = "/opt/vendor/ca.pem"
+		r, err := validator.ValidateParsed(&certificate.ParsedCertificates{
+			Found:       []certificate.Found{inVendor, found},
+			PrivateKeys: []certificate.PrivateKey{{Location: "/opt/vendor/key.pem"}, {Location: "/app/key.pem"}},
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []certificate.Found{found}, r.NotAllowedCertificates)
+		assert.Equal(t, []certificate.PrivateKey{{Location: "/app/key.pem"}}, r.ForbiddenPrivateKeys)
+		require.Len(t, r.Waived, 2)
+		assert.Equal(t, IssueNotAllowed, r.Waived[0].Issue)
+		assert.Equal(t, IssuePrivateKey, r.Waived[1].Issue)
+		assert.Nil(t, r.Waived[1].Certificate)
+	})
+
+	t.Run("Waives rule violations", func(t *testing.T) {
+		config := Config{
+			Version: ConfigVersion2,
+			Rules:   Rules{ExpiresWithinDays: 30},
+			Exceptions: []Exception{{
+				CertificateEntry: CertificateEntry{Subject: "CN=Example Root CA,O=Example"},
+				Owner:            "platform",
+				Expires:          time.Now().Add(time.Hour),
+			}},
+		}
+		validator, err := NewValidator(config, true)
+		require.NoError(t, err)
+		r, err := validator.Validate([]certificate.Found{found})
+		require.NoError(t, err)
+		assert.Truef(t, r.IsPass(), "Validation reported as failed, expected pass")
+		require.Len(t, r.Waived, 1)
+		assert.Equal(t, IssueRule+RuleExpiresWithinDays, r.Waived[0].Issue)
+	})
+
+	t.Run("Reports expiring exceptions", func(t *testing.T) {
+		expired := Exception{CertificateEntry: CertificateEntry{Paths: []string{"/a"}}, Owner: "a", Expires: time.Now().Add(-time.Hour)}
+		soon := Exception{CertificateEntry: CertificateEntry{Paths: []string{"/b"}}, Owner: "b", Expires: time.Now().Add(24 * time.Hour)}
+		later := Exception{CertificateEntry: CertificateEntry{Paths: []string{"/c"}}, Owner: "c", Expires: time.Now().AddDate(1, 0, 0)}
+		validator, err := NewValidator(Config{Version: ConfigVersion2, Exceptions: []Exception{expired, soon, later}}, true)
+		require.NoError(t, err)
+
+		expiring, gone := validator.ExpiringExceptions(14 * 24 * time.Hour)
+		assert.Equal(t, []Exception{soon}, expiring)
+		assert.Equal(t, []Exception{expired}, gone)
+	})
+
+	t.Run("Exceptions must have an owner and expiry", func(t *testing.T) {
+		paths := CertificateEntry{Paths: []string{"/app/**"}}
+		expires := time.Now().Add(time.Hour)
+		assert.True(t, IsConfigValid(&Config{Version: ConfigVersion2, Exceptions: []Exception{{CertificateEntry: paths, Owner: "a", Expires: expires}}}))
+		assert.False(t, IsConfigValid(&Config{Version: ConfigVersion2, Exceptions: []Exception{{CertificateEntry: paths, Expires: expires}}}))
+		assert.False(t, IsConfigValid(&Config{Version: ConfigVersion2, Exceptions: []Exception{{CertificateEntry: paths, Owner: "a"}}}))
+		assert.False(t, IsConfigValid(&Config{Version: ConfigVersion2, Exceptions: []Exception{{Owner: "a", Expires: expires}}}))
+		assert.False(t, IsConfigValid(&Config{Version: ConfigVersion1, Exceptions: []Exception{{CertificateEntry: paths, Owner: "a", Expires: expires}}}))
+	})
+
+	t.Run("Loads exceptions from YAML", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), ".paranoia.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(`version: "2"
+exceptions:
+  - comment: "Vendor CA, until they fix their image"
+    owner: payments
+    ticket: SEC-123
+    expires: 2030-01-31
+    fingerprints:
+      sha256: `+fingerprint+`
+    paths: ["/opt/vendor/**"]
+`), 0o600))
+		config, err := LoadConfig(context.Background(), path)
+		require.NoError(t, err)
+		require.Len(t, config.Exceptions, 1)
+		e := config.Exceptions[0]
+		assert.Equal(t, "payments", e.Owner)
+		assert.Equal(t, fingerprint, e.Fingerprints.Sha256)
+		assert.Equal(t, []string{"/opt/vendor/**"}, e.Paths)
+		assert.Equal(t, time.Date(2030, 1, 31, 0, 0, 0, 0, time.UTC), e.Expires)
+		assert.Equal(t, "exception SEC-123 for SHA256 "+fingerprint+", paths /opt/vendor/** (owner payments, expires 2030-01-31)", e.String())
+	})
+}
@@ -0,0 +1,57 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"path"
+	"strings"
+)
+
+// matchGlob reports whether the location of a file in an image matches the
+// glob. Each element of the glob is matched as by path.Match, except "**"
+// which matches any number of directories, including none.
+func matchGlob(glob, location string) bool {
+	return matchElements(splitPath(glob), splitPath(location))
+}
+
+func matchElements(glob, location []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := 0; i <= len(location); i++ {
+				if matchElements(glob[1:], location[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(location) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], location[0]); !ok {
+			return false
+		}
+		glob, location = glob[1:], location[1:]
+	}
+	return len(location) == 0
+}
+
+// isValidGlob reports whether the glob is absolute and well formed.
+func isValidGlob(glob string) bool {
+	if !strings.HasPrefix(glob, "/") {
+		return false
+	}
+	for _, element := range splitPath(glob) {
+		if _, err := path.Match(element, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(path.Clean(p), "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
@@ -0,0 +1,38 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	for _, tc := range []struct {
+		glob     string
+		location string
+		matches  bool
+	}{
+		{glob: "/etc/ssl/certs/internal-ca.pem", location: "/etc/ssl/certs/internal-ca.pem", matches: true},
+		{glob: "/etc/ssl/certs/internal-ca.pem", location: "/etc/ssl/certs/other.pem"},
+		{glob: "/etc/ssl/certs/*.pem", location: "/etc/ssl/certs/internal-ca.pem", matches: true},
+		{glob: "/etc/ssl/certs/*.pem", location: "/etc/ssl/certs/java/cacerts"},
+		{glob: "/app/**", location: "/app/ca.pem", matches: true},
+		{glob: "/app/**", location: "/app/vendor/certs/ca.pem", matches: true},
+		{glob: "/app/**", location: "/application/ca.pem"},
+		{glob: "/**/*.crt", location: "/usr/local/share/ca-certificates/internal.crt", matches: true},
+		{glob: "/**/*.crt", location: "/internal.crt", matches: true},
+		{glob: "/app/**/ca.pem", location: "/app/ca.pem", matches: true},
+		{glob: "/app/**/ca.pem", location: "/app/x/key.pem"},
+	} {
+		assert.Equalf(t, tc.matches, matchGlob(tc.glob, tc.location), "glob %s, location %s", tc.glob, tc.location)
+	}
+}
+
+func TestIsValidGlob(t *testing.T) {
+	assert.True(t, isValidGlob("/app/**"))
+	assert.True(t, isValidGlob("/etc/ssl/certs/*.pem"))
+	assert.False(t, isValidGlob("app/**"))
+	assert.False(t, isValidGlob("/app/[a"))
+}
@@ -0,0 +1,486 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// PolicyMediaType is the media type of the layer holding the config in an
+	// OCI policy artifact. Artifacts with a single layer may use any media
+	// type.
+	PolicyMediaType types.MediaType = "application/vnd.paranoia.policy.v1+yaml"
+
+	// maxIncludeDepth is how deeply includes may be nested.
+	maxIncludeDepth = 10
+	// maxPolicySize is the largest remote policy which will be read.
+	maxPolicySize = 10 << 20
+	// fetchTimeout is the maximum time allowed for fetching a remote config.
+	fetchTimeout = 30 * time.Second
+)
+
+// Include is another config to merge into this one. Exactly one of Path, URL,
+// and OCI is set.
+type Include struct {
+	// Path is a local file, relative to the including config. Remote configs
+	// cannot include local files.
+	Path string `json:"path,omitempty"`
+	// URL is an HTTPS URL to fetch the config from.
+	URL string `json:"url,omitempty"`
+	// OCI is a reference to an OCI artifact holding the config.
+	OCI string `json:"oci,omitempty"`
+	// Digest pins the config, in the form "sha256:<hex>". For OCI artifacts
+	// it is the digest of the manifest, and may instead be given in the
+	// reference. Pinned remote configs are read from the cache when present.
+	// Every include of a pinned config must be pinned too, so that its
+	// contents can't change.
+	Digest string `json:"digest,omitempty"`
+}
+
+// pinned returns true if the include is pinned by a digest.
+func (i Include) pinned() bool {
+	if i.Digest != "" {
+		return true
+	}
+	if i.OCI == "" {
+		return false
+	}
+	ref, err := name.ParseReference(i.OCI)
+	if err != nil {
+		return false
+	}
+	_, ok := ref.(name.Digest)
+	return ok
+}
+
+func (i Include) location() string {
+	switch {
+	case i.Path != "":
+		return i.Path
+	case i.URL != "":
+		return i.URL
+	default:
+		return i.OCI
+	}
+}
+
+// PolicySource is a config which was loaded to make up the policy.
+type PolicySource struct {
+	// Location is the path, URL, or OCI reference the config was loaded from.
+	Location string
+	// Digest is the digest of the config's contents, or for OCI artifacts the
+	// manifest.
+	Digest string
+	// Cached is true if a remote config was read from the cache.
+	Cached bool
+}
+
+func (s PolicySource) String() string {
+	str := s.Location + " (" + s.Digest
+	if s.Cached {
+		str += ", cached"
+	}
+	return str + ")"
+}
+
+// LoadOption is a functional option that configures how configs are loaded.
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	cacheDir   string
+	client     *http.Client
+	remoteOpts []remote.Option
+}
+
+// WithPolicyCacheDir is a functional option that caches remote configs in the
+// given directory.
+func WithPolicyCacheDir(dir string) LoadOption {
+	return func(o *loadOptions) {
+		o.cacheDir = dir
+	}
+}
+
+// WithHTTPClient is a functional option that sets the client used to fetch
+// configs from URLs. By default, a client with a 30 second timeout is used.
+func WithHTTPClient(client *http.Client) LoadOption {
+	return func(o *loadOptions) {
+		o.client = client
+	}
+}
+
+// WithRemoteOptions is a functional option that adds to the options used to
+// fetch configs from OCI registries.
+func WithRemoteOptions(opts ...remote.Option) LoadOption {
+	return func(o *loadOptions) {
+		o.remoteOpts = append(o.remoteOpts, opts...)
+	}
+}
+
+// loader resolves the includes of a config.
+type loader struct {
+	ctx  context.Context
+	opts loadOptions
+	// including are the locations of the configs being loaded, to detect
+	// cycles.
+	including []string
+}
+
+// load parses the config and merges in its includes. Local includes are
+// relative to dir, or forbidden if dir is empty. The key identifies the config
+// to detect cycles. The includes of a pinned config must also be pinned.
+func (l *loader) load(source PolicySource, key string, b []byte, dir string, pinned bool) (*Config, error) {
+	var contents map[string]interface{}
+	if err := yaml.Unmarshal(b, &contents); err != nil {
+		return nil, err
+	}
+	version, _ := contents["version"].(string)
+	if !slices.Contains(SupportedVersions, version) {
+		return nil, errors.New("Unsupported config version, expected one of " + strings.Join(SupportedVersions, ", ") + ", found " + fmt.Sprint(contents["version"]))
+	}
+
+	var c Config
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if source.Digest == "" {
+		source.Digest = digestOf(b)
+	}
+	c.Sources = []PolicySource{source}
+	if len(c.Include) == 0 {
+		return &c, nil
+	}
+	if c.Version != ConfigVersion2 {
+		return nil, errors.New("include requires config version 2")
+	}
+	if len(l.including) >= maxIncludeDepth {
+		return nil, errors.Errorf("includes are nested more than %d deep", maxIncludeDepth)
+	}
+	l.including = append(l.including, key)
+	defer func() { l.including = l.including[:len(l.including)-1] }()
+
+	merged := &Config{Version: ConfigVersion2}
+	for i, inc := range c.Include {
+		if pinned && !inc.pinned() {
+			return nil, errors.Errorf("include at position %d (%s) must be pinned by digest, as %s is pinned", i, inc.location(), source.Location)
+		}
+		included, err := l.include(inc, dir)
+		if err != nil {
+			return nil, errors.Wrapf(err, "include at position %d (%s)", i, inc.location())
+		}
+		merged = mergeConfigs(merged, included)
+	}
+	merged = mergeConfigs(merged, &c)
+	merged.Include = c.Include
+	return merged, nil
+}
+
+func (l *loader) include(inc Include, dir string) (*Config, error) {
+	set := 0
+	for _, s := range []string{inc.Path, inc.URL, inc.OCI} {
+		if s != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.New("exactly one of path, url, and oci must be set")
+	}
+
+	var (
+		source PolicySource
+		key    string
+		b      []byte
+		err    error
+	)
+	switch {
+	case inc.Path != "":
+		if dir == "" {
+			return nil, errors.New("remote configs cannot include local files")
+		}
+		path := inc.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(dir, path)
+		}
+		source = PolicySource{Location: path}
+		if key, err = filepath.Abs(path); err != nil {
+			return nil, err
+		}
+		if b, err = os.ReadFile(path); err != nil {
+			return nil, err
+		}
+		if err := verifyDigest(inc.Digest, b); err != nil {
+			return nil, err
+		}
+		dir = filepath.Dir(path)
+	case inc.URL != "":
+		source, b, err = l.fetchURL(inc)
+		key, dir = inc.URL, ""
+	default:
+		source, b, err = l.fetchOCI(inc)
+		key, dir = inc.OCI, ""
+	}
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(l.including, key) {
+		return nil, errors.Errorf("include cycle through %s", source.Location)
+	}
+	return l.load(source, key, b, dir, inc.pinned())
+}
+
+// fetchURL fetches a config over HTTPS, reading a pinned config from the
+// cache if present, and falling back to the cache if the fetch fails.
+func (l *loader) fetchURL(inc Include) (PolicySource, []byte, error) {
+	source := PolicySource{Location: inc.URL}
+	if !strings.HasPrefix(inc.URL, "https://") {
+		return source, nil, errors.New("configs can only be fetched over HTTPS")
+	}
+
+	key := cacheKey(inc.URL, inc.Digest)
+	if inc.Digest != "" {
+		if b, ok := l.cached(key); ok && verifyDigest(inc.Digest, b) == nil {
+			source.Cached = true
+			return source, b, nil
+		}
+	}
+
+	b, err := l.get(inc.URL)
+	if err == nil {
+		if err := verifyDigest(inc.Digest, b); err != nil {
+			return source, nil, err
+		}
+		l.cache(key, b)
+		return source, b, nil
+	}
+	if cached, ok := l.cached(key); ok && inc.Digest == "" {
+		stderr(fmt.Sprintf("Warning: failed to fetch %s, using cached copy: %s", inc.URL, err))
+		source.Cached = true
+		return source, cached, nil
+	}
+	return source, nil, err
+}
+
+func (l *loader) get(url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(l.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	client := l.opts.client
+	if client == nil {
+		client = &http.Client{Timeout: fetchTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unexpected status %s", resp.Status)
+	}
+	return readPolicy(resp.Body)
+}
+
+// fetchOCI fetches a config from an OCI artifact, reading a pinned config from
+// the cache if present, and falling back to the cache if the fetch fails.
+func (l *loader) fetchOCI(inc Include) (PolicySource, []byte, error) {
+	source := PolicySource{Location: inc.OCI}
+	ref, err := name.ParseReference(inc.OCI)
+	if err != nil {
+		return source, nil, err
+	}
+	pinned := inc.Digest
+	if d, ok := ref.(name.Digest); ok {
+		if pinned != "" && pinned != d.DigestStr() {
+			return source, nil, errors.Errorf("digest %s doesn't match the reference's digest %s", pinned, d.DigestStr())
+		}
+		pinned = d.DigestStr()
+	}
+
+	key := "oci-" + cacheKey(inc.OCI, pinned)
+	if pinned != "" {
+		if b, ok := l.cached(key); ok {
+			source.Digest, source.Cached = pinned, true
+			return source, b, nil
+		}
+	}
+
+	b, digest, err := l.pull(ref)
+	if err == nil {
+		if pinned != "" && digest != pinned {
+			return source, nil, errors.Errorf("digest mismatch, expected %s but found %s", pinned, digest)
+		}
+		l.cache(key, b)
+		source.Digest = digest
+		return source, b, nil
+	}
+	if cached, ok := l.cached(key); ok && pinned == "" {
+		stderr(fmt.Sprintf("Warning: failed to fetch %s, using cached copy: %s", inc.OCI, err))
+		source.Cached = true
+		return source, cached, nil
+	}
+	return source, nil, err
+}
+
+// pull returns the config in an OCI artifact, and the digest of its manifest.
+func (l *loader) pull(ref name.Reference) ([]byte, string, error) {
+	ctx, cancel := context.WithTimeout(l.ctx, fetchTimeout)
+	defer cancel()
+	opts := append([]remote.Option{
+		remote.WithContext(ctx),
+		remote.WithAuthFromKeychain(authn.DefaultKeychain),
+	}, l.opts.remoteOpts...)
+	img, err := remote.Image(ref, opts...)
+	if err != nil {
+		return nil, "", err
+	}
+	digest, err := img.Digest()
+	if err != nil {
+		return nil, "", err
+	}
+	layers, err := img.Layers()
+	if err != nil {
+		return nil, "", err
+	}
+	for _, layer := range layers {
+		mt, err := layer.MediaType()
+		if err != nil {
+			return nil, "", err
+		}
+		if mt == PolicyMediaType || len(layers) == 1 {
+			rc, err := layer.Compressed()
+			if err != nil {
+				return nil, "", err
+			}
+			defer rc.Close()
+			b, err := readPolicy(rc)
+			return b, digest.String(), err
+		}
+	}
+	return nil, "", errors.Errorf("artifact has no layer of media type %s", PolicyMediaType)
+}
+
+func readPolicy(r io.Reader) ([]byte, error) {
+	b, err := io.ReadAll(io.LimitReader(r, maxPolicySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxPolicySize {
+		return nil, errors.Errorf("config is larger than %d bytes", maxPolicySize)
+	}
+	return b, nil
+}
+
+// cacheKey returns the name of the cache file for a remote config. Pinned
+// configs are cached by their digest, so that they are only fetched once.
+func cacheKey(location, digest string) string {
+	if digest != "" {
+		return strings.ReplaceAll(digest, ":", "-")
+	}
+	sum := sha256.Sum256([]byte(location))
+	return "location-" + hex.EncodeToString(sum[:])
+}
+
+func (l *loader) cached(key string) ([]byte, bool) {
+	if l.opts.cacheDir == "" {
+		return nil, false
+	}
+	b, err := os.ReadFile(filepath.Join(l.opts.cacheDir, key))
+	return b, err == nil
+}
+
+// cache writes the config to the cache, warning if it can't.
+func (l *loader) cache(key string, b []byte) {
+	if l.opts.cacheDir == "" {
+		return
+	}
+	if err := writeFileAtomic(filepath.Join(l.opts.cacheDir, key), b); err != nil {
+		stderr(fmt.Sprintf("Warning: failed to cache config: %s", err))
+	}
+}
+
+func writeFileAtomic(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func digestOf(b []byte) string {
+	sum := sha256.Sum256(b)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// verifyDigest returns an error if the digest is set and doesn't match the
+// contents.
+func verifyDigest(digest string, b []byte) error {
+	if digest == "" {
+		return nil
+	}
+	if !strings.HasPrefix(digest, "sha256:") {
+		return errors.Errorf("unsupported digest %q, expected sha256:<hex>", digest)
+	}
+	if actual := digestOf(b); !strings.EqualFold(actual, digest) {
+		return errors.Errorf("digest mismatch, expected %s but found %s", digest, actual)
+	}
+	return nil
+}
+
+// mergeConfigs layers the override config on top of the base. Lists are
+// combined, so an entry in either applies, and forbidding a certificate always
+// takes precedence over allowing it. Boolean checks are enabled if either
+// config enables them, and numeric rules set by the override replace the
+// base's.
+func mergeConfigs(base, override *Config) *Config {
+	out := &Config{
+		Version:           ConfigVersion2,
+		Allow:             slices.Concat(base.Allow, override.Allow),
+		Forbid:            slices.Concat(base.Forbid, override.Forbid),
+		Require:           slices.Concat(base.Require, override.Require),
+		ForbidPrivateKeys: base.ForbidPrivateKeys || override.ForbidPrivateKeys,
+		PathRules:         slices.Concat(base.PathRules, override.PathRules),
+		Exceptions:        slices.Concat(base.Exceptions, override.Exceptions),
+		Sources:           slices.Concat(base.Sources, override.Sources),
+		Rules:             base.Rules,
+	}
+	if override.Rules.ExpiresWithinDays != 0 {
+		out.Rules.ExpiresWithinDays = override.Rules.ExpiresWithinDays
+	}
+	if override.Rules.MinRSAKeySize != 0 {
+		out.Rules.MinRSAKeySize = override.Rules.MinRSAKeySize
+	}
+	if override.Rules.MaxValidityYears != 0 {
+		out.Rules.MaxValidityYears = override.Rules.MaxValidityYears
+	}
+	out.Rules.ForbidSHA1Signatures = base.Rules.ForbidSHA1Signatures || override.Rules.ForbidSHA1Signatures
+	out.Rules.ForbidPartials = base.Rules.ForbidPartials || override.Rules.ForbidPartials
+	return out
+}
@@ -0,0 +1,239 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/crane"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	baselineSHA256 = "bd40be0eccfce513ab318882f03962e4e2ec3799b51392e82805d9249e426d28"
+	repoSHA256     = "96bcec06264976f37460779acf28c5a7cfe8a3c0aae11a8ffcee05c0bddf08c6"
+)
+
+var baselinePolicy = `version: "2"
+forbidPrivateKeys: true
+forbid:
+  - fingerprints:
+      sha256: ` + baselineSHA256 + `
+rules:
+  minRSAKeySize: 2048
+  maxValidityYears: 25
+`
+
+func writeFile(t *testing.T, path, contents string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+	require.NoError(t, os.WriteFile(path, []byte(contents), 0o600))
+}
+
+func TestLoadConfigIncludes(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Merges local includes beneath the including config", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "shared", "baseline.yaml"), baselinePolicy)
+		writeFile(t, filepath.Join(dir, ".paranoia.yaml"), `version: "2"
+include:
+  - path: shared/baseline.yaml
+allow:
+  - fingerprints:
+      sha256: `+repoSHA256+`
+rules:
+  maxValidityYears: 30
+`)
+
+		config, err := LoadConfig(ctx, filepath.Join(dir, ".paranoia.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, ConfigVersion2, config.Version)
+		assert.True(t, config.ForbidPrivateKeys)
+		require.Len(t, config.Forbid, 1)
+		assert.Equal(t, baselineSHA256, config.Forbid[0].Fingerprints.Sha256)
+		require.Len(t, config.Allow, 1)
+		assert.Equal(t, repoSHA256, config.Allow[0].Fingerprints.Sha256)
+		assert.Equal(t, Rules{MinRSAKeySize: 2048, MaxValidityYears: 30}, config.Rules)
+
+		require.Len(t, config.Sources, 2)
+		assert.Equal(t, filepath.Join(dir, "shared", "baseline.yaml"), config.Sources[0].Location)
+		assert.Equal(t, digestOf([]byte(baselinePolicy)), config.Sources[0].Digest)
+		assert.Equal(t, filepath.Join(dir, ".paranoia.yaml"), config.Sources[1].Location)
+		assert.True(t, IsConfigValid(config))
+	})
+
+	t.Run("Checks the digest of pinned includes", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "baseline.yaml"), baselinePolicy)
+		writeFile(t, filepath.Join(dir, ".paranoia.yaml"), `version: "2"
+include:
+  - path: baseline.yaml
+    digest: sha256:0000000000000000000000000000000000000000000000000000000000000000
+`)
+		_, err := LoadConfig(ctx, filepath.Join(dir, ".paranoia.yaml"))
+		assert.ErrorContains(t, err, "digest mismatch")
+	})
+
+	t.Run("Rejects include cycles", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "a.yaml"), "version: \"2\"\ninclude:\n  - path: b.yaml\n")
+		writeFile(t, filepath.Join(dir, "b.yaml"), "version: \"2\"\ninclude:\n  - path: a.yaml\n")
+		_, err := LoadConfig(ctx, filepath.Join(dir, "a.yaml"))
+		assert.ErrorContains(t, err, "include cycle")
+	})
+
+	t.Run("Rejects includes in version 1 configs", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "baseline.yaml"), baselinePolicy)
+		writeFile(t, filepath.Join(dir, ".paranoia.yaml"), "version: \"1\"\ninclude:\n  - path: baseline.yaml\n")
+		_, err := LoadConfig(ctx, filepath.Join(dir, ".paranoia.yaml"))
+		assert.Error(t, err)
+	})
+
+	t.Run("Fetches and caches HTTPS includes", func(t *testing.T) {
+		requests := 0
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			fmt.Fprint(w, baselinePolicy)
+		}))
+		defer srv.Close()
+
+		dir := t.TempDir()
+		cacheDir := filepath.Join(dir, "cache")
+		config := filepath.Join(dir, ".paranoia.yaml")
+		writeFile(t, config, `version: "2"
+include:
+  - url: `+srv.URL+`/baseline.yaml
+    digest: `+digestOf([]byte(baselinePolicy))+`
+`)
+		opts := []LoadOption{WithHTTPClient(srv.Client()), WithPolicyCacheDir(cacheDir)}
+
+		c, err := LoadConfig(ctx, config, opts...)
+		require.NoError(t, err)
+		assert.Len(t, c.Forbid, 1)
+		assert.False(t, c.Sources[0].Cached)
+
+		// Pinned includes are read from the cache.
+		c, err = LoadConfig(ctx, config, opts...)
+		require.NoError(t, err)
+		assert.Len(t, c.Forbid, 1)
+		assert.True(t, c.Sources[0].Cached)
+		assert.Equal(t, 1, requests)
+
+		writeFile(t, config, "version: \"2\"\ninclude:\n  - url: "+srv.URL+"/baseline.yaml\n    digest: sha256:00\n")
+		_, err = LoadConfig(ctx, config, WithHTTPClient(srv.Client()))
+		assert.ErrorContains(t, err, "digest mismatch")
+
+		writeFile(t, config, "version: \"2\"\ninclude:\n  - url: "+strings.Replace(srv.URL, "https", "http", 1)+"/baseline.yaml\n")
+		_, err = LoadConfig(ctx, config, WithHTTPClient(srv.Client()))
+		assert.ErrorContains(t, err, "HTTPS")
+	})
+
+	t.Run("Falls back to the cache for unpinned includes", func(t *testing.T) {
+		up := true
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !up {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fmt.Fprint(w, baselinePolicy)
+		}))
+		defer srv.Close()
+
+		dir := t.TempDir()
+		config := filepath.Join(dir, ".paranoia.yaml")
+		writeFile(t, config, "version: \"2\"\ninclude:\n  - url: "+srv.URL+"/baseline.yaml\n")
+		opts := []LoadOption{WithHTTPClient(srv.Client()), WithPolicyCacheDir(filepath.Join(dir, "cache"))}
+
+		_, err := LoadConfig(ctx, config, opts...)
+		require.NoError(t, err)
+		up = false
+		c, err := LoadConfig(ctx, config, opts...)
+		require.NoError(t, err)
+		assert.Len(t, c.Forbid, 1)
+		assert.True(t, c.Sources[0].Cached)
+	})
+
+	t.Run("Requires the includes of pinned configs to be pinned", func(t *testing.T) {
+		var outer string
+		srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/outer.yaml" {
+				fmt.Fprint(w, outer)
+				return
+			}
+			fmt.Fprint(w, baselinePolicy)
+		}))
+		defer srv.Close()
+
+		dir := t.TempDir()
+		config := filepath.Join(dir, ".paranoia.yaml")
+		load := func(inner string) error {
+			outer = "version: \"2\"\ninclude:\n  - url: " + srv.URL + "/baseline.yaml\n" + inner
+			writeFile(t, config, "version: \"2\"\ninclude:\n  - url: "+srv.URL+"/outer.yaml\n    digest: "+digestOf([]byte(outer))+"\n")
+			_, err := LoadConfig(ctx, config, WithHTTPClient(srv.Client()))
+			return err
+		}
+
+		assert.ErrorContains(t, load(""), "must be pinned by digest")
+		assert.NoError(t, load("    digest: "+digestOf([]byte(baselinePolicy))+"\n"))
+	})
+
+	t.Run("Fetches includes from OCI artifacts", func(t *testing.T) {
+		reg := httptest.NewServer(registry.New())
+		defer reg.Close()
+
+		artifact, err := mutate.Append(empty.Image, mutate.Addendum{Layer: static.NewLayer([]byte(baselinePolicy), PolicyMediaType)})
+		require.NoError(t, err)
+		ref := strings.TrimPrefix(reg.URL, "http://") + "/policies/baseline:v1"
+		require.NoError(t, crane.Push(artifact, ref))
+		digest, err := artifact.Digest()
+		require.NoError(t, err)
+
+		dir := t.TempDir()
+		config := filepath.Join(dir, ".paranoia.yaml")
+		writeFile(t, config, "version: \"2\"\ninclude:\n  - oci: "+ref+"\n    digest: "+digest.String()+"\n")
+
+		c, err := LoadConfig(ctx, config, WithPolicyCacheDir(filepath.Join(dir, "cache")))
+		require.NoError(t, err)
+		assert.Len(t, c.Forbid, 1)
+		assert.Equal(t, PolicySource{Location: ref, Digest: digest.String()}, c.Sources[0])
+
+		// Pinned artifacts are read from the cache, without the registry.
+		reg.Close()
+		c, err = LoadConfig(ctx, config, WithPolicyCacheDir(filepath.Join(dir, "cache")))
+		require.NoError(t, err)
+		assert.Len(t, c.Forbid, 1)
+		assert.True(t, c.Sources[0].Cached)
+	})
+}
+
+func TestMergeConfigs(t *testing.T) {
+	base := &Config{
+		Version:           ConfigVersion1,
+		ForbidPrivateKeys: true,
+		Allow:             []CertificateEntry{{Comment: "base"}},
+		Rules:             Rules{ExpiresWithinDays: 30, ForbidPartials: true},
+	}
+	override := &Config{
+		Version: ConfigVersion2,
+		Allow:   []CertificateEntry{{Comment: "override"}},
+		Rules:   Rules{ExpiresWithinDays: 7, ForbidSHA1Signatures: true},
+	}
+	merged := mergeConfigs(base, override)
+	assert.Equal(t, []CertificateEntry{{Comment: "base"}, {Comment: "override"}}, merged.Allow)
+	assert.True(t, merged.ForbidPrivateKeys, "overrides cannot disable checks")
+	assert.Equal(t, Rules{ExpiresWithinDays: 7, ForbidSHA1Signatures: true, ForbidPartials: true}, merged.Rules)
+}
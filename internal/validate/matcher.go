@@ -0,0 +1,208 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/util/checksum"
+)
+
+// Matchers returns a description of each matcher set on the entry, such as
+// "SHA256 <fingerprint>" or `subject "CN=Example"`.
+func (ce CertificateEntry) Matchers() []string {
+	var m []string
+	if ce.Fingerprints.Sha256 != "" {
+		m = append(m, "SHA256 "+ce.Fingerprints.Sha256)
+	}
+	if ce.Fingerprints.Sha1 != "" {
+		m = append(m, "SHA1 "+ce.Fingerprints.Sha1)
+	}
+	if ce.SPKISHA256 != "" {
+		m = append(m, "SPKI SHA256 "+ce.SPKISHA256)
+	}
+	if ce.Subject != "" {
+		m = append(m, fmt.Sprintf("subject %q", ce.Subject))
+	}
+	if ce.SubjectRegex != "" {
+		m = append(m, fmt.Sprintf("subject matching %q", ce.SubjectRegex))
+	}
+	if ce.Issuer != "" {
+		m = append(m, fmt.Sprintf("issuer %q", ce.Issuer))
+	}
+	if ce.IssuerRegex != "" {
+		m = append(m, fmt.Sprintf("issuer matching %q", ce.IssuerRegex))
+	}
+	if ce.Serial != "" {
+		m = append(m, "serial "+ce.Serial)
+	}
+	if ce.KeyID != "" {
+		m = append(m, "key ID "+ce.KeyID)
+	}
+	return m
+}
+
+// IsFingerprintOnly returns true if the entry identifies certificates only by
+// their fingerprint, as in version 1 configs.
+func (ce CertificateEntry) IsFingerprintOnly() bool {
+	return !ce.hasVersion2Matchers()
+}
+
+// hasVersion2Matchers returns true if the entry sets any matcher which is not
+// supported in version 1 configs.
+func (ce CertificateEntry) hasVersion2Matchers() bool {
+	return ce.SPKISHA256 != "" || ce.Subject != "" || ce.SubjectRegex != "" ||
+		ce.Issuer != "" || ce.IssuerRegex != "" || ce.Serial != "" || ce.KeyID != ""
+}
+
+// matcher is a compiled config entry. Unset fields match any certificate.
+type matcher struct {
+	entry        CertificateEntry
+	sha1         *[20]byte
+	sha256       *[32]byte
+	spkiSHA256   *[32]byte
+	subject      string
+	subjectRegex *regexp.Regexp
+	issuer       string
+	issuerRegex  *regexp.Regexp
+	serial       *big.Int
+	keyID        []byte
+	paths        []string
+}
+
+func newMatcher(entry CertificateEntry) (*matcher, error) {
+	m := matcher{
+		entry:   entry,
+		subject: entry.Subject,
+		issuer:  entry.Issuer,
+		paths:   entry.Paths,
+	}
+	if entry.Fingerprints.Sha1 != "" {
+		sha, err := checksum.ParseSHA1(entry.Fingerprints.Sha1)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid SHA1")
+		}
+		m.sha1 = &sha
+	}
+	if entry.Fingerprints.Sha256 != "" {
+		sha, err := checksum.ParseSHA256(entry.Fingerprints.Sha256)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid SHA256")
+		}
+		m.sha256 = &sha
+	}
+	if entry.SPKISHA256 != "" {
+		sha, err := checksum.ParseSHA256(normaliseHex(entry.SPKISHA256))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid SPKI SHA256")
+		}
+		m.spkiSHA256 = &sha
+	}
+	if entry.SubjectRegex != "" {
+		re, err := regexp.Compile(entry.SubjectRegex)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid subject regex")
+		}
+		m.subjectRegex = re
+	}
+	if entry.IssuerRegex != "" {
+		re, err := regexp.Compile(entry.IssuerRegex)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid issuer regex")
+		}
+		m.issuerRegex = re
+	}
+	if entry.Serial != "" {
+		serial, ok := new(big.Int).SetString(normaliseHex(entry.Serial), 16)
+		if !ok {
+			return nil, errors.New("invalid serial")
+		}
+		m.serial = serial
+	}
+	if entry.KeyID != "" {
+		keyID, err := hex.DecodeString(normaliseHex(entry.KeyID))
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid key ID")
+		}
+		m.keyID = keyID
+	}
+	return &m, nil
+}
+
+// normaliseHex strips the colons and leading "0x" which are commonly used
+// when displaying hex encoded certificate fields.
+func normaliseHex(s string) string {
+	s = strings.ToLower(strings.ReplaceAll(s, ":", ""))
+	return strings.TrimPrefix(s, "0x")
+}
+
+// matches returns true if the certificate matches every matcher set on the
+// entry. Matchers other than fingerprints never match a certificate which
+// could not be parsed.
+func (m *matcher) matches(found certificate.Found) bool {
+	if m.sha1 != nil && *m.sha1 != found.FingerprintSha1 {
+		return false
+	}
+	if m.sha256 != nil && *m.sha256 != found.FingerprintSha256 {
+		return false
+	}
+	if !m.entry.hasVersion2Matchers() {
+		return true
+	}
+
+	cert := found.Certificate
+	if cert == nil {
+		return false
+	}
+	if m.spkiSHA256 != nil && *m.spkiSHA256 != sha256.Sum256(cert.RawSubjectPublicKeyInfo) {
+		return false
+	}
+	if m.subject != "" && m.subject != cert.Subject.String() {
+		return false
+	}
+	if m.subjectRegex != nil && !m.subjectRegex.MatchString(cert.Subject.String()) {
+		return false
+	}
+	if m.issuer != "" && m.issuer != cert.Issuer.String() {
+		return false
+	}
+	if m.issuerRegex != nil && !m.issuerRegex.MatchString(cert.Issuer.String()) {
+		return false
+	}
+	if m.serial != nil && (cert.SerialNumber == nil || m.serial.Cmp(cert.SerialNumber) != 0) {
+		return false
+	}
+	if m.keyID != nil && !bytes.Equal(m.keyID, cert.SubjectKeyId) {
+		return false
+	}
+	return true
+}
+
+// permits returns true if the entry applies to certificates found in the
+// location.
+func (m *matcher) permits(location string) bool {
+	if len(m.paths) == 0 {
+		return true
+	}
+	for _, glob := range m.paths {
+		if matchGlob(glob, location) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesIn returns true if the certificate matches the entry, and was found
+// in a location the entry applies to.
+func (m *matcher) matchesIn(found certificate.Found) bool {
+	return m.matches(found) && m.permits(found.Location)
+}
@@ -0,0 +1,323 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func TestMatcher(t *testing.T) {
+	found := testCertificate(t)
+	cert := found.Certificate
+	spki := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	for name, tc := range map[string]struct {
+		entry   CertificateEntry
+		matches bool
+	}{
+		"SHA256 fingerprint": {
+			entry:   CertificateEntry{Fingerprints: CertificateFingerprints{Sha256: hex.EncodeToString(found.FingerprintSha256[:])}},
+			matches: true,
+		},
+		"SPKI SHA256": {
+			entry:   CertificateEntry{SPKISHA256: hex.EncodeToString(spki[:])},
+			matches: true,
+		},
+		"other SPKI SHA256": {
+			entry: CertificateEntry{SPKISHA256: hex.EncodeToString(make([]byte, 32))},
+		},
+		"subject": {
+			entry:   CertificateEntry{Subject: "CN=Example Root CA,O=Example"},
+			matches: true,
+		},
+		"other subject": {
+			entry: CertificateEntry{Subject: "CN=Example Root CA"},
+		},
+		"subject regex": {
+			entry:   CertificateEntry{SubjectRegex: "^CN=Example .* CA,"},
+			matches: true,
+		},
+		"issuer regex": {
+			entry: CertificateEntry{IssuerRegex: "Other"},
+		},
+		"serial with colons": {
+			entry:   CertificateEntry{Serial: "01:E2:40"},
+			matches: true,
+		},
+		"other serial": {
+			entry: CertificateEntry{Serial: "01e241"},
+		},
+		"key ID": {
+			entry:   CertificateEntry{KeyID: "01:02:03:04"},
+			matches: true,
+		},
+		"all matchers must match": {
+			entry: CertificateEntry{Issuer: "CN=Example Root CA,O=Example", Serial: "01"},
+		},
+		"issuer and serial": {
+			entry:   CertificateEntry{Issuer: "CN=Example Root CA,O=Example", Serial: "1e240"},
+			matches: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, err := newMatcher(tc.entry)
+			require.NoError(t, err)
+			assert.Equal(t, tc.matches, m.matches(found))
+		})
+	}
+
+	t.Run("Only fingerprints match unparsed certificates", func(t *testing.T) {
+		unparsed := found
+		unparsed.Certificate = nil
+
+		m, err := newMatcher(CertificateEntry{Fingerprints: CertificateFingerprints{Sha1: hex.EncodeToString(found.FingerprintSha1[:])}})
+		require.NoError(t, err)
+		assert.True(t, m.matches(unparsed))
+
+		m, err = newMatcher(CertificateEntry{SubjectRegex: ".*"})
+		require.NoError(t, err)
+		assert.False(t, m.matches(unparsed))
+	})
+
+	t.Run("Rejects invalid matchers", func(t *testing.T) {
+		for _, entry := range []CertificateEntry{
+			{SPKISHA256: "abcd"},
+			{SubjectRegex: "("},
+			{Serial: "xyz"},
+			{KeyID: "xyz"},
+		} {
+			_, err := newMatcher(entry)
+			assert.Error(t, err)
+		}
+	})
+}
+
+func TestValidatorVersion2(t *testing.T) {
+	found := testCertificate(t)
+	config := Config{
+		Version: ConfigVersion2,
+		Forbid: []CertificateEntry{
+			{IssuerRegex: "O=Example$", Comment: "example CA"},
+		},
+		Require: []CertificateEntry{
+			{Subject: "CN=Other Root CA"},
+		},
+	}
+
+	validator, err := NewValidator(config, true)
+	require.NoError(t, err)
+
+	r, err := validator.Validate([]certificate.Found{found})
+	require.NoError(t, err)
+	require.Len(t, r.ForbiddenCertificates, 1)
+	assert.Equal(t, []string{`issuer matching "O=Example$"`}, r.ForbiddenCertificates[0].Matchers())
+	assert.Equal(t, config.Require, r.RequiredButAbsent)
+}
+
+func TestIsConfigValid(t *testing.T) {
+	subject := CertificateEntry{Subject: "CN=Example Root CA"}
+
+	assert.True(t, IsConfigValid(&Config{Version: ConfigVersion2, Allow: []CertificateEntry{subject}}))
+	assert.False(t, IsConfigValid(&Config{Version: ConfigVersion1, Allow: []CertificateEntry{subject}}))
+	assert.False(t, IsConfigValid(&Config{Allow: []CertificateEntry{subject}}))
+	assert.False(t, IsConfigValid(&Config{Version: ConfigVersion2, Forbid: []CertificateEntry{{Comment: "no matchers"}}}))
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	for name, tc := range map[string]struct {
+		contents string
+		wantErr  bool
+	}{
+		"version 1":       {contents: "version: \"1\"\n"},
+		"version 2":       {contents: "version: \"2\"\nallow:\n  - subjectRegex: \"^CN=Example\"\n"},
+		"missing version": {contents: "allow: []\n", wantErr: true},
+		"unknown version": {contents: "version: \"3\"\n", wantErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(dir, name+".yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.contents), 0o600))
+			_, err := LoadConfig(context.Background(), path)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// testCertificate returns a self-signed certificate with the subject
+// "CN=Example Root CA,O=Example", serial 123456, and key ID 01020304.
+func testCertificate(t *testing.T) certificate.Found {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	name := pkix.Name{CommonName: "Example Root CA", Organization: []string{"Example"}}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(123456),
+		Subject:               name,
+		Issuer:                name,
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return certificate.Found{
+		Location:          "/etc/ssl/certs/example.pem",
+		Certificate:       cert,
+		FingerprintSha1:   sha1.Sum(der),
+		FingerprintSha256: sha256.Sum256(der),
+	}
+}
+
+func TestValidatorPaths(t *testing.T) {
+	found := testCertificate(t)
+	inApp := found
+	inApp.Location = "/app/certs/example.pem"
+
+	config := Config{
+		Version: ConfigVersion2,
+		Allow: []CertificateEntry{
+			{Subject: "CN=Example Root CA,O=Example", Paths: []string{"/etc/ssl/certs/*.pem"}},
+		},
+		PathRules: []PathRule{
+			{Path: "/app/**", Comment: "no certificates in the app"},
+		},
+	}
+
+	t.Run("Allows certificates in their paths", func(t *testing.T) {
+		validator, err := NewValidator(config, false)
+		require.NoError(t, err)
+		r, err := validator.Validate([]certificate.Found{found})
+		require.NoError(t, err)
+		assert.Truef(t, r.IsPass(), "Validation reported as failed, expected pass")
+	})
+
+	required := Config{
+		Version: ConfigVersion2,
+		Require: []CertificateEntry{{Subject: "CN=Example Root CA,O=Example", Paths: []string{"/etc/ssl/certs/*.pem"}}},
+	}
+	unlisted := Config{
+		Version: ConfigVersion2,
+		Allow:   []CertificateEntry{{Serial: "ffff"}},
+	}
+
+	for name, tc := range map[string]struct {
+		config    Config
+		found     certificate.Found
+		misplaced []MisplacedCert
+		// notAllowed is whether the certificate is reported as not allowed
+		// in strict mode.
+		notAllowed bool
+	}{
+		"allowed certificate in its paths": {
+			config: config,
+			found:  found,
+		},
+		"allowed certificate outside its paths": {
+			config: config,
+			found:  inApp,
+			misplaced: []MisplacedCert{
+				{Certificate: inApp, Entry: &config.Allow[0]},
+				{Certificate: inApp, Rule: &config.PathRules[0]},
+			},
+		},
+		"required certificate in its paths": {
+			config: required,
+			found:  found,
+		},
+		"required certificate outside its paths": {
+			config:    required,
+			found:     inApp,
+			misplaced: []MisplacedCert{{Certificate: inApp, Entry: &required.Require[0]}},
+		},
+		"unlisted certificate": {
+			config:     unlisted,
+			found:      found,
+			notAllowed: true,
+		},
+	} {
+		for mode, permissive := range map[string]bool{"strict": false, "permissive": true} {
+			t.Run(name+" in "+mode+" mode", func(t *testing.T) {
+				validator, err := NewValidator(tc.config, permissive)
+				require.NoError(t, err)
+				r, err := validator.Validate([]certificate.Found{tc.found})
+				require.NoError(t, err)
+				assert.Equal(t, tc.misplaced, r.MisplacedCertificates)
+				assert.Empty(t, r.RequiredButAbsent)
+				if tc.notAllowed && !permissive {
+					assert.Equal(t, []certificate.Found{tc.found}, r.NotAllowedCertificates)
+				} else {
+					assert.Empty(t, r.NotAllowedCertificates)
+				}
+			})
+		}
+	}
+
+	t.Run("Path rules allow their allowed certificates", func(t *testing.T) {
+		allowed := config
+		allowed.Allow = nil
+		allowed.PathRules = []PathRule{{Path: "/app/**", Allow: []CertificateEntry{{Serial: "1e240"}}}}
+		validator, err := NewValidator(allowed, true)
+		require.NoError(t, err)
+		r, err := validator.Validate([]certificate.Found{inApp})
+		require.NoError(t, err)
+		assert.Truef(t, r.IsPass(), "Validation reported as failed, expected pass")
+	})
+
+	t.Run("Forbids and requires certificates only in their paths", func(t *testing.T) {
+		scoped := Config{
+			Version: ConfigVersion2,
+			Forbid:  []CertificateEntry{{SubjectRegex: "Example", Paths: []string{"/app/**"}}},
+			Require: []CertificateEntry{{SubjectRegex: "Example", Paths: []string{"/etc/ssl/certs/*.pem"}}},
+		}
+		validator, err := NewValidator(scoped, true)
+		require.NoError(t, err)
+
+		r, err := validator.Validate([]certificate.Found{found})
+		require.NoError(t, err)
+		assert.Truef(t, r.IsPass(), "Validation reported as failed, expected pass")
+
+		r, err = validator.Validate([]certificate.Found{inApp})
+		require.NoError(t, err)
+		assert.Len(t, r.ForbiddenCertificates, 1)
+		assert.Equal(t, []MisplacedCert{{Certificate: inApp, Entry: &scoped.Require[0]}}, r.MisplacedCertificates)
+		assert.Empty(t, r.RequiredButAbsent)
+
+		r, err = validator.Validate(nil)
+		require.NoError(t, err)
+		assert.Equal(t, scoped.Require, r.RequiredButAbsent)
+	})
+
+	t.Run("Paths require config version 2", func(t *testing.T) {
+		assert.False(t, IsConfigValid(&Config{Allow: []CertificateEntry{{Fingerprints: CertificateFingerprints{Sha1: "abcd"}, Paths: []string{"/app/**"}}}}))
+		assert.False(t, IsConfigValid(&Config{Version: ConfigVersion1, PathRules: []PathRule{{Path: "/app/**"}}}))
+		assert.False(t, IsConfigValid(&Config{Version: ConfigVersion2, PathRules: []PathRule{{Path: "app"}}}))
+	})
+}
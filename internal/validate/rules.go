@@ -0,0 +1,136 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"time"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+// Rule names, as used for the keys of the rules in the config.
+const (
+	RuleExpiresWithinDays    = "expiresWithinDays"
+	RuleMinRSAKeySize        = "minRSAKeySize"
+	RuleForbidSHA1Signatures = "forbidSHA1Signatures"
+	RuleMaxValidityYears     = "maxValidityYears"
+	RuleForbidPartials       = "forbidPartials"
+)
+
+// Rules are checks of the properties of every certificate found, applied
+// alongside the allow, forbid, and require lists. Unset rules are not checked.
+type Rules struct {
+	// ExpiresWithinDays fails validation for certificates which have expired,
+	// or expire within this many days.
+	ExpiresWithinDays int `json:"expiresWithinDays,omitempty" yaml:"expiresWithinDays,omitempty"`
+	// MinRSAKeySize fails validation for RSA keys of fewer bits.
+	MinRSAKeySize int `json:"minRSAKeySize,omitempty" yaml:"minRSAKeySize,omitempty"`
+	// ForbidSHA1Signatures fails validation for certificates signed using
+	// SHA-1.
+	ForbidSHA1Signatures bool `json:"forbidSHA1Signatures,omitempty" yaml:"forbidSHA1Signatures,omitempty"`
+	// MaxValidityYears fails validation for certificates valid for more than
+	// this many calendar years.
+	MaxValidityYears int `json:"maxValidityYears,omitempty" yaml:"maxValidityYears,omitempty"`
+	// ForbidPartials fails validation if any partial certificate, which looks
+	// like a certificate but couldn't be parsed, is found.
+	ForbidPartials bool `json:"forbidPartials,omitempty" yaml:"forbidPartials,omitempty"`
+}
+
+// Enabled returns the names of the rules which are set, in a stable order.
+func (r Rules) Enabled() []string {
+	var names []string
+	if r.ExpiresWithinDays > 0 {
+		names = append(names, RuleExpiresWithinDays)
+	}
+	if r.MinRSAKeySize > 0 {
+		names = append(names, RuleMinRSAKeySize)
+	}
+	if r.ForbidSHA1Signatures {
+		names = append(names, RuleForbidSHA1Signatures)
+	}
+	if r.MaxValidityYears > 0 {
+		names = append(names, RuleMaxValidityYears)
+	}
+	if r.ForbidPartials {
+		names = append(names, RuleForbidPartials)
+	}
+	return names
+}
+
+// RuleViolation is a certificate, or partial certificate, which fails one of
+// the config's rules.
+type RuleViolation struct {
+	// Rule is the name of the rule, such as "minRSAKeySize".
+	Rule     string
+	Location string
+	// Certificate is the certificate which violated the rule, or nil for
+	// partial certificates.
+	Certificate *certificate.Found
+	Reason      string
+}
+
+// checkCertificate returns the rules the certificate violates. Certificates
+// which couldn't be parsed aren't checked.
+func (r Rules) checkCertificate(found certificate.Found, now time.Time) []RuleViolation {
+	cert := found.Certificate
+	if cert == nil {
+		return nil
+	}
+	var violations []RuleViolation
+	violation := func(rule, reason string) {
+		violations = append(violations, RuleViolation{
+			Rule:        rule,
+			Location:    found.Location,
+			Certificate: &found,
+			Reason:      reason,
+		})
+	}
+
+	if r.ExpiresWithinDays > 0 {
+		if now.After(cert.NotAfter) {
+			violation(RuleExpiresWithinDays, "expired on "+cert.NotAfter.Format(time.RFC3339))
+		} else if now.AddDate(0, 0, r.ExpiresWithinDays).After(cert.NotAfter) {
+			violation(RuleExpiresWithinDays, fmt.Sprintf("expires on %s, within %d days", cert.NotAfter.Format(time.RFC3339), r.ExpiresWithinDays))
+		}
+	}
+	if pub, ok := cert.PublicKey.(*rsa.PublicKey); ok && r.MinRSAKeySize > 0 {
+		if size := pub.N.BitLen(); size < r.MinRSAKeySize {
+			violation(RuleMinRSAKeySize, fmt.Sprintf("RSA key of %d bits, at least %d bits are required", size, r.MinRSAKeySize))
+		}
+	}
+	if r.ForbidSHA1Signatures {
+		switch cert.SignatureAlgorithm {
+		case x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+			violation(RuleForbidSHA1Signatures, "signed with "+cert.SignatureAlgorithm.String())
+		}
+	}
+	if r.MaxValidityYears > 0 {
+		// Compare dates rather than durations, as a time.Duration overflows
+		// at around 292 years.
+		if cert.NotAfter.After(cert.NotBefore.AddDate(r.MaxValidityYears, 0, 0)) {
+			years := float64(cert.NotAfter.Unix()-cert.NotBefore.Unix()) / (365 * 24 * 60 * 60)
+			violation(RuleMaxValidityYears, fmt.Sprintf("valid for %.1f years, at most %d are allowed", years, r.MaxValidityYears))
+		}
+	}
+	return violations
+}
+
+// checkPartials returns a violation for each partial certificate, if they are
+// forbidden.
+func (r Rules) checkPartials(partials []certificate.Partial) []RuleViolation {
+	if !r.ForbidPartials {
+		return nil
+	}
+	var violations []RuleViolation
+	for _, p := range partials {
+		violations = append(violations, RuleViolation{
+			Rule:     RuleForbidPartials,
+			Location: p.Location,
+			Reason:   p.Reason,
+		})
+	}
+	return violations
+}
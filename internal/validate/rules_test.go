@@ -0,0 +1,154 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package validate
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+)
+
+func TestRules(t *testing.T) {
+	issuerKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	issuer := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Example Root CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(10, 0, 0),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+
+	issue := func(t *testing.T, bits int, algorithm x509.SignatureAlgorithm, notBefore, notAfter time.Time) certificate.Found {
+		t.Helper()
+		key, err := rsa.GenerateKey(rand.Reader, bits)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber:       big.NewInt(2),
+			Subject:            pkix.Name{CommonName: "example.com"},
+			NotBefore:          notBefore,
+			NotAfter:           notAfter,
+			SignatureAlgorithm: algorithm,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, issuer, &key.PublicKey, issuerKey)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		return certificate.Found{
+			Location:          "/etc/ssl/certs/example.pem",
+			Certificate:       cert,
+			FingerprintSha256: sha256.Sum256(der),
+		}
+	}
+
+	rules := Rules{
+		ExpiresWithinDays:    30,
+		MinRSAKeySize:        2048,
+		ForbidSHA1Signatures: true,
+		MaxValidityYears:     25,
+		ForbidPartials:       true,
+	}
+	now := time.Now()
+
+	for name, tc := range map[string]struct {
+		found certificate.Found
+		rules []string
+	}{
+		"passes every rule": {
+			found: issue(t, 2048, x509.SHA256WithRSA, now.Add(-time.Hour), now.AddDate(1, 0, 0)),
+		},
+		"expires soon": {
+			found: issue(t, 2048, x509.SHA256WithRSA, now.Add(-time.Hour), now.AddDate(0, 0, 10)),
+			rules: []string{RuleExpiresWithinDays},
+		},
+		"expired": {
+			found: issue(t, 2048, x509.SHA256WithRSA, now.AddDate(-1, 0, 0), now.Add(-time.Hour)),
+			rules: []string{RuleExpiresWithinDays},
+		},
+		"weak RSA key": {
+			found: issue(t, 1024, x509.SHA256WithRSA, now.Add(-time.Hour), now.AddDate(1, 0, 0)),
+			rules: []string{RuleMinRSAKeySize},
+		},
+		"SHA-1 signature": {
+			found: issue(t, 2048, x509.SHA1WithRSA, now.Add(-time.Hour), now.AddDate(1, 0, 0)),
+			rules: []string{RuleForbidSHA1Signatures},
+		},
+		"long validity": {
+			found: issue(t, 2048, x509.SHA256WithRSA, now.Add(-time.Hour), now.AddDate(30, 0, 0)),
+			rules: []string{RuleMaxValidityYears},
+		},
+		"validity too long for a duration": {
+			found: issue(t, 2048, x509.SHA256WithRSA, now.Add(-time.Hour), now.AddDate(300, 0, 0)),
+			rules: []string{RuleMaxValidityYears},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, v := range rules.checkCertificate(tc.found, now) {
+				assert.Equal(t, tc.found.Location, v.Location)
+				assert.NotEmpty(t, v.Reason)
+				got = append(got, v.Rule)
+			}
+			assert.Equal(t, tc.rules, got)
+		})
+	}
+
+	t.Run("Self-signed certificates signed with SHA-1 are reported", func(t *testing.T) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(3),
+			Subject:               pkix.Name{CommonName: "Example SHA-1 Root CA"},
+			NotBefore:             now.Add(-time.Hour),
+			NotAfter:              now.AddDate(1, 0, 0),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			SignatureAlgorithm:    x509.SHA1WithRSA,
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		require.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		require.NoError(t, err)
+		require.True(t, certificate.IsSelfSigned(cert))
+
+		violations := rules.checkCertificate(certificate.Found{Location: "/etc/ssl/certs/root.pem", Certificate: cert}, now)
+		require.Len(t, violations, 1)
+		assert.Equal(t, RuleForbidSHA1Signatures, violations[0].Rule)
+	})
+
+	t.Run("Unset rules aren't checked", func(t *testing.T) {
+		found := issue(t, 1024, x509.SHA1WithRSA, now.AddDate(-1, 0, 0), now.Add(-time.Hour))
+		assert.Empty(t, Rules{}.checkCertificate(found, now))
+		assert.Empty(t, Rules{}.checkPartials([]certificate.Partial{{Location: "/bin/app"}}))
+	})
+
+	t.Run("Validator reports violations", func(t *testing.T) {
+		validator, err := NewValidator(Config{Version: ConfigVersion2, Rules: rules}, true)
+		require.NoError(t, err)
+		r, err := validator.ValidateParsed(&certificate.ParsedCertificates{
+			Found:    []certificate.Found{issue(t, 1024, x509.SHA256WithRSA, now.Add(-time.Hour), now.AddDate(1, 0, 0))},
+			Partials: []certificate.Partial{{Location: "/bin/app", Reason: "truncated"}},
+		})
+		require.NoError(t, err)
+		assert.Falsef(t, r.IsPass(), "Validation reported as passed, when we expected it to fail")
+		require.Len(t, r.RuleViolations, 2)
+		assert.Equal(t, RuleMinRSAKeySize, r.RuleViolations[0].Rule)
+		assert.Equal(t, RuleViolation{Rule: RuleForbidPartials, Location: "/bin/app", Reason: "truncated"}, r.RuleViolations[1])
+	})
+
+	t.Run("Rules require config version 2", func(t *testing.T) {
+		assert.False(t, IsConfigValid(&Config{Version: ConfigVersion1, Rules: rules}))
+		assert.False(t, IsConfigValid(&Config{Version: ConfigVersion2, Rules: Rules{MinRSAKeySize: -1}}))
+	})
+}
@@ -4,28 +4,48 @@ package validate
 
 import (
 	"fmt"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/jetstack/paranoia/internal/certificate"
-	"github.com/jetstack/paranoia/internal/util/checksum"
 )
 
 type Validator struct {
 	config         Config
 	permissiveMode bool
-	allowSHA1      map[[20]byte]bool
-	allowSHA256    map[[32]byte]bool
-	forbidSHA1     map[[20]byte]CertificateEntry
-	forbidSHA256   map[[32]byte]CertificateEntry
-	required       []CertificateEntry
+	allowed        []*matcher
+	forbidden      []*matcher
+	required       []*matcher
+	pathRules      []pathRule
+	exceptions     []exception
+}
+
+// pathRule is a compiled path rule.
+type pathRule struct {
+	rule    PathRule
+	allowed []*matcher
 }
 
 func (v *Validator) DescribeConfig() string {
 	s := fmt.Sprintf("%d allowed, %d forbidden, and %d required certificates",
-		len(v.allowSHA1)+len(v.allowSHA256),
-		len(v.forbidSHA1)+len(v.forbidSHA256),
+		len(v.allowed),
+		len(v.forbidden),
 		len(v.required))
+	if len(v.pathRules) > 0 {
+		s += fmt.Sprintf(", %d path rules", len(v.pathRules))
+	}
+	if len(v.exceptions) > 0 {
+		s += fmt.Sprintf(", %d exceptions", len(v.exceptions))
+	}
+	if rules := v.config.Rules.Enabled(); len(rules) > 0 {
+		s += ", with rules " + strings.Join(rules, ", ")
+	}
+	if v.config.ForbidPrivateKeys {
+		s += ", with private keys forbidden"
+	}
 	if v.permissiveMode {
 		s += ", in permissive mode"
 	} else {
@@ -34,6 +54,12 @@ func (v *Validator) DescribeConfig() string {
 	return s
 }
 
+// IsPermissive returns true if the validator allows any certificate that is not
+// otherwise forbidden.
+func (v *Validator) IsPermissive() bool {
+	return v.permissiveMode
+}
+
 func NewValidator(config Config, permissiveMode bool) (*Validator, error) {
 	if !IsConfigValid(&config) {
 		return nil, fmt.Errorf("invalid validator config")
@@ -41,97 +67,147 @@ func NewValidator(config Config, permissiveMode bool) (*Validator, error) {
 	v := Validator{
 		config:         config,
 		permissiveMode: permissiveMode,
-		allowSHA1:      make(map[[20]byte]bool),
-		allowSHA256:    make(map[[32]byte]bool),
-		forbidSHA1:     make(map[[20]byte]CertificateEntry),
-		forbidSHA256:   make(map[[32]byte]CertificateEntry),
-		required:       config.Require,
-	}
-	if !permissiveMode {
-		for i, allowed := range config.Allow {
-			if allowed.Fingerprints.Sha256 != "" {
-				sha, err := checksum.ParseSHA256(allowed.Fingerprints.Sha256)
-				if err != nil {
-					return nil, errors.Wrap(err, fmt.Sprintf("entry at position %d in allow list had invalid SHA256", i))
-				}
-				v.allowSHA256[sha] = true
-			} else if allowed.Fingerprints.Sha1 != "" {
-				sha, err := checksum.ParseSHA1(allowed.Fingerprints.Sha1)
-				if err != nil {
-					return nil, errors.Wrap(err, fmt.Sprintf("entry at position %d in allow list had invalid SHA1", i))
-				}
-				v.allowSHA1[sha] = true
-			}
-		}
-
-		for i, required := range config.Require {
-			if required.Fingerprints.Sha256 != "" {
-				sha, err := checksum.ParseSHA256(required.Fingerprints.Sha256)
-				if err != nil {
-					return nil, errors.Wrap(err, fmt.Sprintf("entry at position %d in require list had invalid SHA256", i))
-				}
-				v.allowSHA256[sha] = true
-			} else if required.Fingerprints.Sha1 != "" {
-				sha, err := checksum.ParseSHA1(required.Fingerprints.Sha1)
-				if err != nil {
-					return nil, errors.Wrap(err, fmt.Sprintf("entry at position %d in require list had invalid SHA1", i))
-				}
-				v.allowSHA1[sha] = true
-			}
+	}
 
+	var err error
+	if v.required, err = newMatchers(config.Require, "require"); err != nil {
+		return nil, err
+	}
+	// The allowed entries are needed in permissive mode too, to check the
+	// locations of the certificates they match.
+	if v.allowed, err = newMatchers(config.Allow, "allow"); err != nil {
+		return nil, err
+	}
+	// Required certificates are implicitly allowed.
+	v.allowed = append(v.allowed, v.required...)
+	if v.forbidden, err = newMatchers(config.Forbid, "forbid"); err != nil {
+		return nil, err
+	}
+	for i, rule := range config.PathRules {
+		allowed, err := newMatchers(rule.Allow, fmt.Sprintf("path rule %d allow", i))
+		if err != nil {
+			return nil, err
 		}
+		v.pathRules = append(v.pathRules, pathRule{rule: rule, allowed: allowed})
 	}
-
-	for i, forbidden := range config.Forbid {
-		if forbidden.Fingerprints.Sha256 != "" {
-			sha, err := checksum.ParseSHA256(forbidden.Fingerprints.Sha256)
-			if err != nil {
-				return nil, errors.Wrap(err, fmt.Sprintf("entry at position %d in forbid list had invalid SHA256", i))
-			}
-			v.forbidSHA256[sha] = forbidden
-		} else if forbidden.Fingerprints.Sha1 != "" {
-			sha, err := checksum.ParseSHA1(forbidden.Fingerprints.Sha1)
-			if err != nil {
-				return nil, errors.Wrap(err, fmt.Sprintf("entry at position %d in forbid list had invalid SHA1", i))
-			}
-			v.forbidSHA1[sha] = forbidden
+	for i, e := range config.Exceptions {
+		m, err := newMatcher(e.CertificateEntry)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("exception at position %d", i))
 		}
+		v.exceptions = append(v.exceptions, exception{exception: e, matcher: m})
 	}
 	return &v, nil
 }
 
+func newMatchers(entries []CertificateEntry, list string) ([]*matcher, error) {
+	matchers := make([]*matcher, 0, len(entries))
+	for i, entry := range entries {
+		m, err := newMatcher(entry)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("entry at position %d in %s list", i, list))
+		}
+		matchers = append(matchers, m)
+	}
+	return matchers, nil
+}
+
 type ForbiddenCert struct {
 	Certificate certificate.Found
 	Entry       CertificateEntry
 }
 
+// Matchers describes the matchers of the config entry which identified the
+// certificate as forbidden.
+func (f ForbiddenCert) Matchers() []string {
+	return f.Entry.Matchers()
+}
+
+// MisplacedCert is a certificate found in a location where the config doesn't
+// permit it. Exactly one of Entry and Rule is set.
+type MisplacedCert struct {
+	Certificate certificate.Found
+	// Entry is the allow or require entry which matched the certificate, but
+	// not the location it was found in.
+	Entry *CertificateEntry
+	// Rule is the path rule which matched the location the certificate was
+	// found in, but doesn't allow the certificate.
+	Rule *PathRule
+}
+
 type Result struct {
 	NotAllowedCertificates []certificate.Found
 	ForbiddenCertificates  []ForbiddenCert
+	MisplacedCertificates  []MisplacedCert
+	RuleViolations         []RuleViolation
 	RequiredButAbsent      []CertificateEntry
+	ForbiddenPrivateKeys   []certificate.PrivateKey
+	// Waived are the issues which exceptions in the config waived. They don't
+	// fail validation.
+	Waived []WaivedIssue
 }
 
 func (r *Result) IsPass() bool {
-	return r != nil && len(r.ForbiddenCertificates) == 0 && len(r.NotAllowedCertificates) == 0 && len(r.RequiredButAbsent) == 0
+	return r != nil && len(r.ForbiddenCertificates) == 0 && len(r.NotAllowedCertificates) == 0 && len(r.RequiredButAbsent) == 0 &&
+		len(r.ForbiddenPrivateKeys) == 0 && len(r.MisplacedCertificates) == 0 && len(r.RuleViolations) == 0
 }
 
+// ValidateParsed validates the certificates found in an image, as Validate
+// does, along with any private keys and partial certificates found if the
+// config forbids them.
+func (v *Validator) ValidateParsed(parsed *certificate.ParsedCertificates) (Result, error) {
+	result, err := v.Validate(parsed.Found)
+	if err != nil {
+		return Result{}, err
+	}
+	now := time.Now()
+	if v.config.ForbidPrivateKeys {
+		for _, key := range parsed.PrivateKeys {
+			if !v.waive(&result, now, IssuePrivateKey, key.Location, nil) {
+				result.ForbiddenPrivateKeys = append(result.ForbiddenPrivateKeys, key)
+			}
+		}
+	}
+	v.addRuleViolations(&result, now, v.config.Rules.checkPartials(parsed.Partials))
+	return result, nil
+}
+
+// Validate validates the certificates found in an image against the config.
+// Issues covered by an active exception are recorded as waived instead.
 func (v *Validator) Validate(founds []certificate.Found) (Result, error) {
 	var result Result
 
-	sha1checksums := make(map[[20]byte]bool)
-	sha256checksums := make(map[[32]byte]bool)
-
+	// misplaced are the certificates matching an allowed entry, but found
+	// outside of its paths.
+	var misplaced []certificate.Found
+	now := time.Now()
 	for _, cert := range founds {
-		sha1checksums[cert.FingerprintSha1] = true
-		sha256checksums[cert.FingerprintSha256] = true
+		v.addRuleViolations(&result, now, v.config.Rules.checkCertificate(cert, now))
+
+		if ce := v.misplacedBy(cert); ce != nil {
+			misplaced = append(misplaced, cert)
+			if !v.waive(&result, now, IssueMisplaced, cert.Location, &cert) {
+				result.MisplacedCertificates = append(result.MisplacedCertificates, MisplacedCert{
+					Certificate: cert,
+					Entry:       ce,
+				})
+			}
+		} else if !v.permissiveMode && !v.IsAllowed(cert) && !v.waive(&result, now, IssueNotAllowed, cert.Location, &cert) {
+			result.NotAllowedCertificates = append(result.NotAllowedCertificates, cert)
+		}
 
-		if !v.permissiveMode {
-			if !v.IsAllowed(cert) {
-				result.NotAllowedCertificates = append(result.NotAllowedCertificates, cert)
+		for _, pr := range v.pathRules {
+			if matchGlob(pr.rule.Path, cert.Location) && !slices.ContainsFunc(pr.allowed, func(m *matcher) bool { return m.matches(cert) }) &&
+				!v.waive(&result, now, IssueMisplaced, cert.Location, &cert) {
+				rule := pr.rule
+				result.MisplacedCertificates = append(result.MisplacedCertificates, MisplacedCert{
+					Certificate: cert,
+					Rule:        &rule,
+				})
 			}
 		}
 
-		if b, ce := v.IsForbidden(cert); b {
+		if b, ce := v.IsForbidden(cert); b && !v.waive(&result, now, IssueForbidden, cert.Location, &cert) {
 			result.ForbiddenCertificates = append(result.ForbiddenCertificates, ForbiddenCert{
 				Certificate: cert,
 				Entry:       *ce,
@@ -139,50 +215,55 @@ func (v *Validator) Validate(founds []certificate.Found) (Result, error) {
 		}
 	}
 
-	// Check for missing required certificates
+	// Check for missing required certificates. A required certificate found
+	// outside of its paths has already been reported as misplaced.
 	for _, required := range v.required {
-		if required.Fingerprints.Sha256 != "" {
-			s, err := checksum.ParseSHA256(required.Fingerprints.Sha256)
-			if err != nil {
-				return Result{}, err
-			}
-			if _, ok := sha256checksums[s]; !ok {
-				result.RequiredButAbsent = append(result.RequiredButAbsent, required)
-			}
-		} else if required.Fingerprints.Sha1 != "" {
-			s, err := checksum.ParseSHA1(required.Fingerprints.Sha1)
-			if err != nil {
-				return Result{}, err
-			}
-			if _, ok := sha1checksums[s]; !ok {
-				result.RequiredButAbsent = append(result.RequiredButAbsent, required)
-			}
+		if !slices.ContainsFunc(founds, required.matchesIn) && !slices.ContainsFunc(misplaced, required.matches) {
+			result.RequiredButAbsent = append(result.RequiredButAbsent, required.entry)
 		}
 	}
 
 	return result, nil
 }
 
-func (v *Validator) IsAllowed(result certificate.Found) bool {
-	if _, ok := v.allowSHA1[result.FingerprintSha1]; ok {
-		return true
+func (v *Validator) addRuleViolations(result *Result, now time.Time, violations []RuleViolation) {
+	for _, rv := range violations {
+		if !v.waive(result, now, IssueRule+rv.Rule, rv.Location, rv.Certificate) {
+			result.RuleViolations = append(result.RuleViolations, rv)
+		}
 	}
+}
 
-	if _, ok := v.allowSHA256[result.FingerprintSha256]; ok {
-		return true
+func (v *Validator) IsAllowed(result certificate.Found) bool {
+	for _, m := range v.allowed {
+		if m.matchesIn(result) {
+			return true
+		}
 	}
-
 	return false
 }
 
-func (v *Validator) IsForbidden(result certificate.Found) (bool, *CertificateEntry) {
-	if ce, ok := v.forbidSHA1[result.FingerprintSha1]; ok {
-		return true, &ce
+// misplacedBy returns the allowed entry which matched the certificate but not
+// its location, if no allowed entry matched both.
+func (v *Validator) misplacedBy(result certificate.Found) *CertificateEntry {
+	if v.IsAllowed(result) {
+		return nil
 	}
-
-	if ce, ok := v.forbidSHA256[result.FingerprintSha256]; ok {
-		return true, &ce
+	for _, m := range v.allowed {
+		if m.matches(result) {
+			ce := m.entry
+			return &ce
+		}
 	}
+	return nil
+}
 
+func (v *Validator) IsForbidden(result certificate.Found) (bool, *CertificateEntry) {
+	for _, m := range v.forbidden {
+		if m.matchesIn(result) {
+			ce := m.entry
+			return true, &ce
+		}
+	}
 	return false, nil
 }
@@ -219,6 +219,33 @@ func TestValidator(t *testing.T) {
 		})
 
 	})
+
+	t.Run("Forbid Private Keys", func(t *testing.T) {
+		parsed := &certificate.ParsedCertificates{
+			PrivateKeys: []certificate.PrivateKey{{Location: "/app/server.key"}},
+		}
+
+		t.Run("Private keys are ignored by default", func(t *testing.T) {
+			validator, err := NewValidator(Config{}, true)
+			require.NoError(t, err)
+			r, err := validator.ValidateParsed(parsed)
+			assert.NoError(t, err)
+			assert.Truef(t, r.IsPass(), "Validation reported as failed, when we expected it to pass")
+		})
+
+		t.Run("Private keys are rejected when forbidden", func(t *testing.T) {
+			validator, err := NewValidator(Config{ForbidPrivateKeys: true}, true)
+			require.NoError(t, err)
+			r, err := validator.ValidateParsed(parsed)
+			assert.NoError(t, err)
+			assert.Falsef(t, r.IsPass(), "Validation reported as passed, when we expected it to fail")
+			assert.Equal(t, parsed.PrivateKeys, r.ForbiddenPrivateKeys)
+
+			r, err = validator.ValidateParsed(&certificate.ParsedCertificates{})
+			assert.NoError(t, err)
+			assert.Truef(t, r.IsPass(), "Validation reported as failed, when we expected it to pass")
+		})
+	})
 }
 
 func anySHA1() [20]byte {
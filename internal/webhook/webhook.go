@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: Apache-2.0
+
+// Package webhook implements a Kubernetes validating admission webhook, which
+// denies Pods and workloads whose images fail validation.
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/sync/singleflight"
+	admissionv1 "k8s.io/api/admission/v1"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/manifests"
+	"github.com/jetstack/paranoia/internal/scancache"
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+// Handler validates the images of admitted Pods and workloads, caching the
+// certificates found in the most recently used image digests. Cached
+// certificates are validated again for each request, since the verdict changes
+// as exceptions and certificates expire. Concurrent admissions of the same
+// digest share a single scan.
+type Handler struct {
+	validator *validate.Validator
+	filter    func(*certificate.ParsedCertificates) *certificate.ParsedCertificates
+
+	// resolve and scan are image.Digest and image.ScanImage, replaced in tests.
+	resolve func(ctx context.Context, name string) (string, error)
+	scan    func(ctx context.Context, name string) (*image.Result, error)
+
+	scans    *scancache.Cache
+	scanning singleflight.Group
+}
+
+var _ admission.Handler = &Handler{}
+
+// NewHandler returns a handler validating images with the validator, caching
+// the scans of up to cacheSize image digests. If filter is not nil, it selects
+// the certificates validated, such as those trusted by the operating system.
+func NewHandler(validator *validate.Validator, filter func(*certificate.ParsedCertificates) *certificate.ParsedCertificates, cacheSize int, opts ...image.Option) *Handler {
+	return &Handler{
+		validator: validator,
+		filter:    filter,
+		resolve: func(ctx context.Context, name string) (string, error) {
+			return image.Digest(ctx, name, opts...)
+		},
+		scan: func(ctx context.Context, name string) (*image.Result, error) {
+			return image.ScanImage(ctx, name, opts...)
+		},
+		scans: scancache.New(cacheSize),
+	}
+}
+
+// Handle allows the object if every image its containers run passes
+// validation, and otherwise denies it, listing the fingerprints of the
+// certificates responsible.
+func (h *Handler) Handle(ctx context.Context, req admission.Request) admission.Response {
+	if req.Operation == admissionv1.Delete || len(req.Object.Raw) == 0 {
+		return admission.Allowed("")
+	}
+
+	containers, err := manifests.Parse(req.Kind.Kind, req.Object.Raw)
+	if err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	var denials []string
+	checked := make(map[string]bool)
+	for _, c := range containers {
+		if checked[c.Image] {
+			continue
+		}
+		checked[c.Image] = true
+
+		// Local tarballs would be read from the webhook's own filesystem.
+		if err := image.CheckReference(c.Image); err != nil {
+			denials = append(denials, fmt.Sprintf("image %s (%s %s): %s", c.Image, c.Type, c.Container, err))
+			continue
+		}
+		violations, err := h.verdict(ctx, c.Image)
+		if err != nil {
+			return admission.Errored(http.StatusInternalServerError, fmt.Errorf("image %s: %w", c.Image, err))
+		}
+		if len(violations) > 0 {
+			denials = append(denials, fmt.Sprintf("image %s (%s %s): %s", c.Image, c.Type, c.Container, strings.Join(violations, ", ")))
+		}
+	}
+
+	if len(denials) > 0 {
+		return admission.Denied(strings.Join(denials, "; "))
+	}
+	return admission.Allowed("")
+}
+
+// verdict returns the policy violations of the image, scanning it unless an
+// image with the same digest has been scanned before.
+func (h *Handler) verdict(ctx context.Context, name string) ([]string, error) {
+	digest, err := h.resolve(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := h.certificates(ctx, name, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := h.validator.ValidateParsed(parsed)
+	if err != nil {
+		return nil, err
+	}
+	return Violations(result), nil
+}
+
+// certificates returns the certificates found in the image, scanning it unless
+// its digest is cached, or joining a scan of the digest already in progress.
+func (h *Handler) certificates(ctx context.Context, name, digest string) (*certificate.ParsedCertificates, error) {
+	if parsed, ok := h.scans.Get(digest); ok {
+		return parsed, nil
+	}
+
+	v, err, _ := h.scanning.Do(digest, func() (any, error) {
+		// Scan the digest resolved, in case the tag has moved since.
+		pinned, err := image.Pin(name, digest)
+		if err != nil {
+			return nil, err
+		}
+		res, err := h.scan(ctx, pinned)
+		if err != nil {
+			return nil, err
+		}
+		parsed := res.Parsed
+		if h.filter != nil {
+			parsed = h.filter(parsed)
+		}
+		h.scans.Add(digest, parsed)
+		return parsed, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*certificate.ParsedCertificates), nil
+}
+
+// Violations describes each way the result fails validation, in a stable
+// order.
+func Violations(result validate.Result) []string {
+	var violations []string
+	for _, f := range result.ForbiddenCertificates {
+		violation := fmt.Sprintf("forbidden certificate %X", f.Certificate.FingerprintSha256)
+		if !f.Entry.IsFingerprintOnly() {
+			violation += " (matched by " + strings.Join(f.Matchers(), ", ") + ")"
+		}
+		violations = append(violations, violation)
+	}
+	for _, na := range result.NotAllowedCertificates {
+		violations = append(violations, fmt.Sprintf("not allowed certificate %X", na.FingerprintSha256))
+	}
+	for _, m := range result.MisplacedCertificates {
+		violations = append(violations, fmt.Sprintf("misplaced certificate %X in %s", m.Certificate.FingerprintSha256, m.Certificate.Location))
+	}
+	for _, req := range result.RequiredButAbsent {
+		if !req.IsFingerprintOnly() {
+			violations = append(violations, "missing required certificate with "+strings.Join(req.Matchers(), ", "))
+			continue
+		}
+		fingerprint := req.Fingerprints.Sha256
+		if fingerprint == "" {
+			fingerprint = req.Fingerprints.Sha1
+		}
+		violations = append(violations, fmt.Sprintf("missing required certificate %s", strings.ToUpper(fingerprint)))
+	}
+	for _, key := range result.ForbiddenPrivateKeys {
+		violations = append(violations, fmt.Sprintf("forbidden private key in %s", key.Location))
+	}
+	for _, rv := range result.RuleViolations {
+		if rv.Certificate == nil {
+			violations = append(violations, fmt.Sprintf("partial certificate in %s violates rule %s", rv.Location, rv.Rule))
+		} else {
+			violations = append(violations, fmt.Sprintf("certificate %X violates rule %s: %s", rv.Certificate.FingerprintSha256, rv.Rule, rv.Reason))
+		}
+	}
+	// The same certificate may be found in many files.
+	slices.Sort(violations)
+	return slices.Compact(violations)
+}
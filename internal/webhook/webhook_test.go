@@ -0,0 +1,266 @@
+// SPDX-License-Identifier: Apache-2.0
+
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+
+	"github.com/jetstack/paranoia/internal/certificate"
+	"github.com/jetstack/paranoia/internal/image"
+	"github.com/jetstack/paranoia/internal/util/checksum"
+	"github.com/jetstack/paranoia/internal/validate"
+)
+
+const forbiddenSHA256 = "bd40be0eccfce513ab318882f03962e4e2ec3799b51392e82805d9249e426d28"
+
+// fakeRegistry serves the certificates of images by name, counting scans.
+type fakeRegistry struct {
+	digests map[string]string
+	images  map[string][]certificate.Found
+	scans   map[string]int
+}
+
+func (f *fakeRegistry) handler(t *testing.T) http.Handler {
+	validator, err := validate.NewValidator(validate.Config{
+		Version: "1",
+		Forbid: []validate.CertificateEntry{
+			{Fingerprints: validate.CertificateFingerprints{Sha256: forbiddenSHA256}, Comment: "internal"},
+		},
+	}, true)
+	require.NoError(t, err)
+
+	h := NewHandler(validator, nil, 10)
+	f.stub(h)
+	return &webhook.Admission{Handler: h}
+}
+
+// stub replaces the handler's registry access with the fake registry.
+func (f *fakeRegistry) stub(h *Handler) {
+	h.resolve = func(_ context.Context, name string) (string, error) {
+		digest, ok := f.digests[name]
+		if !ok {
+			return "", errors.New("not found")
+		}
+		return digest, nil
+	}
+	h.scan = func(_ context.Context, name string) (*image.Result, error) {
+		// Images are scanned by the digest they were resolved to.
+		_, digest, ok := strings.Cut(name, "@")
+		if !ok {
+			return nil, errors.New("image is not pinned to a digest")
+		}
+		f.scans[digest]++
+		return &image.Result{
+			Name:   name,
+			Digest: digest,
+			Parsed: &certificate.ParsedCertificates{Found: f.images[digest]},
+		}, nil
+	}
+}
+
+func review(t *testing.T, url string, operation admissionv1.Operation, object string) *admissionv1.AdmissionResponse {
+	req := &admissionv1.AdmissionRequest{
+		UID:       types.UID("uid"),
+		Kind:      metav1.GroupVersionKind{Version: "v1", Kind: "Pod"},
+		Operation: operation,
+	}
+	if object != "" {
+		req.Object = runtime.RawExtension{Raw: []byte(object)}
+	}
+	body, err := json.Marshal(admissionv1.AdmissionReview{
+		TypeMeta: metav1.TypeMeta{APIVersion: "admission.k8s.io/v1", Kind: "AdmissionReview"},
+		Request:  req,
+	})
+	require.NoError(t, err)
+
+	resp, err := http.Post(url, "application/json", bytes.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var out admissionv1.AdmissionReview
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	require.NotNil(t, out.Response)
+	assert.Equal(t, types.UID("uid"), out.Response.UID)
+	return out.Response
+}
+
+func TestHandler(t *testing.T) {
+	forbidden := certificate.Found{
+		Location:          "/etc/ssl/certs/internal.pem",
+		FingerprintSha256: checksum.MustParseSHA256(forbiddenSHA256),
+	}
+	fake := &fakeRegistry{
+		digests: map[string]string{
+			"good:1":     "sha256:good",
+			"good:alias": "sha256:good",
+			"bad:1":      "sha256:bad",
+		},
+		images: map[string][]certificate.Found{
+			"sha256:bad": {forbidden, forbidden},
+		},
+		scans: make(map[string]int),
+	}
+	srv := httptest.NewServer(fake.handler(t))
+	defer srv.Close()
+
+	t.Run("allows pod with passing images", func(t *testing.T) {
+		resp := review(t, srv.URL, admissionv1.Create, `{"kind":"Pod","metadata":{"name":"p"},"spec":{"containers":[{"name":"a","image":"good:1"}]}}`)
+		assert.True(t, resp.Allowed)
+	})
+
+	t.Run("denies pod with forbidden certificate in an init container", func(t *testing.T) {
+		resp := review(t, srv.URL, admissionv1.Create, `{"kind":"Pod","metadata":{"name":"p"},"spec":{"initContainers":[{"name":"init","image":"bad:1"}],"containers":[{"name":"a","image":"good:1"}]}}`)
+		assert.False(t, resp.Allowed)
+		require.NotNil(t, resp.Result)
+		assert.Equal(t, "image bad:1 (initContainer init): forbidden certificate BD40BE0ECCFCE513AB318882F03962E4E2EC3799B51392E82805D9249E426D28", resp.Result.Message)
+	})
+
+	t.Run("caches scans by digest", func(t *testing.T) {
+		resp := review(t, srv.URL, admissionv1.Update, `{"kind":"Pod","metadata":{"name":"p"},"spec":{"containers":[{"name":"a","image":"good:alias"}]}}`)
+		assert.True(t, resp.Allowed)
+		assert.Equal(t, map[string]int{"sha256:good": 1, "sha256:bad": 1}, fake.scans)
+	})
+
+	t.Run("errors when an image can't be resolved", func(t *testing.T) {
+		resp := review(t, srv.URL, admissionv1.Create, `{"kind":"Pod","metadata":{"name":"p"},"spec":{"containers":[{"name":"a","image":"missing:1"}]}}`)
+		assert.False(t, resp.Allowed)
+		require.NotNil(t, resp.Result)
+		assert.Equal(t, int32(http.StatusInternalServerError), resp.Result.Code)
+	})
+
+	t.Run("denies images which aren't registry references", func(t *testing.T) {
+		for _, image := range []string{"file:///etc/paranoia/.paranoia.yaml", "-"} {
+			resp := review(t, srv.URL, admissionv1.Create, `{"kind":"Pod","metadata":{"name":"p"},"spec":{"containers":[{"name":"a","image":"`+image+`"}]}}`)
+			assert.False(t, resp.Allowed, image)
+			require.NotNil(t, resp.Result)
+			assert.Equal(t, "image "+image+" (container a): image must be a registry reference, not a local tarball", resp.Result.Message)
+		}
+	})
+
+	t.Run("allows deletes", func(t *testing.T) {
+		resp := review(t, srv.URL, admissionv1.Delete, "")
+		assert.True(t, resp.Allowed)
+	})
+}
+
+func TestViolations(t *testing.T) {
+	violations := Violations(validate.Result{
+		NotAllowedCertificates: []certificate.Found{{FingerprintSha256: [32]byte{1}}},
+		RequiredButAbsent: []validate.CertificateEntry{
+			{Fingerprints: validate.CertificateFingerprints{Sha1: "abcd"}},
+			{Subject: "CN=Example Root CA"},
+		},
+		ForbiddenCertificates: []validate.ForbiddenCert{
+			{Certificate: certificate.Found{FingerprintSha256: [32]byte{2}}, Entry: validate.CertificateEntry{Serial: "01"}},
+		},
+		MisplacedCertificates: []validate.MisplacedCert{
+			{Certificate: certificate.Found{Location: "/app/ca.pem", FingerprintSha256: [32]byte{3}}, Rule: &validate.PathRule{Path: "/app/**"}},
+		},
+		ForbiddenPrivateKeys: []certificate.PrivateKey{{Location: "/key.pem"}},
+		RuleViolations: []validate.RuleViolation{
+			{Rule: validate.RuleForbidPartials, Location: "/bin/app", Reason: "truncated"},
+		},
+	})
+	assert.Equal(t, []string{
+		"forbidden certificate 0200000000000000000000000000000000000000000000000000000000000000 (matched by serial 01)",
+		"forbidden private key in /key.pem",
+		"misplaced certificate 0300000000000000000000000000000000000000000000000000000000000000 in /app/ca.pem",
+		"missing required certificate ABCD",
+		`missing required certificate with subject "CN=Example Root CA"`,
+		"not allowed certificate 0100000000000000000000000000000000000000000000000000000000000000",
+		"partial certificate in /bin/app violates rule forbidPartials",
+	}, violations)
+}
+
+func TestHandlerRevalidatesCachedScans(t *testing.T) {
+	fake := &fakeRegistry{
+		digests: map[string]string{"vendor:1": "sha256:vendor"},
+		images: map[string][]certificate.Found{
+			"sha256:vendor": {{Location: "/etc/ssl/certs/internal.pem", FingerprintSha256: checksum.MustParseSHA256(forbiddenSHA256)}},
+		},
+		scans: make(map[string]int),
+	}
+	validator, err := validate.NewValidator(validate.Config{
+		Version: validate.ConfigVersion2,
+		Forbid:  []validate.CertificateEntry{{Fingerprints: validate.CertificateFingerprints{Sha256: forbiddenSHA256}}},
+		Exceptions: []validate.Exception{{
+			CertificateEntry: validate.CertificateEntry{Paths: []string{"/etc/ssl/certs/**"}},
+			Owner:            "vendor",
+			Expires:          time.Now().Add(200 * time.Millisecond),
+		}},
+	}, true)
+	require.NoError(t, err)
+	h := NewHandler(validator, nil, 10)
+	fake.stub(h)
+
+	violations, err := h.verdict(context.Background(), "vendor:1")
+	require.NoError(t, err)
+	assert.Empty(t, violations, "the exception should waive the forbidden certificate")
+
+	// Once the exception expires, the cached scan is denied.
+	time.Sleep(250 * time.Millisecond)
+	violations, err = h.verdict(context.Background(), "vendor:1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"forbidden certificate " + strings.ToUpper(forbiddenSHA256)}, violations)
+	assert.Equal(t, map[string]int{"sha256:vendor": 1}, fake.scans)
+}
+
+func TestHandlerSharesScans(t *testing.T) {
+	validator, err := validate.NewValidator(validate.Config{Version: validate.ConfigVersion2}, true)
+	require.NoError(t, err)
+
+	var (
+		scans   atomic.Int32
+		release = make(chan struct{})
+	)
+	h := NewHandler(validator, nil, 1)
+	h.resolve = func(_ context.Context, name string) (string, error) {
+		return "sha256:" + strings.TrimSuffix(name, ":1"), nil
+	}
+	h.scan = func(_ context.Context, name string) (*image.Result, error) {
+		scans.Add(1)
+		<-release
+		return &image.Result{Name: name, Parsed: &certificate.ParsedCertificates{}}, nil
+	}
+
+	// Concurrent admissions of the same digest share one scan.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := h.verdict(context.Background(), "app:1")
+			assert.NoError(t, err)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), scans.Load())
+
+	// The cache holds only the most recently used digest.
+	_, err = h.verdict(context.Background(), "other:1")
+	require.NoError(t, err)
+	_, err = h.verdict(context.Background(), "app:1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), scans.Load())
+}